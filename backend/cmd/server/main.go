@@ -1,8 +1,11 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"log"
 	"os"
+	"slices"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -11,6 +14,8 @@ import (
 	"github.com/kube-deploy/backend/internal/database"
 	"github.com/kube-deploy/backend/internal/k8s"
 	"github.com/kube-deploy/backend/internal/middleware"
+	"github.com/kube-deploy/backend/internal/models"
+	"github.com/kube-deploy/backend/internal/utils"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 	_ "github.com/kube-deploy/backend/docs"
@@ -37,88 +42,222 @@ import (
 // @description Type "Bearer" followed by a space and JWT token.
 
 func main() {
+	fakeCluster := flag.Bool("fake-cluster", false, "Serve an in-memory cluster with sample workloads instead of connecting to Kubernetes")
+	flag.Parse()
+
 	// Load configuration
 	cfg := config.Load()
 
-	// Connect to database (optional - for authentication)
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	middleware.GrantExec(cfg.ExecAllowedRoles)
+
+	if err := utils.LoadSigningKeys(cfg.JWTSigningKeyFile, cfg.JWTVerificationKeyFiles); err != nil {
+		log.Fatalf("Failed to load JWT keys: %v", err)
+	}
+	// Anyone could forge tokens signed with the well-known development secret
+	if gin.Mode() == gin.ReleaseMode && utils.UsingDefaultSecret() {
+		log.Fatal("Refusing to start in release mode with the default JWT secret, set JWT_SECRET or JWT_SIGNING_KEY_FILE")
+	}
+
+	// Connect to database (optional - for authentication). Without one every caller is an admin,
+	// so that has to be asked for and must not follow from an unreachable database.
 	if err := database.Connect(); err != nil {
-		log.Printf("Warning: Database connection failed: %v", err)
-		log.Println("Authentication features will be disabled")
+		log.Fatalf("Database connection failed: %v", err)
+	}
+	if database.GetDB() == nil {
+		if !*fakeCluster && !cfg.AuthDisabled {
+			log.Fatal("DATABASE_URL is not set. Set AUTH_DISABLED=true to run without authentication, which makes every caller an admin")
+		}
+		log.Println("Warning: Authentication is disabled, every caller is an admin")
 	} else {
 		// Seed demo users for testing
 		if err := database.SeedDemoUsers(); err != nil {
 			log.Printf("Warning: Failed to seed demo users: %v", err)
 		}
+		if err := database.SeedDemoTeams(); err != nil {
+			log.Printf("Warning: Failed to seed demo teams: %v", err)
+		}
 	}
 
-	// Initialize Kubernetes client
-	k8sClient, err := k8s.NewClient(cfg.KubeConfigPath)
-	if err != nil {
-		log.Fatalf("Failed to initialize Kubernetes client: %v", err)
+	// Load the Kubernetes clusters
+	clusters := loadClusters(cfg, *fakeCluster)
+	clusterHandler := handlers.NewClusterHandler(clusters, cfg.ClusterEncryptionKey)
+	if database.GetDB() != nil {
+		if err := clusterHandler.LoadRegisteredClusters(); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+	if cfg.DefaultCluster != "" && !clusters.SetDefault(cfg.DefaultCluster) {
+		log.Printf("Warning: Default cluster %s not found, using %s", cfg.DefaultCluster, clusters.DefaultName())
 	}
+	if clusters.DefaultName() == "" {
+		log.Println("Warning: No Kubernetes cluster is configured, register one with POST /api/clusters")
+	} else if database.GetDB() != nil {
+		// Team namespaces bound before bindings named a cluster were meant for the default one
+		assigned, err := database.AssignTeamNamespaceClusters(clusters.DefaultName())
+		if err != nil {
+			log.Fatalf("Failed to bind team namespaces to cluster %s: %v", clusters.DefaultName(), err)
+		}
+		if assigned > 0 {
+			log.Printf("Bound %d team namespaces without a cluster to cluster %s", assigned, clusters.DefaultName())
+		}
+	}
+	clusters.StartHealthChecks(context.Background(), cfg.ClusterHealthInterval)
 
 	// Initialize Gin router
-	router := gin.Default()
+	// The access log redacts the token query parameter WebSocket clients authenticate with
+	router := gin.New()
+	router.Use(middleware.Logger(), gin.Recovery())
+	// Client IPs key the login throttle and are recorded in sessions and the audit log, so
+	// X-Forwarded-For is only believed from the configured proxies
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
 
 	// Configure CORS - Allow all localhost ports for development
+	allowedOrigins := []string{"http://localhost:5173", "http://localhost:5174", "http://localhost:5175", "http://localhost:5176", "http://localhost:5177", "http://localhost:5178", "http://localhost:3000"}
 	router.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"http://localhost:5173", "http://localhost:5174", "http://localhost:5175", "http://localhost:5176", "http://localhost:5177", "http://localhost:5178", "http://localhost:3000"},
+		AllowOrigins:     allowedOrigins,
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
 		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
 		ExposeHeaders:    []string{"Content-Length"},
 		AllowCredentials: true,
 	}))
 
+	// Without a database there are no portal users to impersonate
+	impersonate := cfg.ImpersonateUsers && database.GetDB() != nil
+
 	// Initialize handlers
-	authHandler := handlers.NewAuthHandler()
-	podHandler := handlers.NewPodHandler(k8sClient)
-	deploymentHandler := handlers.NewDeploymentHandler(k8sClient)
-	serviceHandler := handlers.NewServiceHandler(k8sClient)
-	namespaceHandler := handlers.NewNamespaceHandler(k8sClient)
+	authHandler := handlers.NewAuthHandler(cfg.PasswordLogin, cfg.LoginThrottle, cfg.OIDC)
+	podHandler := handlers.NewPodHandler()
+	deploymentHandler := handlers.NewDeploymentHandler()
+	serviceHandler := handlers.NewServiceHandler()
+	namespaceHandler := handlers.NewNamespaceHandler()
+	manifestHandler := handlers.NewManifestHandler(impersonate)
+	execHandler := handlers.NewExecHandler(allowedOrigins)
+	proxyHandler := handlers.NewProxyHandler()
+	eventHandler := handlers.NewEventHandler()
+	watchHandler := handlers.NewWatchHandler(allowedOrigins)
+	auditHandler := handlers.NewAuditHandler()
+	tokenHandler := handlers.NewTokenHandler()
+	userHandler := handlers.NewUserHandler()
 
 	// Swagger documentation
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
+	// Public keys for verifying access tokens
+	router.GET("/.well-known/jwks.json", authHandler.JWKS)
+
 	// API routes
 	api := router.Group("/api")
+	// Every mutating call is recorded, including those that are rejected
+	api.Use(middleware.Audit())
 	{
 		// Auth routes (public)
 		auth := api.Group("/auth")
 		{
 			auth.POST("/signup", authHandler.Signup)
 			auth.POST("/login", authHandler.Login)
+			auth.POST("/refresh", authHandler.Refresh)
 			auth.GET("/me", middleware.AuthMiddleware(), authHandler.Me)
+			auth.POST("/logout", middleware.AuthMiddleware(), authHandler.Logout)
+			auth.PUT("/me", middleware.AuthMiddleware(), authHandler.UpdateMe)
+			auth.POST("/me/password", middleware.AuthMiddleware(), authHandler.ChangePassword)
+			auth.GET("/oidc/login", authHandler.OIDCLogin)
+			auth.GET("/oidc/callback", authHandler.OIDCCallback)
 		}
 
-		// Protected routes (require authentication if database is available)
-		// If no database, routes are accessible without auth
+		// Protected routes. With a database, callers must authenticate in strict mode and are
+		// treated as viewers otherwise. Without a database, which requires AUTH_DISABLED or the
+		// fake cluster, authentication is disabled entirely.
 		protected := api.Group("")
-		if database.GetDB() != nil {
-			protected.Use(middleware.OptionalAuthMiddleware())
+		switch {
+		case database.GetDB() == nil:
+			protected.Use(middleware.DefaultRole(models.RoleAdmin))
+		case cfg.StrictAuth:
+			protected.Use(middleware.AuthMiddleware())
+		default:
+			protected.Use(middleware.OptionalAuthMiddleware(), middleware.DefaultRole(models.RoleViewer))
 		}
-		{
+		// Resource routes act on the cluster named in the path or the cluster query parameter,
+		// or on the default cluster. Non-admin callers are limited to the namespaces of that
+		// cluster bound to their teams.
+		kubernetesClient := middleware.KubernetesClient(clusters, impersonate)
+		namespaceScope := middleware.NamespaceScope()
+		resourceRoutes := func(resources *gin.RouterGroup) {
 			// Pod routes
-			protected.POST("/pods", podHandler.CreatePod)
-			protected.GET("/pods", podHandler.ListPods)
-			protected.GET("/pods/:namespace/:name", podHandler.GetPod)
-			protected.DELETE("/pods/:namespace/:name", podHandler.DeletePod)
-			protected.GET("/pods/:namespace/:name/logs", podHandler.GetPodLogs)
+			resources.POST("/pods", middleware.RequirePermission(middleware.VerbWrite, "pods"), podHandler.CreatePod)
+			resources.GET("/pods", middleware.RequirePermission(middleware.VerbRead, "pods"), podHandler.ListPods)
+			resources.GET("/pods/:namespace/:name", middleware.RequirePermission(middleware.VerbRead, "pods"), podHandler.GetPod)
+			resources.DELETE("/pods/:namespace/:name", middleware.RequirePermission(middleware.VerbDelete, "pods"), podHandler.DeletePod)
+			resources.GET("/pods/:namespace/:name/logs", middleware.RequirePermission(middleware.VerbRead, "pods"), podHandler.GetPodLogs)
+			resources.GET("/pods/:namespace/:name/events", middleware.RequirePermission(middleware.VerbRead, "events"), eventHandler.ListPodEvents)
+			resources.GET("/pods/:namespace/:name/exec", middleware.AuthMiddleware(), middleware.RequirePermission(middleware.VerbExec, "pods"), execHandler.ExecPod)
+			resources.Any("/pods/:namespace/:name/proxy/:port/*path", middleware.RequirePermission(middleware.VerbProxy, "pods"), proxyHandler.ProxyPod)
 
 			// Deployment routes
-			protected.POST("/deployments", deploymentHandler.CreateDeployment)
-			protected.GET("/deployments", deploymentHandler.ListDeployments)
-			protected.GET("/deployments/:namespace/:name", deploymentHandler.GetDeployment)
-			protected.DELETE("/deployments/:namespace/:name", deploymentHandler.DeleteDeployment)
-			protected.PUT("/deployments/:namespace/:name/scale", deploymentHandler.ScaleDeployment)
+			resources.POST("/deployments", middleware.RequirePermission(middleware.VerbWrite, "deployments"), deploymentHandler.CreateDeployment)
+			resources.GET("/deployments", middleware.RequirePermission(middleware.VerbRead, "deployments"), deploymentHandler.ListDeployments)
+			resources.GET("/deployments/:namespace/:name", middleware.RequirePermission(middleware.VerbRead, "deployments"), deploymentHandler.GetDeployment)
+			resources.DELETE("/deployments/:namespace/:name", middleware.RequirePermission(middleware.VerbDelete, "deployments"), deploymentHandler.DeleteDeployment)
+			resources.PUT("/deployments/:namespace/:name/scale", middleware.RequirePermission(middleware.VerbWrite, "deployments"), deploymentHandler.ScaleDeployment)
+			resources.PUT("/deployments/:namespace/:name/image", middleware.RequirePermission(middleware.VerbWrite, "deployments"), deploymentHandler.UpdateDeploymentImage)
+			resources.POST("/deployments/:namespace/:name/rollback", middleware.RequirePermission(middleware.VerbWrite, "deployments"), deploymentHandler.RollbackDeployment)
+			resources.GET("/deployments/:namespace/:name/history", middleware.RequirePermission(middleware.VerbRead, "deployments"), deploymentHandler.GetDeploymentHistory)
+			resources.POST("/deployments/:namespace/:name/pause", middleware.RequirePermission(middleware.VerbWrite, "deployments"), deploymentHandler.PauseDeployment)
+			resources.POST("/deployments/:namespace/:name/resume", middleware.RequirePermission(middleware.VerbWrite, "deployments"), deploymentHandler.ResumeDeployment)
+			resources.POST("/deployments/:namespace/:name/restart", middleware.RequirePermission(middleware.VerbWrite, "deployments"), deploymentHandler.RestartDeployment)
+			resources.GET("/deployments/:namespace/:name/rollout-status", middleware.RequirePermission(middleware.VerbRead, "deployments"), deploymentHandler.StreamRolloutStatus)
+			resources.GET("/deployments/:namespace/:name/logs", middleware.RequirePermission(middleware.VerbRead, "deployments"), deploymentHandler.GetDeploymentLogs)
+			resources.GET("/deployments/:namespace/:name/events", middleware.RequirePermission(middleware.VerbRead, "events"), eventHandler.ListDeploymentEvents)
 
 			// Service routes
-			protected.POST("/services", serviceHandler.CreateService)
-			protected.GET("/services", serviceHandler.ListServices)
-			protected.GET("/services/:namespace/:name", serviceHandler.GetService)
-			protected.DELETE("/services/:namespace/:name", serviceHandler.DeleteService)
+			resources.POST("/services", middleware.RequirePermission(middleware.VerbWrite, "services"), serviceHandler.CreateService)
+			resources.GET("/services", middleware.RequirePermission(middleware.VerbRead, "services"), serviceHandler.ListServices)
+			resources.GET("/services/:namespace/:name", middleware.RequirePermission(middleware.VerbRead, "services"), serviceHandler.GetService)
+			resources.DELETE("/services/:namespace/:name", middleware.RequirePermission(middleware.VerbDelete, "services"), serviceHandler.DeleteService)
+			resources.GET("/services/:namespace/:name/events", middleware.RequirePermission(middleware.VerbRead, "events"), eventHandler.ListServiceEvents)
+			resources.Any("/services/:namespace/:name/proxy/:port/*path", middleware.RequirePermission(middleware.VerbProxy, "services"), proxyHandler.ProxyService)
 
 			// Namespace routes
-			protected.GET("/namespaces", namespaceHandler.ListNamespaces)
+			resources.GET("/namespaces", middleware.RequirePermission(middleware.VerbRead, "namespaces"), namespaceHandler.ListNamespaces)
+
+			// Event routes
+			resources.GET("/events", middleware.RequirePermission(middleware.VerbRead, "events"), eventHandler.ListEvents)
+
+			// Change feed routes, which check the read permission of every kind watched
+			resources.GET("/watch", watchHandler.Watch)
+
+			// Manifest routes
+			resources.POST("/manifests", middleware.RequirePermission(middleware.VerbWrite, "manifests"), manifestHandler.ApplyManifests)
+		}
+		resourceRoutes(protected.Group("", kubernetesClient, namespaceScope))
+		resourceRoutes(protected.Group("/clusters/:cluster", kubernetesClient, namespaceScope))
+		{
+			// Cluster routes
+			protected.GET("/clusters", middleware.RequirePermission(middleware.VerbRead, "clusters"), clusterHandler.ListClusters)
+			protected.GET("/clusters/:cluster", middleware.RequirePermission(middleware.VerbRead, "clusters"), clusterHandler.GetCluster)
+			protected.POST("/clusters", middleware.RequireRole(models.RoleAdmin), clusterHandler.CreateCluster)
+			protected.DELETE("/clusters/:cluster", middleware.RequireRole(models.RoleAdmin), clusterHandler.DeleteCluster)
+
+			// Audit routes
+			protected.GET("/audit", middleware.RequireRole(models.RoleAdmin), auditHandler.ListAuditEntries)
+
+			// User administration routes
+			admin := protected.Group("/admin", middleware.RequireRole(models.RoleAdmin))
+			admin.GET("/users", userHandler.ListUsers)
+			admin.GET("/users/:id", userHandler.GetUser)
+			admin.PUT("/users/:id", userHandler.UpdateUser)
+			admin.DELETE("/users/:id", userHandler.DeleteUser)
+			admin.POST("/users/:id/password", userHandler.ResetPassword)
+			admin.POST("/users/:id/unlock", userHandler.UnlockUser)
+
+			// API token routes, managed by the signed-in user
+			protected.POST("/tokens", middleware.AuthMiddleware(), tokenHandler.CreateToken)
+			protected.GET("/tokens", middleware.AuthMiddleware(), tokenHandler.ListTokens)
+			protected.DELETE("/tokens/:id", middleware.AuthMiddleware(), tokenHandler.DeleteToken)
 		}
 
 		// Health check
@@ -132,6 +271,16 @@ func main() {
 				"database": dbStatus,
 			})
 		})
+
+		// Readiness check, failing until the cluster caches have synced so that a new replica
+		// does not take traffic while its reads would all go to the API servers
+		api.GET("/ready", func(c *gin.Context) {
+			if clusters.CacheSyncing() {
+				c.JSON(503, gin.H{"status": "syncing"})
+				return
+			}
+			c.JSON(200, gin.H{"status": "ready"})
+		})
 	}
 
 	// Start server
@@ -145,3 +294,45 @@ func main() {
 		log.Fatalf("Failed to start server: %v", err)
 	}
 }
+
+// loadClusters builds the cluster registry from the cluster the backend runs in and every
+// context of the kubeconfig file, or from the fake cluster alone. The cluster the backend runs
+// in is the default, or else the kubeconfig's current context.
+func loadClusters(cfg *config.Config, fakeCluster bool) *k8s.Registry {
+	clusters := k8s.NewRegistry(cfg.InformerCache)
+	if fakeCluster {
+		log.Println("Using a fake in-memory cluster, changes are lost on restart")
+		clusters.Add(&k8s.Cluster{Name: "fake", Source: k8s.ClusterSourceFake, Client: k8s.NewFakeClient()})
+		return clusters
+	}
+
+	if client, err := k8s.NewInClusterClient(); err == nil {
+		clusters.Add(&k8s.Cluster{Name: k8s.InClusterName, Source: k8s.ClusterSourceInCluster, Client: client})
+	}
+
+	if _, err := os.Stat(cfg.KubeConfigPath); err != nil {
+		return clusters
+	}
+	clients, currentContext, err := k8s.LoadKubeconfig(cfg.KubeConfigPath)
+	if err != nil {
+		log.Printf("Warning: Failed to load kubeconfig %s: %v", cfg.KubeConfigPath, err)
+	}
+
+	contexts := make([]string, 0, len(clients))
+	for kubeContext := range clients {
+		contexts = append(contexts, kubeContext)
+	}
+	slices.Sort(contexts)
+	for _, kubeContext := range contexts {
+		name := k8s.ClusterName(kubeContext)
+		cluster := &k8s.Cluster{Name: name, Source: k8s.ClusterSourceKubeconfig, Client: clients[kubeContext]}
+		if name == "" || clusters.Add(cluster) != nil {
+			log.Printf("Warning: Skipping kubeconfig context %s, its cluster name %q is empty or taken", kubeContext, name)
+		}
+	}
+
+	if clusters.DefaultName() != k8s.InClusterName && currentContext != "" {
+		clusters.SetDefault(k8s.ClusterName(currentContext))
+	}
+	return clusters
+}
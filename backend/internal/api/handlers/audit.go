@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kube-deploy/backend/internal/database"
+	"github.com/kube-deploy/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+const (
+	// defaultAuditLimit is the number of audit entries returned when no limit is given
+	defaultAuditLimit = 100
+	// maxAuditLimit caps the number of audit entries returned by a single request
+	maxAuditLimit = 10000
+)
+
+type AuditHandler struct{}
+
+func NewAuditHandler() *AuditHandler {
+	return &AuditHandler{}
+}
+
+// ListAuditEntries handles listing the audit log
+// @Summary List audit log entries
+// @Description Get the mutating API calls recorded in the audit log, newest first. With format=csv the entries are exported as a CSV file.
+// @Tags audit
+// @Produce json
+// @Produce text/csv
+// @Security BearerAuth
+// @Param user query string false "Username filter"
+// @Param cluster query string false "Cluster filter"
+// @Param namespace query string false "Namespace filter"
+// @Param resource query string false "Resource filter, e.g. deployments"
+// @Param since query string false "Only return entries at or after this RFC3339 timestamp"
+// @Param until query string false "Only return entries before this RFC3339 timestamp"
+// @Param limit query int false "Maximum number of entries to return" default(100)
+// @Param format query string false "Response format, json or csv" default(json)
+// @Success 200 {object} models.APIResponse{data=[]models.AuditEntry}
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 503 {object} models.APIResponse
+// @Router /audit [get]
+func (h *AuditHandler) ListAuditEntries(c *gin.Context) {
+	db := database.GetDB()
+	if db == nil {
+		c.JSON(http.StatusServiceUnavailable, models.APIResponse{
+			Success: false,
+			Error:   "Database not available. Audit logging is disabled.",
+		})
+		return
+	}
+
+	query, limit, err := h.auditQuery(c, db)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Invalid request: %v", err),
+		})
+		return
+	}
+
+	entries := make([]models.AuditEntry, 0)
+	if err := query.Order("created_at DESC").Limit(limit).Find(&entries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to list audit entries",
+		})
+		return
+	}
+
+	if c.Query("format") == "csv" {
+		h.writeCSV(c, entries)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    entries,
+	})
+}
+
+// auditQuery builds the audit log query from the filter query parameters
+func (h *AuditHandler) auditQuery(c *gin.Context, db *gorm.DB) (*gorm.DB, int, error) {
+	query := db.Model(&models.AuditEntry{})
+
+	if user := c.Query("user"); user != "" {
+		query = query.Where("username = ?", user)
+	}
+	if cluster := c.Query("cluster"); cluster != "" {
+		query = query.Where("cluster = ?", cluster)
+	}
+	if namespace := c.Query("namespace"); namespace != "" {
+		query = query.Where("namespace = ?", namespace)
+	}
+	if resource := c.Query("resource"); resource != "" {
+		query = query.Where("resource = ?", resource)
+	}
+
+	if value := c.Query("since"); value != "" {
+		since, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, 0, fmt.Errorf("since must be an RFC3339 timestamp")
+		}
+		query = query.Where("created_at >= ?", since)
+	}
+	if value := c.Query("until"); value != "" {
+		until, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, 0, fmt.Errorf("until must be an RFC3339 timestamp")
+		}
+		query = query.Where("created_at < ?", until)
+	}
+
+	if format := c.Query("format"); format != "" && format != "json" && format != "csv" {
+		return nil, 0, fmt.Errorf("invalid format %q", format)
+	}
+
+	limit := defaultAuditLimit
+	if value := c.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			return nil, 0, fmt.Errorf("limit must be a positive number")
+		}
+		limit = min(parsed, maxAuditLimit)
+	}
+
+	return query, limit, nil
+}
+
+// writeCSV responds with the audit entries as a CSV attachment
+func (h *AuditHandler) writeCSV(c *gin.Context, entries []models.AuditEntry) {
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", `attachment; filename="audit.csv"`)
+	c.Status(http.StatusOK)
+
+	writer := csv.NewWriter(c.Writer)
+	writer.Write([]string{
+		"time", "user_id", "username", "method", "route", "path", "cluster", "namespace",
+		"resource", "name", "status", "duration_ms", "client_ip", "request_body",
+	})
+	for _, entry := range entries {
+		writer.Write([]string{
+			entry.CreatedAt.Format(time.RFC3339),
+			strconv.FormatUint(uint64(entry.UserID), 10),
+			csvCell(entry.Username),
+			csvCell(entry.Method),
+			csvCell(entry.Route),
+			csvCell(entry.Path),
+			csvCell(entry.Cluster),
+			csvCell(entry.Namespace),
+			csvCell(entry.Resource),
+			csvCell(entry.Name),
+			strconv.Itoa(entry.Status),
+			strconv.FormatInt(entry.DurationMs, 10),
+			csvCell(entry.ClientIP),
+			csvCell(entry.RequestBody),
+		})
+	}
+	writer.Flush()
+}
+
+// csvCell prefixes values that spreadsheets would evaluate as a formula with a quote, so that
+// exported request data cannot run formulas when the file is opened
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
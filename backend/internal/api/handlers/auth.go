@@ -1,32 +1,51 @@
 package handlers
 
 import (
+	"log"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/kube-deploy/backend/internal/config"
 	"github.com/kube-deploy/backend/internal/database"
 	"github.com/kube-deploy/backend/internal/models"
 	"github.com/kube-deploy/backend/internal/utils"
+	"gorm.io/gorm"
 )
 
-type AuthHandler struct{}
+type AuthHandler struct {
+	passwordLogin bool
+	throttle      config.LoginThrottleConfig
+	oidc          *oidcProvider
+}
 
-func NewAuthHandler() *AuthHandler {
-	return &AuthHandler{}
+// NewAuthHandler creates the authentication handler. Single sign-on is enabled when the
+// OIDC configuration is complete.
+func NewAuthHandler(passwordLogin bool, throttle config.LoginThrottleConfig, oidcConfig config.OIDCConfig) *AuthHandler {
+	h := &AuthHandler{passwordLogin: passwordLogin, throttle: throttle}
+	if oidcConfig.Enabled() {
+		h.oidc = &oidcProvider{config: oidcConfig}
+	}
+	return h
 }
 
 // Signup godoc
 // @Summary Register a new user
-// @Description Create a new user account
+// @Description Create a new user account with the viewer role. Admins grant other roles through the user administration API.
 // @Tags auth
 // @Accept json
 // @Produce json
 // @Param request body models.SignupRequest true "Signup request"
 // @Success 201 {object} models.APIResponse{data=models.LoginResponse}
 // @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
 // @Failure 500 {object} models.APIResponse
 // @Router /auth/signup [post]
 func (h *AuthHandler) Signup(c *gin.Context) {
+	if !h.requirePasswordLogin(c) {
+		return
+	}
+
 	var req models.SignupRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, models.APIResponse{
@@ -60,7 +79,7 @@ func (h *AuthHandler) Signup(c *gin.Context) {
 		Email:    req.Email,
 		Username: req.Username,
 		FullName: req.FullName,
-		Role:     "user",
+		Role:     models.RoleViewer,
 		Active:   true,
 	}
 
@@ -82,8 +101,8 @@ func (h *AuthHandler) Signup(c *gin.Context) {
 		return
 	}
 
-	// Generate JWT token
-	token, err := utils.GenerateToken(user.ID, user.Email, user.Username, user.Role)
+	// Start a session with an access and refresh token
+	response, err := h.startSession(c, db, user)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.APIResponse{
 			Success: false,
@@ -94,16 +113,13 @@ func (h *AuthHandler) Signup(c *gin.Context) {
 
 	c.JSON(http.StatusCreated, models.APIResponse{
 		Success: true,
-		Data: models.LoginResponse{
-			Token: token,
-			User:  user,
-		},
+		Data:    response,
 	})
 }
 
 // Login godoc
 // @Summary Login user
-// @Description Authenticate user and return JWT token
+// @Description Authenticate user and return a short-lived JWT access token and a refresh token
 // @Tags auth
 // @Accept json
 // @Produce json
@@ -111,9 +127,15 @@ func (h *AuthHandler) Signup(c *gin.Context) {
 // @Success 200 {object} models.APIResponse{data=models.LoginResponse}
 // @Failure 400 {object} models.APIResponse
 // @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 429 {object} models.APIResponse
 // @Failure 500 {object} models.APIResponse
 // @Router /auth/login [post]
 func (h *AuthHandler) Login(c *gin.Context) {
+	if !h.requirePasswordLogin(c) {
+		return
+	}
+
 	var req models.LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, models.APIResponse{
@@ -132,9 +154,20 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
+	// Failed logins from the client IP delay further attempts for any account
+	ipKey := ipThrottleKey(c.ClientIP())
+	if !h.loginAllowed(c, ipKey) {
+		return
+	}
+
 	// Find user by email
 	var user models.User
 	if err := db.Where("email = ?", req.Email).First(&user).Error; err != nil {
+		emailKey := emailThrottleKey(req.Email)
+		if !h.loginAllowed(c, emailKey) {
+			return
+		}
+		h.recordLoginFailure(c, emailKey, nil)
 		c.JSON(http.StatusUnauthorized, models.APIResponse{
 			Success: false,
 			Error:   "Invalid email or password",
@@ -142,6 +175,11 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
+	userKey := userThrottleKey(user.ID)
+	if !h.loginAllowed(c, userKey) {
+		return
+	}
+
 	// Check if user is active
 	if !user.Active {
 		c.JSON(http.StatusUnauthorized, models.APIResponse{
@@ -153,6 +191,7 @@ func (h *AuthHandler) Login(c *gin.Context) {
 
 	// Verify password
 	if err := user.CheckPassword(req.Password); err != nil {
+		h.recordLoginFailure(c, userKey, &user)
 		c.JSON(http.StatusUnauthorized, models.APIResponse{
 			Success: false,
 			Error:   "Invalid email or password",
@@ -160,8 +199,15 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	// Generate JWT token
-	token, err := utils.GenerateToken(user.ID, user.Email, user.Username, user.Role)
+	// The IP's failures are kept, so that one valid account cannot reset them while guessing
+	// the passwords of others
+	if err := database.ClearLoginFailures(userKey); err != nil {
+		log.Printf("Failed to clear failed logins of user %d: %v", user.ID, err)
+	}
+	recordLogin(c, db, &user)
+
+	// Start a session with an access and refresh token
+	response, err := h.startSession(c, db, user)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.APIResponse{
 			Success: false,
@@ -172,10 +218,7 @@ func (h *AuthHandler) Login(c *gin.Context) {
 
 	c.JSON(http.StatusOK, models.APIResponse{
 		Success: true,
-		Data: models.LoginResponse{
-			Token: token,
-			User:  user,
-		},
+		Data:    response,
 	})
 }
 
@@ -221,3 +264,386 @@ func (h *AuthHandler) Me(c *gin.Context) {
 		Data:    user,
 	})
 }
+
+// UpdateMe godoc
+// @Summary Update current user
+// @Description Change the current user's full name. The email address cannot be verified, so only admins can change it. Usernames cannot be changed, as they appear in the tokens of existing sessions and in the audit log.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.ProfileUpdateRequest true "Profile changes"
+// @Success 200 {object} models.APIResponse{data=models.User}
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /auth/me [put]
+func (h *AuthHandler) UpdateMe(c *gin.Context) {
+	var req models.ProfileUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		c.JSON(http.StatusServiceUnavailable, models.APIResponse{
+			Success: false,
+			Error:   "Database not available",
+		})
+		return
+	}
+
+	var user models.User
+	if err := db.First(&user, c.GetUint("userID")).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, models.APIResponse{
+			Success: false,
+			Error:   "Unauthorized",
+		})
+		return
+	}
+
+	// There is no way to verify a new address, so only admins may change it
+	if req.Email != nil && *req.Email != user.Email {
+		c.JSON(http.StatusForbidden, models.APIResponse{
+			Success: false,
+			Error:   "Your email address can only be changed by an administrator",
+		})
+		return
+	}
+	if req.Username != nil && *req.Username != user.Username {
+		c.JSON(http.StatusForbidden, models.APIResponse{
+			Success: false,
+			Error:   "Your username cannot be changed",
+		})
+		return
+	}
+	if req.FullName != nil {
+		user.FullName = *req.FullName
+	}
+
+	if err := db.Model(&user).Select("full_name").Updates(&user).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to update profile",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Profile updated successfully",
+		Data:    user,
+	})
+}
+
+// Refresh godoc
+// @Summary Refresh an access token
+// @Description Exchange a refresh token for a new access token and a new refresh token. Each refresh token can be used once, reusing one revokes its session.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body models.RefreshRequest true "Refresh request"
+// @Success 200 {object} models.APIResponse{data=models.LoginResponse}
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /auth/refresh [post]
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var req models.RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		c.JSON(http.StatusServiceUnavailable, models.APIResponse{
+			Success: false,
+			Error:   "Database not available. Authentication is disabled.",
+		})
+		return
+	}
+
+	var stored models.RefreshToken
+	if err := db.Preload("Session.User").Where("token_hash = ?", utils.HashToken(req.RefreshToken)).First(&stored).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, models.APIResponse{
+			Success: false,
+			Error:   "Invalid refresh token",
+		})
+		return
+	}
+	session := stored.Session
+
+	now := time.Now()
+	switch {
+	case session.RevokedAt != nil || now.After(stored.ExpiresAt) || now.After(session.ExpiresAt):
+		c.JSON(http.StatusUnauthorized, models.APIResponse{
+			Success: false,
+			Error:   "Session expired or revoked",
+		})
+		return
+	case !session.User.Active:
+		database.RevokeSession(session.ID)
+		c.JSON(http.StatusUnauthorized, models.APIResponse{
+			Success: false,
+			Error:   "Account is disabled",
+		})
+		return
+	}
+
+	// Claim the refresh token so that it cannot be used twice, even by concurrent requests.
+	// A token that was already used has leaked, so the whole session is ended.
+	result := db.Model(&stored).Where("used_at IS NULL").Update("used_at", now)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to refresh session",
+		})
+		return
+	}
+	if result.RowsAffected == 0 {
+		database.RevokeSession(session.ID)
+		c.JSON(http.StatusUnauthorized, models.APIResponse{
+			Success: false,
+			Error:   "Refresh token was already used, the session has been revoked",
+		})
+		return
+	}
+
+	response, err := h.issueTokens(db, &session, session.User)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to generate token",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    response,
+	})
+}
+
+// Logout godoc
+// @Summary Logout user
+// @Description End the current session, revoking its access and refresh tokens
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /auth/logout [post]
+func (h *AuthHandler) Logout(c *gin.Context) {
+	sessionID := c.GetString("sessionID")
+	if sessionID == "" {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Only login sessions can be logged out, delete API tokens instead",
+		})
+		return
+	}
+
+	if err := database.RevokeSession(sessionID); err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to logout",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Logged out successfully",
+	})
+}
+
+// ChangePassword godoc
+// @Summary Change password
+// @Description Change the current user's password. Every session of the user is revoked and a new one is started.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.ChangePasswordRequest true "Change password request"
+// @Success 200 {object} models.APIResponse{data=models.LoginResponse}
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Failure 503 {object} models.APIResponse
+// @Router /auth/me/password [post]
+func (h *AuthHandler) ChangePassword(c *gin.Context) {
+	var req models.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if c.GetString("sessionID") == "" {
+		c.JSON(http.StatusUnauthorized, models.APIResponse{
+			Success: false,
+			Error:   "Passwords can only be changed from a login session",
+		})
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		c.JSON(http.StatusServiceUnavailable, models.APIResponse{
+			Success: false,
+			Error:   "Database not available",
+		})
+		return
+	}
+
+	var user models.User
+	if err := db.First(&user, c.GetUint("userID")).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, models.APIResponse{
+			Success: false,
+			Error:   "Unauthorized",
+		})
+		return
+	}
+
+	if err := user.CheckPassword(req.CurrentPassword); err != nil {
+		c.JSON(http.StatusUnauthorized, models.APIResponse{
+			Success: false,
+			Error:   "Current password is incorrect",
+		})
+		return
+	}
+
+	if err := user.HashPassword(req.NewPassword); err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to hash password",
+		})
+		return
+	}
+
+	if err := db.Model(&user).Update("password", user.Password).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to update password",
+		})
+		return
+	}
+
+	// Sessions started with the old password, possibly by someone else, end here
+	if err := database.RevokeUserSessions(user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to revoke sessions",
+		})
+		return
+	}
+
+	response, err := h.startSession(c, db, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to generate token",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Password changed successfully",
+		Data:    response,
+	})
+}
+
+// JWKS serves the public keys access tokens are signed with, so that other services can verify
+// them. It is empty while tokens are signed with a shared secret.
+func (h *AuthHandler) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, utils.PublicKeys())
+}
+
+// requirePasswordLogin rejects the request when password login is turned off
+func (h *AuthHandler) requirePasswordLogin(c *gin.Context) bool {
+	if !h.passwordLogin {
+		c.JSON(http.StatusForbidden, models.APIResponse{
+			Success: false,
+			Error:   "Password login is disabled, please sign in with single sign-on",
+		})
+		return false
+	}
+	return true
+}
+
+// startSession starts a new login session for a user and issues its first tokens
+func (h *AuthHandler) startSession(c *gin.Context, db *gorm.DB, user models.User) (models.LoginResponse, error) {
+	sessionID, err := utils.RandomID()
+	if err != nil {
+		return models.LoginResponse{}, err
+	}
+
+	session := models.Session{
+		ID:        sessionID,
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(utils.RefreshTokenTTL),
+		ClientIP:  c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}
+	if err := db.Create(&session).Error; err != nil {
+		return models.LoginResponse{}, err
+	}
+
+	return h.issueTokens(db, &session, user)
+}
+
+// issueTokens issues a new access and refresh token for a session and extends the session
+// to the refresh token's expiry. Team memberships are looked up again on every refresh.
+func (h *AuthHandler) issueTokens(db *gorm.DB, session *models.Session, user models.User) (models.LoginResponse, error) {
+	teams, err := database.UserTeamNames(user.ID)
+	if err != nil {
+		return models.LoginResponse{}, err
+	}
+
+	refreshToken, refreshHash, err := utils.GenerateRefreshToken()
+	if err != nil {
+		return models.LoginResponse{}, err
+	}
+
+	expiresAt := time.Now().Add(utils.RefreshTokenTTL)
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&models.RefreshToken{
+			SessionID: session.ID,
+			TokenHash: refreshHash,
+			ExpiresAt: expiresAt,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Model(session).Update("expires_at", expiresAt).Error
+	})
+	if err != nil {
+		return models.LoginResponse{}, err
+	}
+
+	token, err := utils.GenerateToken(user.ID, user.Email, user.Username, user.Role, teams, session.ID)
+	if err != nil {
+		return models.LoginResponse{}, err
+	}
+
+	return models.LoginResponse{
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(utils.AccessTokenTTL.Seconds()),
+		User:         user,
+	}, nil
+}
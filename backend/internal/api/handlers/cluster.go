@@ -0,0 +1,302 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kube-deploy/backend/internal/database"
+	"github.com/kube-deploy/backend/internal/k8s"
+	"github.com/kube-deploy/backend/internal/models"
+	"github.com/kube-deploy/backend/internal/utils"
+)
+
+type ClusterHandler struct {
+	clusters *k8s.Registry
+	// encryptionKey encrypts the kubeconfigs of registered clusters in the database
+	encryptionKey string
+}
+
+func NewClusterHandler(clusters *k8s.Registry, encryptionKey string) *ClusterHandler {
+	return &ClusterHandler{
+		clusters:      clusters,
+		encryptionKey: encryptionKey,
+	}
+}
+
+// LoadRegisteredClusters adds the clusters registered through the API to the registry.
+// Clusters whose kubeconfig can no longer be decrypted or used are added without a client, so
+// that they are reported as unavailable and can still be deleted.
+func (h *ClusterHandler) LoadRegisteredClusters() error {
+	var records []models.Cluster
+	if err := database.GetDB().Order("name").Find(&records).Error; err != nil {
+		return fmt.Errorf("failed to load registered clusters: %w", err)
+	}
+
+	for _, record := range records {
+		cluster := &k8s.Cluster{Name: record.Name, Source: k8s.ClusterSourceRegistered}
+		if client, err := h.registeredClient(record); err != nil {
+			log.Printf("Warning: Failed to load cluster %s: %v", record.Name, err)
+			cluster.LoadError = err.Error()
+		} else {
+			cluster.Client = client
+		}
+
+		if err := h.clusters.Add(cluster); err != nil {
+			log.Printf("Warning: Skipping registered cluster %s: %v", record.Name, err)
+		}
+	}
+	return nil
+}
+
+// registeredClient creates the client of a registered cluster from its stored kubeconfig
+func (h *ClusterHandler) registeredClient(record models.Cluster) (*k8s.Client, error) {
+	kubeconfig, err := utils.DecryptSecret(h.encryptionKey, record.Kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	return k8s.NewClientFromKubeconfig(kubeconfig)
+}
+
+// ListClusters handles listing clusters
+// @Summary List clusters
+// @Description Get every cluster the API can act on, from the kubeconfig file and registered through the API, with the result of its latest health check
+// @Tags clusters
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.APIResponse{data=[]models.ClusterResponse}
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Router /clusters [get]
+func (h *ClusterHandler) ListClusters(c *gin.Context) {
+	defaultName := h.clusters.DefaultName()
+	clusters := h.clusters.List()
+
+	response := make([]models.ClusterResponse, 0, len(clusters))
+	for _, cluster := range clusters {
+		response = append(response, clusterResponse(cluster, defaultName))
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    response,
+	})
+}
+
+// GetCluster handles getting a cluster
+// @Summary Get a cluster
+// @Description Get a cluster and the result of its latest health check
+// @Tags clusters
+// @Produce json
+// @Security BearerAuth
+// @Param cluster path string true "Cluster name"
+// @Success 200 {object} models.APIResponse{data=models.ClusterResponse}
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Router /clusters/{cluster} [get]
+func (h *ClusterHandler) GetCluster(c *gin.Context) {
+	cluster, ok := h.findCluster(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    clusterResponse(cluster, h.clusters.DefaultName()),
+	})
+}
+
+// CreateCluster handles registering a cluster
+// @Summary Register a cluster
+// @Description Register a cluster from a kubeconfig. Only the chosen context is kept, encrypted in the database. Credential plugins and references to local files are not supported. The cluster is registered even when it cannot be reached, its health is reported in the response.
+// @Tags clusters
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param cluster body models.ClusterCreateRequest true "Cluster configuration"
+// @Success 201 {object} models.APIResponse{data=models.ClusterResponse}
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Failure 503 {object} models.APIResponse
+// @Router /clusters [post]
+func (h *ClusterHandler) CreateCluster(c *gin.Context) {
+	db := database.GetDB()
+	if db == nil {
+		c.JSON(http.StatusServiceUnavailable, models.APIResponse{
+			Success: false,
+			Error:   "Database not available. Cluster registration is disabled.",
+		})
+		return
+	}
+	if h.encryptionKey == "" {
+		c.JSON(http.StatusServiceUnavailable, models.APIResponse{
+			Success: false,
+			Error:   "Cluster registration is disabled, set CLUSTER_ENCRYPTION_KEY to enable it",
+		})
+		return
+	}
+
+	var req models.ClusterCreateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Invalid request: %v", err),
+		})
+		return
+	}
+	if !k8s.ValidClusterName(req.Name) {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid request: cluster names may only contain letters, digits, '.', '_' and '-'",
+		})
+		return
+	}
+
+	kubeconfig, contextName, err := k8s.MinifyKubeconfig([]byte(req.Kubeconfig), req.Context)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Invalid request: %v", err),
+		})
+		return
+	}
+	client, err := k8s.NewClientFromKubeconfig(kubeconfig)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Invalid request: %v", err),
+		})
+		return
+	}
+
+	encrypted, err := utils.EncryptSecret(h.encryptionKey, kubeconfig)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to encrypt kubeconfig",
+		})
+		return
+	}
+
+	// Adding the cluster to the registry first claims its name, including against clusters
+	// from the kubeconfig file that are not stored in the database
+	cluster := &k8s.Cluster{Name: req.Name, Source: k8s.ClusterSourceRegistered, Client: client}
+	if err := h.clusters.Add(cluster); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, k8s.ErrClusterExists) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, models.APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Failed to register cluster: %v", err),
+		})
+		return
+	}
+
+	record := models.Cluster{
+		Name:        req.Name,
+		Context:     contextName,
+		Kubeconfig:  encrypted,
+		CreatedByID: c.GetUint("userID"),
+	}
+	if err := db.Create(&record).Error; err != nil {
+		h.clusters.Remove(cluster.Name)
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to register cluster",
+		})
+		return
+	}
+
+	cluster.CheckHealth(c.Request.Context())
+
+	c.JSON(http.StatusCreated, models.APIResponse{
+		Success: true,
+		Message: "Cluster registered successfully",
+		Data:    clusterResponse(cluster, h.clusters.DefaultName()),
+	})
+}
+
+// DeleteCluster handles removing a registered cluster
+// @Summary Remove a cluster
+// @Description Remove a cluster registered through the API and delete its stored kubeconfig. Clusters from the kubeconfig file cannot be removed.
+// @Tags clusters
+// @Produce json
+// @Security BearerAuth
+// @Param cluster path string true "Cluster name"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /clusters/{cluster} [delete]
+func (h *ClusterHandler) DeleteCluster(c *gin.Context) {
+	cluster, ok := h.findCluster(c)
+	if !ok {
+		return
+	}
+
+	if cluster.Source != k8s.ClusterSourceRegistered {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Cluster %s was not registered through the API and cannot be removed", cluster.Name),
+		})
+		return
+	}
+
+	if err := database.GetDB().Where("name = ?", cluster.Name).Delete(&models.Cluster{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to remove cluster",
+		})
+		return
+	}
+	h.clusters.Remove(cluster.Name)
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Cluster removed successfully",
+	})
+}
+
+// findCluster looks up the cluster named in the path and responds with 404 when it does not
+// exist
+func (h *ClusterHandler) findCluster(c *gin.Context) (*k8s.Cluster, bool) {
+	cluster, ok := h.clusters.Get(c.Param("cluster"))
+	if !ok {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Cluster %q not found", c.Param("cluster")),
+		})
+		return nil, false
+	}
+	return cluster, true
+}
+
+// clusterResponse converts a cluster to its API representation
+func clusterResponse(cluster *k8s.Cluster, defaultName string) models.ClusterResponse {
+	health := cluster.Health()
+	response := models.ClusterResponse{
+		Name:    cluster.Name,
+		Source:  cluster.Source,
+		Default: cluster.Name == defaultName,
+		Healthy: health.Healthy,
+		Error:   health.Error,
+	}
+	if !health.CheckedAt.IsZero() {
+		response.CheckedAt = &health.CheckedAt
+	}
+	if cluster.Client != nil {
+		response.Cache = cluster.Client.CacheState()
+	} else {
+		response.Cache = k8s.CacheDisabled
+	}
+	return response
+}
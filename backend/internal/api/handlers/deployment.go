@@ -2,8 +2,11 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"reflect"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -11,16 +14,17 @@ import (
 	"github.com/kube-deploy/backend/internal/models"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/api/resource"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	appsv1apply "k8s.io/client-go/applyconfigurations/apps/v1"
+	corev1apply "k8s.io/client-go/applyconfigurations/core/v1"
+	metav1apply "k8s.io/client-go/applyconfigurations/meta/v1"
 )
 
-type DeploymentHandler struct {
-	k8sClient *k8s.Client
-}
+type DeploymentHandler struct{}
 
-func NewDeploymentHandler(k8sClient *k8s.Client) *DeploymentHandler {
-	return &DeploymentHandler{k8sClient: k8sClient}
+func NewDeploymentHandler() *DeploymentHandler {
+	return &DeploymentHandler{}
 }
 
 // CreateDeployment handles deployment creation
@@ -30,8 +34,13 @@ func NewDeploymentHandler(k8sClient *k8s.Client) *DeploymentHandler {
 // @Accept json
 // @Produce json
 // @Param deployment body models.DeploymentCreateRequest true "Deployment configuration"
+// @Param apply query bool false "Use server-side apply so an existing deployment is updated"
+// @Param dryRun query bool false "Validate on the API server without persisting"
+// @Success 200 {object} models.APIResponse{data=models.DeploymentResponse}
 // @Success 201 {object} models.APIResponse{data=models.DeploymentResponse}
 // @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
 // @Failure 500 {object} models.APIResponse
 // @Router /deployments [post]
 func (h *DeploymentHandler) CreateDeployment(c *gin.Context) {
@@ -44,63 +53,108 @@ func (h *DeploymentHandler) CreateDeployment(c *gin.Context) {
 		return
 	}
 
+	if !requireNamespace(c, req.Namespace) {
+		return
+	}
+
+	opts, err := writeOptionsFromQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Invalid request: %v", err),
+		})
+		return
+	}
+
 	deployment := h.buildDeploymentSpec(&req)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	createdDeployment, err := h.k8sClient.CreateDeployment(ctx, req.Namespace, deployment)
+	createdDeployment, err := kubeClient(c).CreateDeployment(ctx, req.Namespace, deployment, opts)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.APIResponse{
+		c.JSON(statusForError(err), models.APIResponse{
 			Success: false,
 			Error:   fmt.Sprintf("Failed to create deployment: %v", err),
 		})
 		return
 	}
 
-	c.JSON(http.StatusCreated, models.APIResponse{
+	status, message := writeResult(opts, "Deployment")
+	c.JSON(status, models.APIResponse{
 		Success: true,
-		Message: "Deployment created successfully",
+		Message: message,
 		Data:    h.deploymentToResponse(createdDeployment),
 	})
 }
 
 // ListDeployments handles listing deployments
 // @Summary List all deployments
-// @Description Get a list of all deployments in the cluster or a specific namespace
+// @Description Get a list of all deployments in the cluster or a specific namespace. With a limit the deployments are returned a page at a time, pass the continue token of the response to get the next page. The status filter and search term are applied to each page after it has been fetched, so a page can hold fewer deployments than the limit or none at all while its continue token is still set. Keep following the continue token until it is empty. Sorting cannot be combined with pagination.
 // @Tags deployments
 // @Accept json
 // @Produce json
 // @Param namespace query string false "Namespace filter"
+// @Param limit query int false "Maximum number of deployments per page"
+// @Param continue query string false "Continue token of the previous page"
+// @Param labelSelector query string false "Label selector, e.g. app=web"
+// @Param fieldSelector query string false "Field selector, e.g. metadata.name=web"
+// @Param status query string false "Rollout status filter: progressing, complete or failed"
+// @Param search query string false "Only return deployments whose name contains this term"
+// @Param sort query string false "Sort order: name or age (youngest first), prefix with - to reverse. Not allowed with limit or continue."
+// @Param managedOnly query bool false "Only return deployments created through this API"
+// @Param consistent query bool false "Read from the API server instead of the cache"
 // @Success 200 {object} models.APIResponse{data=[]models.DeploymentResponse}
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 410 {object} models.APIResponse
 // @Failure 500 {object} models.APIResponse
 // @Router /deployments [get]
 func (h *DeploymentHandler) ListDeployments(c *gin.Context) {
-	namespace := c.Query("namespace")
-	if namespace == "" {
-		namespace = corev1.NamespaceAll
+	query, ok := parseListQuery(c, listSpec{
+		kind:    "deployments",
+		status:  true,
+		managed: true,
+		sorts:   []string{sortByName, sortByAge},
+	})
+	if !ok {
+		return
+	}
+	namespace, access, ok := listNamespace(c)
+	if !ok {
+		return
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	deploymentList, err := h.k8sClient.ListDeployments(ctx, namespace)
+	deploymentList, err := kubeClient(c).ListDeployments(ctx, namespace, query.opts)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.APIResponse{
+		c.JSON(statusForError(err), models.APIResponse{
 			Success: false,
 			Error:   fmt.Sprintf("Failed to list deployments: %v", err),
 		})
 		return
 	}
 
-	deployments := make([]models.DeploymentResponse, 0, len(deploymentList.Items))
-	for _, deployment := range deploymentList.Items {
-		deployments = append(deployments, h.deploymentToResponse(&deployment))
+	matching := make([]*appsv1.Deployment, 0, len(deploymentList.Items))
+	for i := range deploymentList.Items {
+		deployment := &deploymentList.Items[i]
+		if access.Allows(deployment.Namespace) && query.matches(deployment.Name, deploymentStatus(deployment)) {
+			matching = append(matching, deployment)
+		}
+	}
+	sortObjects(matching, query, nil)
+
+	deployments := make([]models.DeploymentResponse, 0, len(matching))
+	for _, deployment := range matching {
+		deployments = append(deployments, h.deploymentToResponse(deployment))
 	}
 
 	c.JSON(http.StatusOK, models.APIResponse{
-		Success: true,
-		Data:    deployments,
+		Success:    true,
+		Data:       deployments,
+		Pagination: query.pagination(deploymentList.ListMeta, len(deployments)),
 	})
 }
 
@@ -112,6 +166,7 @@ func (h *DeploymentHandler) ListDeployments(c *gin.Context) {
 // @Produce json
 // @Param namespace path string true "Namespace"
 // @Param name path string true "Deployment name"
+// @Param consistent query bool false "Read from the API server instead of the cache"
 // @Success 200 {object} models.APIResponse{data=models.DeploymentResponse}
 // @Failure 404 {object} models.APIResponse
 // @Router /deployments/{namespace}/{name} [get]
@@ -122,9 +177,9 @@ func (h *DeploymentHandler) GetDeployment(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	deployment, err := h.k8sClient.GetDeployment(ctx, namespace, name)
+	deployment, err := kubeClient(c).GetDeployment(ctx, namespace, name)
 	if err != nil {
-		c.JSON(http.StatusNotFound, models.APIResponse{
+		c.JSON(statusForError(err), models.APIResponse{
 			Success: false,
 			Error:   fmt.Sprintf("Deployment not found: %v", err),
 		})
@@ -155,9 +210,9 @@ func (h *DeploymentHandler) DeleteDeployment(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	err := h.k8sClient.DeleteDeployment(ctx, namespace, name)
+	err := kubeClient(c).DeleteDeployment(ctx, namespace, name)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.APIResponse{
+		c.JSON(statusForError(err), models.APIResponse{
 			Success: false,
 			Error:   fmt.Sprintf("Failed to delete deployment: %v", err),
 		})
@@ -199,9 +254,9 @@ func (h *DeploymentHandler) ScaleDeployment(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	err := h.k8sClient.ScaleDeployment(ctx, namespace, name, replicaCount)
+	err := kubeClient(c).ScaleDeployment(ctx, namespace, name, replicaCount)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.APIResponse{
+		c.JSON(statusForError(err), models.APIResponse{
 			Success: false,
 			Error:   fmt.Sprintf("Failed to scale deployment: %v", err),
 		})
@@ -214,122 +269,552 @@ func (h *DeploymentHandler) ScaleDeployment(c *gin.Context) {
 	})
 }
 
-func (h *DeploymentHandler) buildDeploymentSpec(req *models.DeploymentCreateRequest) *appsv1.Deployment {
-	// Build container ports
-	containerPorts := make([]corev1.ContainerPort, 0, len(req.Ports))
-	for _, port := range req.Ports {
-		protocol := corev1.ProtocolTCP
-		if port.Protocol != "" {
-			protocol = corev1.Protocol(port.Protocol)
+// UpdateDeploymentImage handles changing a deployment's container image
+// @Summary Update deployment image
+// @Description Change the image of a deployment container, triggering a rolling update
+// @Tags deployments
+// @Accept json
+// @Produce json
+// @Param namespace path string true "Namespace"
+// @Param name path string true "Deployment name"
+// @Param request body models.DeploymentImageUpdateRequest true "New image"
+// @Success 200 {object} models.APIResponse{data=models.DeploymentResponse}
+// @Failure 400 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /deployments/{namespace}/{name}/image [put]
+func (h *DeploymentHandler) UpdateDeploymentImage(c *gin.Context) {
+	namespace := c.Param("namespace")
+	name := c.Param("name")
+
+	var req models.DeploymentImageUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Invalid request: %v", err),
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	deployment, err := kubeClient(c).UpdateDeploymentImage(ctx, namespace, name, req.Container, req.Image)
+	if err != nil {
+		c.JSON(statusForError(err), models.APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Failed to update deployment image: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: fmt.Sprintf("Deployment image updated to %s", req.Image),
+		Data:    h.deploymentToResponse(deployment),
+	})
+}
+
+// RollbackDeployment handles rolling a deployment back to a previous revision
+// @Summary Roll back a deployment
+// @Description Restore the pod template of a previous revision. Without a revision, the deployment is rolled back to the revision before the current one.
+// @Tags deployments
+// @Accept json
+// @Produce json
+// @Param namespace path string true "Namespace"
+// @Param name path string true "Deployment name"
+// @Param revision query int false "Revision to roll back to"
+// @Success 200 {object} models.APIResponse{data=models.DeploymentResponse}
+// @Failure 400 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /deployments/{namespace}/{name}/rollback [post]
+func (h *DeploymentHandler) RollbackDeployment(c *gin.Context) {
+	namespace := c.Param("namespace")
+	name := c.Param("name")
+
+	revision := int64(0)
+	if value := c.Query("revision"); value != "" {
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || parsed < 0 {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false,
+				Error:   "Invalid revision number",
+			})
+			return
 		}
-		containerPorts = append(containerPorts, corev1.ContainerPort{
-			Name:          port.Name,
-			ContainerPort: port.ContainerPort,
-			Protocol:      protocol,
+		revision = parsed
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	deployment, err := kubeClient(c).RollbackDeployment(ctx, namespace, name, revision)
+	if err != nil {
+		c.JSON(statusForError(err), models.APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Failed to roll back deployment: %v", err),
 		})
+		return
 	}
 
-	// Build environment variables
-	envVars := make([]corev1.EnvVar, 0, len(req.Env))
-	for _, env := range req.Env {
-		envVars = append(envVars, corev1.EnvVar{
-			Name:  env.Name,
-			Value: env.Value,
+	message := "Deployment rolled back to previous revision"
+	if revision > 0 {
+		message = fmt.Sprintf("Deployment rolled back to revision %d", revision)
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: message,
+		Data:    h.deploymentToResponse(deployment),
+	})
+}
+
+// GetDeploymentHistory handles listing a deployment's rollout history
+// @Summary Get deployment rollout history
+// @Description List the revisions of a deployment, built from the ReplicaSets it owns, newest first
+// @Tags deployments
+// @Accept json
+// @Produce json
+// @Param namespace path string true "Namespace"
+// @Param name path string true "Deployment name"
+// @Param consistent query bool false "Read from the API server instead of the cache"
+// @Success 200 {object} models.APIResponse{data=[]models.DeploymentRevision}
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /deployments/{namespace}/{name}/history [get]
+func (h *DeploymentHandler) GetDeploymentHistory(c *gin.Context) {
+	namespace := c.Param("namespace")
+	name := c.Param("name")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	client := kubeClient(c)
+	deployment, err := client.GetDeployment(ctx, namespace, name)
+	if err != nil {
+		c.JSON(statusForError(err), models.APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Deployment not found: %v", err),
 		})
+		return
 	}
 
-	// Build resource requirements
-	resources := corev1.ResourceRequirements{}
-	if req.Resources.CPU != "" || req.Resources.Memory != "" {
-		resources.Requests = corev1.ResourceList{}
-		resources.Limits = corev1.ResourceList{}
+	replicaSets, err := client.ListDeploymentRevisions(ctx, deployment)
+	if err != nil {
+		c.JSON(statusForError(err), models.APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Failed to get deployment history: %v", err),
+		})
+		return
+	}
 
-		if req.Resources.CPU != "" {
-			resources.Requests[corev1.ResourceCPU] = resource.MustParse(req.Resources.CPU)
-			resources.Limits[corev1.ResourceCPU] = resource.MustParse(req.Resources.CPU)
+	currentRevision := deployment.Annotations[k8s.RevisionAnnotation]
+	history := make([]models.DeploymentRevision, 0, len(replicaSets))
+	for _, rs := range replicaSets {
+		images := make([]string, 0, len(rs.Spec.Template.Spec.Containers))
+		for _, container := range rs.Spec.Template.Spec.Containers {
+			images = append(images, container.Image)
 		}
-		if req.Resources.Memory != "" {
-			resources.Requests[corev1.ResourceMemory] = resource.MustParse(req.Resources.Memory)
-			resources.Limits[corev1.ResourceMemory] = resource.MustParse(req.Resources.Memory)
+
+		replicas := int32(0)
+		if rs.Spec.Replicas != nil {
+			replicas = *rs.Spec.Replicas
 		}
+
+		history = append(history, models.DeploymentRevision{
+			Revision:    k8s.Revision(&rs),
+			ReplicaSet:  rs.Name,
+			Images:      images,
+			Replicas:    replicas,
+			ChangeCause: rs.Annotations[k8s.ChangeCauseAnnotation],
+			Current:     rs.Annotations[k8s.RevisionAnnotation] == currentRevision,
+			CreatedAt:   rs.CreationTimestamp.Format(time.RFC3339),
+		})
 	}
 
-	// Build volume mounts
-	volumeMounts := make([]corev1.VolumeMount, 0, len(req.Volumes))
-	volumes := make([]corev1.Volume, 0, len(req.Volumes))
-	for _, vol := range req.Volumes {
-		volumeMounts = append(volumeMounts, corev1.VolumeMount{
-			Name:      vol.Name,
-			MountPath: vol.MountPath,
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    history,
+	})
+}
+
+// PauseDeployment handles pausing a deployment rollout
+// @Summary Pause a deployment
+// @Description Pause a deployment so template changes are not rolled out
+// @Tags deployments
+// @Accept json
+// @Produce json
+// @Param namespace path string true "Namespace"
+// @Param name path string true "Deployment name"
+// @Success 200 {object} models.APIResponse{data=models.DeploymentResponse}
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /deployments/{namespace}/{name}/pause [post]
+func (h *DeploymentHandler) PauseDeployment(c *gin.Context) {
+	h.rolloutAction(c, "pause", "Deployment paused", k8s.ClusterAPI.PauseDeployment)
+}
+
+// ResumeDeployment handles resuming a paused deployment rollout
+// @Summary Resume a deployment
+// @Description Resume a paused deployment so pending template changes are rolled out
+// @Tags deployments
+// @Accept json
+// @Produce json
+// @Param namespace path string true "Namespace"
+// @Param name path string true "Deployment name"
+// @Success 200 {object} models.APIResponse{data=models.DeploymentResponse}
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /deployments/{namespace}/{name}/resume [post]
+func (h *DeploymentHandler) ResumeDeployment(c *gin.Context) {
+	h.rolloutAction(c, "resume", "Deployment resumed", k8s.ClusterAPI.ResumeDeployment)
+}
+
+// RestartDeployment handles a rolling restart of a deployment
+// @Summary Restart a deployment
+// @Description Trigger a rolling restart of all pods in a deployment
+// @Tags deployments
+// @Accept json
+// @Produce json
+// @Param namespace path string true "Namespace"
+// @Param name path string true "Deployment name"
+// @Success 200 {object} models.APIResponse{data=models.DeploymentResponse}
+// @Failure 400 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /deployments/{namespace}/{name}/restart [post]
+func (h *DeploymentHandler) RestartDeployment(c *gin.Context) {
+	h.rolloutAction(c, "restart", "Deployment restart triggered", k8s.ClusterAPI.RestartDeployment)
+}
+
+// rolloutAction runs a deployment operation that only needs the namespace and name
+func (h *DeploymentHandler) rolloutAction(c *gin.Context, verb, message string, action func(k8s.ClusterAPI, context.Context, string, string) (*appsv1.Deployment, error)) {
+	namespace := c.Param("namespace")
+	name := c.Param("name")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	deployment, err := action(kubeClient(c), ctx, namespace, name)
+	if err != nil {
+		c.JSON(statusForError(err), models.APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Failed to %s deployment: %v", verb, err),
 		})
+		return
+	}
 
-		volumeSource := corev1.VolumeSource{}
-		switch vol.Type {
-		case "emptyDir":
-			volumeSource.EmptyDir = &corev1.EmptyDirVolumeSource{}
-		case "configMap":
-			volumeSource.ConfigMap = &corev1.ConfigMapVolumeSource{
-				LocalObjectReference: corev1.LocalObjectReference{Name: vol.Source},
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: message,
+		Data:    h.deploymentToResponse(deployment),
+	})
+}
+
+// GetDeploymentLogs handles getting the combined logs of a deployment's pods
+// @Summary Get deployment logs
+// @Description Retrieve the logs of every pod selected by a deployment, interleaved by timestamp and prefixed with pod/container. With follow=true the logs are streamed, including pods that start during a rollout. A stream that ends while its container still runs is resumed after the last line sent. At most 50 containers are followed at once; a "warning" event names the ones left out. An "error" event reports that new pods can no longer be watched, for example when the caller may not watch pods. Plain text streams carry these events as lines starting with [warning] or [error].
+// @Tags deployments
+// @Accept json
+// @Produce json
+// @Produce plain
+// @Produce text/event-stream
+// @Param namespace path string true "Namespace"
+// @Param name path string true "Deployment name"
+// @Param tail query int false "Number of lines to tail per container" default(100)
+// @Param follow query bool false "Stream new log lines as they are written"
+// @Param container query string false "Only include containers with this name"
+// @Param previous query bool false "Return logs of the previous terminated container instances"
+// @Param sinceSeconds query int false "Only return logs newer than this many seconds"
+// @Param sinceTime query string false "Only return logs after this RFC3339 timestamp"
+// @Param timestamps query bool false "Keep the RFC3339 timestamp on every line"
+// @Param limitBytes query int false "Maximum number of bytes to return per container"
+// @Success 200 {object} models.APIResponse{data=models.DeploymentLogsResponse}
+// @Failure 400 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /deployments/{namespace}/{name}/logs [get]
+func (h *DeploymentHandler) GetDeploymentLogs(c *gin.Context) {
+	namespace := c.Param("namespace")
+	name := c.Param("name")
+
+	opts, err := podLogOptionsFromQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Invalid request: %v", err),
+		})
+		return
+	}
+
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if opts.Follow {
+		ctx, cancel = context.WithCancel(c.Request.Context())
+	} else {
+		ctx, cancel = context.WithTimeout(context.Background(), 60*time.Second)
+	}
+	defer cancel()
+
+	client := kubeClient(c)
+	deployment, err := client.GetDeployment(ctx, namespace, name)
+	if err != nil {
+		c.JSON(statusForError(err), models.APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Deployment not found: %v", err),
+		})
+		return
+	}
+
+	pods, err := client.ListDeploymentPods(ctx, deployment)
+	if err != nil {
+		c.JSON(statusForError(err), models.APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Failed to list deployment pods: %v", err),
+		})
+		return
+	}
+
+	if opts.Follow {
+		selector, err := metav1.LabelSelectorAsSelector(deployment.Spec.Selector)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.APIResponse{
+				Success: false,
+				Error:   fmt.Sprintf("Invalid deployment selector: %v", err),
+			})
+			return
+		}
+
+		lines := make(chan string)
+		notices := make(chan logNotice)
+		go followLogs(ctx, client, namespace, selector.String(), pods, opts, lines, notices)
+		writeLogStream(c, lines, notices)
+		return
+	}
+
+	logs, errs := collectLogs(ctx, client, namespace, logTargets(pods, opts.Container), opts)
+
+	podNames := make([]string, 0, len(pods))
+	for _, pod := range pods {
+		podNames = append(podNames, pod.Name)
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: models.DeploymentLogsResponse{
+			Logs:   logs,
+			Pods:   podNames,
+			Errors: errs,
+		},
+	})
+}
+
+// StreamRolloutStatus handles streaming the rollout progress of a deployment
+// @Summary Stream deployment rollout status
+// @Description Watch a deployment and its ReplicaSets and pods, streaming progress as Server-Sent Events. "progress" events are sent while the rollout runs, and the stream ends with a single "complete" or "failed" event.
+// @Tags deployments
+// @Produce text/event-stream
+// @Param namespace path string true "Namespace"
+// @Param name path string true "Deployment name"
+// @Param timeout query string false "Maximum time to wait for the rollout, e.g. 5m" default(10m)
+// @Success 200 {object} models.RolloutStatusEvent
+// @Failure 400 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Router /deployments/{namespace}/{name}/rollout-status [get]
+func (h *DeploymentHandler) StreamRolloutStatus(c *gin.Context) {
+	namespace := c.Param("namespace")
+	name := c.Param("name")
+
+	timeout := 10 * time.Minute
+	if value := c.Query("timeout"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false,
+				Error:   "Invalid timeout duration",
+			})
+			return
+		}
+		timeout = parsed
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+	defer cancel()
+
+	updates, err := kubeClient(c).WatchRollout(ctx, namespace, name)
+	if err != nil {
+		c.JSON(statusForError(err), models.APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Failed to watch deployment: %v", err),
+		})
+		return
+	}
+
+	startSSE(c)
+
+	heartbeat := time.NewTicker(sseHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	var last models.RolloutStatusEvent
+	for {
+		select {
+		case update, ok := <-updates:
+			if !ok {
+				h.sendRolloutTimeout(ctx, c, timeout, last)
+				return
 			}
-		case "secret":
-			volumeSource.Secret = &corev1.SecretVolumeSource{
-				SecretName: vol.Source,
+			if update.Err != nil {
+				if apierrors.IsNotFound(update.Err) {
+					last.Status = k8s.RolloutFailed
+					last.Message = fmt.Sprintf("Deployment %q was deleted", name)
+					last.Timestamp = time.Now().Format(time.RFC3339)
+					sendSSE(c, k8s.RolloutFailed, last)
+					return
+				}
+				// Transient API errors are retried on the next watch event or resync
+				continue
 			}
-		case "persistentVolumeClaim":
-			volumeSource.PersistentVolumeClaim = &corev1.PersistentVolumeClaimVolumeSource{
-				ClaimName: vol.Source,
+
+			event := h.rolloutEvent(update.Deployment)
+			if reflect.DeepEqual(event, last) {
+				continue
 			}
+			last = event
+
+			event.Timestamp = time.Now().Format(time.RFC3339)
+			switch event.Status {
+			case k8s.RolloutComplete, k8s.RolloutFailed:
+				sendSSE(c, event.Status, event)
+				return
+			default:
+				sendSSE(c, "progress", event)
+			}
+		case <-heartbeat.C:
+			sendSSEHeartbeat(c)
+		case <-ctx.Done():
+			h.sendRolloutTimeout(ctx, c, timeout, last)
+			return
 		}
+	}
+}
+
+// sendRolloutTimeout ends a rollout status stream with a failure event when the timeout expired.
+// Nothing is sent when the client went away.
+func (h *DeploymentHandler) sendRolloutTimeout(ctx context.Context, c *gin.Context, timeout time.Duration, last models.RolloutStatusEvent) {
+	if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return
+	}
 
-		volumes = append(volumes, corev1.Volume{
-			Name:         vol.Name,
-			VolumeSource: volumeSource,
+	last.Status = k8s.RolloutFailed
+	last.Message = fmt.Sprintf("Timed out after %s waiting for rollout to finish", timeout)
+	last.Timestamp = time.Now().Format(time.RFC3339)
+	sendSSE(c, k8s.RolloutFailed, last)
+}
+
+// rolloutEvent builds a rollout status event from the current state of a deployment
+func (h *DeploymentHandler) rolloutEvent(deployment *appsv1.Deployment) models.RolloutStatusEvent {
+	status, message := k8s.GetRolloutStatus(deployment)
+
+	conditions := make([]models.DeploymentCondition, 0, len(deployment.Status.Conditions))
+	for _, condition := range deployment.Status.Conditions {
+		conditions = append(conditions, models.DeploymentCondition{
+			Type:           string(condition.Type),
+			Status:         string(condition.Status),
+			Reason:         condition.Reason,
+			Message:        condition.Message,
+			LastUpdateTime: condition.LastUpdateTime.Format(time.RFC3339),
 		})
 	}
 
+	return models.RolloutStatusEvent{
+		Status:              status,
+		Message:             message,
+		Generation:          deployment.Generation,
+		ObservedGeneration:  deployment.Status.ObservedGeneration,
+		Replicas:            deployment.Status.Replicas,
+		UpdatedReplicas:     deployment.Status.UpdatedReplicas,
+		ReadyReplicas:       deployment.Status.ReadyReplicas,
+		AvailableReplicas:   deployment.Status.AvailableReplicas,
+		UnavailableReplicas: deployment.Status.UnavailableReplicas,
+		Paused:              deployment.Spec.Paused,
+		Conditions:          conditions,
+	}
+}
+
+func (h *DeploymentHandler) buildDeploymentSpec(req *models.DeploymentCreateRequest) *appsv1apply.DeploymentApplyConfiguration {
+	container := corev1apply.Container().
+		WithName(req.Name).
+		WithImage(req.Image).
+		WithCommand(req.Command...).
+		WithArgs(req.Args...)
+
+	// Add container ports
+	for _, port := range req.Ports {
+		protocol := corev1.ProtocolTCP
+		if port.Protocol != "" {
+			protocol = corev1.Protocol(port.Protocol)
+		}
+		containerPort := corev1apply.ContainerPort().
+			WithContainerPort(port.ContainerPort).
+			WithProtocol(protocol)
+		if port.Name != "" {
+			containerPort.WithName(port.Name)
+		}
+		container.WithPorts(containerPort)
+	}
+
+	// Add environment variables
+	for _, env := range req.Env {
+		container.WithEnv(corev1apply.EnvVar().WithName(env.Name).WithValue(env.Value))
+	}
+
+	if resources := resourceRequirements(req.Resources); resources != nil {
+		container.WithResources(resources)
+	}
+
+	// Add volumes and their mounts
+	podSpec := corev1apply.PodSpec()
+	for _, vol := range req.Volumes {
+		container.WithVolumeMounts(corev1apply.VolumeMount().
+			WithName(vol.Name).
+			WithMountPath(vol.MountPath))
+
+		volume := corev1apply.Volume().WithName(vol.Name)
+		switch vol.Type {
+		case "emptyDir":
+			volume.WithEmptyDir(corev1apply.EmptyDirVolumeSource())
+		case "configMap":
+			volume.WithConfigMap(corev1apply.ConfigMapVolumeSource().WithName(vol.Source))
+		case "secret":
+			volume.WithSecret(corev1apply.SecretVolumeSource().WithSecretName(vol.Source))
+		case "persistentVolumeClaim":
+			volume.WithPersistentVolumeClaim(corev1apply.PersistentVolumeClaimVolumeSource().WithClaimName(vol.Source))
+		}
+		podSpec.WithVolumes(volume)
+	}
+
 	labels := map[string]string{
 		"app":         req.Name,
 		"managed-by":  "kube-deploy",
 		"deployed-at": time.Now().Format("2006-01-02"),
 	}
 
-	deployment := &appsv1.Deployment{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      req.Name,
-			Namespace: req.Namespace,
-			Labels:    labels,
-		},
-		Spec: appsv1.DeploymentSpec{
-			Replicas: &req.Replicas,
-			Selector: &metav1.LabelSelector{
-				MatchLabels: map[string]string{
-					"app": req.Name,
-				},
-			},
-			Template: corev1.PodTemplateSpec{
-				ObjectMeta: metav1.ObjectMeta{
-					Labels: labels,
-				},
-				Spec: corev1.PodSpec{
-					Containers: []corev1.Container{
-						{
-							Name:         req.Name,
-							Image:        req.Image,
-							Ports:        containerPorts,
-							Env:          envVars,
-							Resources:    resources,
-							VolumeMounts: volumeMounts,
-							Command:      req.Command,
-							Args:         req.Args,
-						},
-					},
-					Volumes: volumes,
-				},
-			},
-		},
-	}
+	return appsv1apply.Deployment(req.Name, req.Namespace).
+		WithLabels(labels).
+		WithSpec(appsv1apply.DeploymentSpec().
+			WithReplicas(req.Replicas).
+			WithSelector(metav1apply.LabelSelector().
+				WithMatchLabels(map[string]string{"app": req.Name})).
+			WithTemplate(corev1apply.PodTemplateSpec().
+				WithLabels(labels).
+				WithSpec(podSpec.WithContainers(container))))
+}
 
-	return deployment
+// deploymentStatus returns the rollout status of a deployment: progressing, complete or failed
+func deploymentStatus(deployment *appsv1.Deployment) string {
+	status, _ := k8s.GetRolloutStatus(deployment)
+	return status
 }
 
 func (h *DeploymentHandler) deploymentToResponse(deployment *appsv1.Deployment) models.DeploymentResponse {
@@ -349,6 +834,7 @@ func (h *DeploymentHandler) deploymentToResponse(deployment *appsv1.Deployment)
 		Replicas:          replicas,
 		AvailableReplicas: deployment.Status.AvailableReplicas,
 		ReadyReplicas:     deployment.Status.ReadyReplicas,
+		Status:            deploymentStatus(deployment),
 		CreatedAt:         deployment.CreationTimestamp.Format(time.RFC3339),
 		Image:             image,
 		Labels:            deployment.Labels,
@@ -0,0 +1,303 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kube-deploy/backend/internal/models"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type EventHandler struct{}
+
+func NewEventHandler() *EventHandler {
+	return &EventHandler{}
+}
+
+// ListEvents handles listing events
+// @Summary List events
+// @Description Get deduplicated, time-sorted events, optionally limited to a namespace and an involved object. With a limit the events are returned a page at a time, pass the continue token of the response to get the next page. Repeated events are only merged within a page.
+// @Tags events
+// @Accept json
+// @Produce json
+// @Param namespace query string false "Namespace filter"
+// @Param kind query string false "Involved object kind, e.g. Pod"
+// @Param name query string false "Involved object name"
+// @Param limit query int false "Maximum number of events per page, before merging"
+// @Param continue query string false "Continue token of the previous page"
+// @Param labelSelector query string false "Label selector"
+// @Param fieldSelector query string false "Field selector, e.g. reason=BackOff"
+// @Param status query string false "Event type filter: Normal or Warning"
+// @Param search query string false "Only return events of objects whose name contains this term"
+// @Param consistent query bool false "Read from the API server instead of the cache"
+// @Success 200 {object} models.APIResponse{data=[]models.EventResponse}
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 410 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /events [get]
+func (h *EventHandler) ListEvents(c *gin.Context) {
+	query, ok := parseListQuery(c, listSpec{kind: "events", status: true})
+	if !ok {
+		return
+	}
+	namespace, access, ok := listNamespace(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	eventList, err := kubeClient(c).ListEvents(ctx, namespace, c.Query("kind"), c.Query("name"), query.opts)
+	if err != nil {
+		c.JSON(statusForError(err), models.APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Failed to list events: %v", err),
+		})
+		return
+	}
+
+	events := make([]corev1.Event, 0, len(eventList.Items))
+	for _, event := range eventList.Items {
+		if access.Allows(event.Namespace) && query.matches(event.InvolvedObject.Name, event.Type) {
+			events = append(events, event)
+		}
+	}
+
+	response := eventsToResponse(events)
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success:    true,
+		Data:       response,
+		Pagination: query.pagination(eventList.ListMeta, len(response)),
+	})
+}
+
+// ListPodEvents handles listing the events of a pod
+// @Summary List pod events
+// @Description Get deduplicated, time-sorted events for a specific pod
+// @Tags pods
+// @Accept json
+// @Produce json
+// @Param namespace path string true "Namespace"
+// @Param name path string true "Pod name"
+// @Param consistent query bool false "Read from the API server instead of the cache"
+// @Success 200 {object} models.APIResponse{data=[]models.EventResponse}
+// @Failure 500 {object} models.APIResponse
+// @Router /pods/{namespace}/{name}/events [get]
+func (h *EventHandler) ListPodEvents(c *gin.Context) {
+	h.listEvents(c, c.Param("namespace"), "Pod", c.Param("name"))
+}
+
+// ListServiceEvents handles listing the events of a service
+// @Summary List service events
+// @Description Get deduplicated, time-sorted events for a specific service
+// @Tags services
+// @Accept json
+// @Produce json
+// @Param namespace path string true "Namespace"
+// @Param name path string true "Service name"
+// @Param consistent query bool false "Read from the API server instead of the cache"
+// @Success 200 {object} models.APIResponse{data=[]models.EventResponse}
+// @Failure 500 {object} models.APIResponse
+// @Router /services/{namespace}/{name}/events [get]
+func (h *EventHandler) ListServiceEvents(c *gin.Context) {
+	h.listEvents(c, c.Param("namespace"), "Service", c.Param("name"))
+}
+
+// ListDeploymentEvents handles listing the events of a deployment
+// @Summary List deployment events
+// @Description Get deduplicated, time-sorted events for a deployment together with its ReplicaSets and pods
+// @Tags deployments
+// @Accept json
+// @Produce json
+// @Param namespace path string true "Namespace"
+// @Param name path string true "Deployment name"
+// @Param consistent query bool false "Read from the API server instead of the cache"
+// @Success 200 {object} models.APIResponse{data=[]models.EventResponse}
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /deployments/{namespace}/{name}/events [get]
+func (h *EventHandler) ListDeploymentEvents(c *gin.Context) {
+	namespace := c.Param("namespace")
+	name := c.Param("name")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	client := kubeClient(c)
+	deployment, err := client.GetDeployment(ctx, namespace, name)
+	if err != nil {
+		c.JSON(statusForError(err), models.APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Deployment not found: %v", err),
+		})
+		return
+	}
+
+	// Most rollout problems are reported on the ReplicaSets and pods rather than the deployment
+	involved := map[string]bool{"Deployment/" + name: true}
+
+	replicaSets, err := client.ListDeploymentRevisions(ctx, deployment)
+	if err != nil {
+		c.JSON(statusForError(err), models.APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Failed to list events: %v", err),
+		})
+		return
+	}
+	for _, rs := range replicaSets {
+		involved["ReplicaSet/"+rs.Name] = true
+	}
+
+	pods, err := client.ListDeploymentPods(ctx, deployment)
+	if err != nil {
+		c.JSON(statusForError(err), models.APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Failed to list events: %v", err),
+		})
+		return
+	}
+	for _, pod := range pods {
+		involved["Pod/"+pod.Name] = true
+	}
+
+	eventList, err := client.ListEvents(ctx, namespace, "", "", metav1.ListOptions{})
+	if err != nil {
+		c.JSON(statusForError(err), models.APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Failed to list events: %v", err),
+		})
+		return
+	}
+
+	events := make([]corev1.Event, 0, len(eventList.Items))
+	for _, event := range eventList.Items {
+		if involved[event.InvolvedObject.Kind+"/"+event.InvolvedObject.Name] {
+			events = append(events, event)
+		}
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    eventsToResponse(events),
+	})
+}
+
+// listEvents responds with the events matching a namespace, kind and name
+func (h *EventHandler) listEvents(c *gin.Context, namespace, kind, name string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	eventList, err := kubeClient(c).ListEvents(ctx, namespace, kind, name, metav1.ListOptions{})
+	if err != nil {
+		c.JSON(statusForError(err), models.APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Failed to list events: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    eventsToResponse(eventList.Items),
+	})
+}
+
+// eventsToResponse merges repeated events and sorts them by the time they were last seen,
+// oldest first
+func eventsToResponse(events []corev1.Event) []models.EventResponse {
+	type mergedEvent struct {
+		response  models.EventResponse
+		firstSeen time.Time
+		lastSeen  time.Time
+	}
+
+	merged := make(map[string]*mergedEvent)
+	order := make([]string, 0, len(events))
+	for _, event := range events {
+		firstSeen, lastSeen, count := eventTimes(&event)
+
+		key := fmt.Sprintf("%s/%s/%s/%s/%s/%s", event.InvolvedObject.Namespace, event.InvolvedObject.Kind,
+			event.InvolvedObject.Name, event.Type, event.Reason, event.Message)
+		if existing, ok := merged[key]; ok {
+			existing.response.Count += count
+			if firstSeen.Before(existing.firstSeen) {
+				existing.firstSeen = firstSeen
+			}
+			if lastSeen.After(existing.lastSeen) {
+				existing.lastSeen = lastSeen
+			}
+			continue
+		}
+
+		source := event.Source.Component
+		if source == "" {
+			source = event.ReportingController
+		}
+
+		merged[key] = &mergedEvent{
+			response: models.EventResponse{
+				Type:      event.Type,
+				Reason:    event.Reason,
+				Message:   event.Message,
+				Count:     count,
+				Kind:      event.InvolvedObject.Kind,
+				Name:      event.InvolvedObject.Name,
+				Namespace: event.InvolvedObject.Namespace,
+				Source:    source,
+			},
+			firstSeen: firstSeen,
+			lastSeen:  lastSeen,
+		}
+		order = append(order, key)
+	}
+
+	sorted := make([]*mergedEvent, 0, len(order))
+	for _, key := range order {
+		sorted = append(sorted, merged[key])
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].lastSeen.Before(sorted[j].lastSeen)
+	})
+
+	response := make([]models.EventResponse, 0, len(sorted))
+	for _, event := range sorted {
+		event.response.FirstSeen = event.firstSeen.Format(time.RFC3339)
+		event.response.LastSeen = event.lastSeen.Format(time.RFC3339)
+		response = append(response, event.response)
+	}
+	return response
+}
+
+// eventTimes returns when an event was first and last seen and how often it occurred.
+// Newer components report through EventTime and Series instead of the legacy timestamps.
+func eventTimes(event *corev1.Event) (time.Time, time.Time, int32) {
+	firstSeen := event.FirstTimestamp.Time
+	if firstSeen.IsZero() {
+		firstSeen = event.EventTime.Time
+	}
+	if firstSeen.IsZero() {
+		firstSeen = event.CreationTimestamp.Time
+	}
+
+	lastSeen := event.LastTimestamp.Time
+	count := event.Count
+	if event.Series != nil {
+		lastSeen = event.Series.LastObservedTime.Time
+		count = event.Series.Count
+	}
+	if lastSeen.IsZero() {
+		lastSeen = firstSeen
+	}
+	if count == 0 {
+		count = 1
+	}
+
+	return firstSeen, lastSeen, count
+}
@@ -0,0 +1,259 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/kube-deploy/backend/internal/k8s"
+	"github.com/kube-deploy/backend/internal/models"
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+const (
+	// execPingInterval is how often the server pings an idle terminal connection
+	execPingInterval = 30 * time.Second
+	// execPongWait is how long the server waits for a pong before dropping the connection
+	execPongWait = 60 * time.Second
+)
+
+type ExecHandler struct {
+	upgrader websocket.Upgrader
+}
+
+func NewExecHandler(allowedOrigins []string) *ExecHandler {
+	return &ExecHandler{
+		upgrader: newUpgrader(allowedOrigins),
+	}
+}
+
+// newUpgrader creates a WebSocket upgrader that accepts connections from the allowed browser
+// origins and from clients that send no origin
+func newUpgrader(allowedOrigins []string) websocket.Upgrader {
+	origins := make(map[string]bool, len(allowedOrigins))
+	for _, origin := range allowedOrigins {
+		origins[origin] = true
+	}
+
+	return websocket.Upgrader{
+		ReadBufferSize:  4096,
+		WriteBufferSize: 4096,
+		CheckOrigin: func(r *http.Request) bool {
+			origin := r.Header.Get("Origin")
+			return origin == "" || origins[origin]
+		},
+	}
+}
+
+// ExecPod handles an interactive terminal session in a pod
+// @Summary Open a terminal in a pod
+// @Description Upgrade to a WebSocket and run a command in a pod container. Client messages are JSON objects of type "stdin" (with data) or "resize" (with cols and rows). The server sends "stdout", "stderr", "error" and a final "exit" message with the exit code. Browsers may pass the JWT in the token query parameter.
+// @Tags pods
+// @Security BearerAuth
+// @Param namespace path string true "Namespace"
+// @Param name path string true "Pod name"
+// @Param container query string false "Container name, defaults to the pod's only container"
+// @Param command query []string false "Command to run" collectionFormat(multi) default(/bin/sh)
+// @Param tty query bool false "Allocate a TTY" default(true)
+// @Success 101 {string} string "Switching Protocols"
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Router /pods/{namespace}/{name}/exec [get]
+func (h *ExecHandler) ExecPod(c *gin.Context) {
+	namespace := c.Param("namespace")
+	name := c.Param("name")
+
+	command := c.QueryArray("command")
+	if len(command) == 0 {
+		command = []string{"/bin/sh"}
+	}
+
+	tty := true
+	if c.Query("tty") != "" {
+		parsed, err := boolQuery(c, "tty")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false,
+				Error:   fmt.Sprintf("Invalid request: %v", err),
+			})
+			return
+		}
+		tty = parsed
+	}
+
+	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// The upgrader has already written an error response
+		return
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	session := newExecSession(conn)
+	stdinReader, stdinWriter := io.Pipe()
+	sizes := newTerminalSizeQueue(ctx)
+
+	go session.readLoop(cancel, stdinWriter, sizes)
+	go session.pingLoop(ctx)
+
+	exitCode, err := kubeClient(c).ExecPod(ctx, namespace, name, k8s.ExecOptions{
+		Container: c.Query("container"),
+		Command:   command,
+		Stdin:     stdinReader,
+		Stdout:    session.writer("stdout"),
+		Stderr:    session.writer("stderr"),
+		TTY:       tty,
+		Resize:    sizes,
+	})
+	stdinReader.Close()
+
+	if err != nil && ctx.Err() == nil {
+		session.send(execMessage{Type: "error", Data: err.Error()})
+	}
+	session.send(execMessage{Type: "exit", Code: exitCode})
+	session.close()
+}
+
+// execMessage is the JSON frame exchanged with the terminal client
+type execMessage struct {
+	Type string `json:"type"`
+	Data string `json:"data,omitempty"`
+	Cols uint16 `json:"cols,omitempty"`
+	Rows uint16 `json:"rows,omitempty"`
+	Code int    `json:"code"`
+}
+
+// execSession serializes writes to a terminal WebSocket connection
+type execSession struct {
+	conn *websocket.Conn
+	mu   sync.Mutex
+}
+
+func newExecSession(conn *websocket.Conn) *execSession {
+	return &execSession{conn: conn}
+}
+
+func (s *execSession) send(msg execMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	return s.conn.WriteJSON(msg)
+}
+
+func (s *execSession) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+		time.Now().Add(time.Second))
+}
+
+// writer returns an io.Writer that forwards output of the given stream to the client
+func (s *execSession) writer(stream string) io.Writer {
+	return execWriter{session: s, stream: stream}
+}
+
+// readLoop reads client messages until the connection closes, feeding stdin and resize events.
+// The exec is cancelled when the client goes away.
+func (s *execSession) readLoop(cancel context.CancelFunc, stdin *io.PipeWriter, sizes *terminalSizeQueue) {
+	defer cancel()
+	defer stdin.Close()
+
+	s.conn.SetReadDeadline(time.Now().Add(execPongWait))
+	s.conn.SetPongHandler(func(string) error {
+		return s.conn.SetReadDeadline(time.Now().Add(execPongWait))
+	})
+
+	for {
+		var msg execMessage
+		if err := s.conn.ReadJSON(&msg); err != nil {
+			return
+		}
+		s.conn.SetReadDeadline(time.Now().Add(execPongWait))
+
+		switch msg.Type {
+		case "stdin":
+			if _, err := stdin.Write([]byte(msg.Data)); err != nil {
+				return
+			}
+		case "resize":
+			if msg.Cols > 0 && msg.Rows > 0 {
+				sizes.push(remotecommand.TerminalSize{Width: msg.Cols, Height: msg.Rows})
+			}
+		}
+	}
+}
+
+// pingLoop keeps the connection alive and detects dead clients
+func (s *execSession) pingLoop(ctx context.Context) {
+	ticker := time.NewTicker(execPingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.mu.Lock()
+			err := s.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(10*time.Second))
+			s.mu.Unlock()
+			if err != nil {
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// execWriter sends everything written to it as a stdout or stderr message
+type execWriter struct {
+	session *execSession
+	stream  string
+}
+
+func (w execWriter) Write(p []byte) (int, error) {
+	if err := w.session.send(execMessage{Type: w.stream, Data: string(p)}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// terminalSizeQueue hands terminal resize events to the exec stream
+type terminalSizeQueue struct {
+	ctx   context.Context
+	sizes chan remotecommand.TerminalSize
+}
+
+func newTerminalSizeQueue(ctx context.Context) *terminalSizeQueue {
+	return &terminalSizeQueue{ctx: ctx, sizes: make(chan remotecommand.TerminalSize, 1)}
+}
+
+// push queues a new size, replacing any size that has not been picked up yet
+func (q *terminalSizeQueue) push(size remotecommand.TerminalSize) {
+	select {
+	case <-q.sizes:
+	default:
+	}
+	select {
+	case q.sizes <- size:
+	default:
+	}
+}
+
+// Next implements remotecommand.TerminalSizeQueue, returning nil once the session ends
+func (q *terminalSizeQueue) Next() *remotecommand.TerminalSize {
+	select {
+	case size := <-q.sizes:
+		return &size
+	case <-q.ctx.Done():
+		return nil
+	}
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kube-deploy/backend/internal/k8s"
+	"github.com/kube-deploy/backend/internal/middleware"
+	"github.com/kube-deploy/backend/internal/models"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// writeOptionsFromQuery reads the apply and dryRun query parameters
+func writeOptionsFromQuery(c *gin.Context) (k8s.WriteOptions, error) {
+	var opts k8s.WriteOptions
+
+	if apply := c.Query("apply"); apply != "" {
+		value, err := strconv.ParseBool(apply)
+		if err != nil {
+			return opts, fmt.Errorf("invalid apply value %q", apply)
+		}
+		opts.Apply = value
+	}
+
+	if dryRun := c.Query("dryRun"); dryRun != "" {
+		value, err := strconv.ParseBool(dryRun)
+		if err != nil {
+			return opts, fmt.Errorf("invalid dryRun value %q", dryRun)
+		}
+		opts.DryRun = value
+	}
+
+	return opts, nil
+}
+
+// writeResult picks the status code and message verb for a successful write
+func writeResult(opts k8s.WriteOptions, kind string) (int, string) {
+	switch {
+	case opts.DryRun:
+		return http.StatusOK, fmt.Sprintf("%s validated successfully (dry run)", kind)
+	case opts.Apply:
+		return http.StatusOK, fmt.Sprintf("%s applied successfully", kind)
+	default:
+		return http.StatusCreated, fmt.Sprintf("%s created successfully", kind)
+	}
+}
+
+// statusForError maps a Kubernetes API error onto an HTTP status code
+func statusForError(err error) int {
+	switch {
+	case apierrors.IsNotFound(err):
+		return http.StatusNotFound
+	case apierrors.IsAlreadyExists(err), apierrors.IsConflict(err):
+		return http.StatusConflict
+	case apierrors.IsResourceExpired(err), apierrors.IsGone(err):
+		return http.StatusGone
+	case apierrors.IsInvalid(err):
+		return http.StatusUnprocessableEntity
+	case apierrors.IsBadRequest(err):
+		return http.StatusBadRequest
+	case apierrors.IsForbidden(err):
+		return http.StatusForbidden
+	case apierrors.IsUnauthorized(err):
+		return http.StatusUnauthorized
+	case errors.Is(err, k8s.ErrNotSupported):
+		return http.StatusNotImplemented
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// requireNamespace responds with 403 and returns false when the caller may not use namespace
+func requireNamespace(c *gin.Context, namespace string) bool {
+	if middleware.GetNamespaceAccess(c).Allows(namespace) {
+		return true
+	}
+
+	c.JSON(http.StatusForbidden, models.APIResponse{
+		Success: false,
+		Error:   fmt.Sprintf("You do not have access to namespace %s", namespace),
+	})
+	return false
+}
+
+// listNamespace returns the namespace filter of a list request, all namespaces when none is
+// given. It responds with 403 and returns false when the caller may not use the namespace.
+// Results listed across all namespaces must still be filtered with the caller's access.
+func listNamespace(c *gin.Context) (string, middleware.NamespaceAccess, bool) {
+	access := middleware.GetNamespaceAccess(c)
+
+	namespace := c.Query("namespace")
+	if namespace == "" {
+		return corev1.NamespaceAll, access, true
+	}
+	if !requireNamespace(c, namespace) {
+		return "", access, false
+	}
+	return namespace, access, true
+}
+
+// kubeClient returns the Kubernetes client for the request's cluster, which impersonates the
+// caller when impersonation is enabled
+func kubeClient(c *gin.Context) k8s.ClusterAPI {
+	return middleware.GetKubernetesClient(c)
+}
@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"cmp"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kube-deploy/backend/internal/models"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	// maxListLimit caps the page size of list endpoints
+	maxListLimit = 1000
+	// managedBySelector matches the objects created through the API
+	managedBySelector = "managed-by=kube-deploy"
+)
+
+// Sort orders of list endpoints. Prefixing one with - reverses it.
+const (
+	sortByName = "name"
+	// sortByAge puts the youngest objects first
+	sortByAge = "age"
+	// sortByRestarts puts the pods with the fewest restarts first
+	sortByRestarts = "restarts"
+)
+
+// listSpec declares the filters and sort orders a list endpoint supports on top of pagination,
+// selectors and search
+type listSpec struct {
+	kind string // Plural kind, used in error messages
+	// status is set when objects can be filtered by status
+	status bool
+	// managed is set when objects can be limited to those created through the API
+	managed bool
+	sorts   []string
+}
+
+// listQuery holds the pagination, selector, filter and sort query parameters of a list request
+type listQuery struct {
+	// opts is passed to the API server, which pages and applies the selectors
+	opts metav1.ListOptions
+	// status and search are applied to every page afterwards
+	status     string
+	search     string
+	sort       string
+	descending bool
+}
+
+// parseListQuery reads the query parameters of a list request. It responds with 400 and
+// returns false when one is invalid or not supported by the endpoint.
+func parseListQuery(c *gin.Context, spec listSpec) (listQuery, bool) {
+	query, err := listQueryFromRequest(c, spec)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Invalid request: %v", err),
+		})
+		return query, false
+	}
+	return query, true
+}
+
+func listQueryFromRequest(c *gin.Context, spec listSpec) (listQuery, error) {
+	query := listQuery{
+		opts: metav1.ListOptions{
+			Continue:      c.Query("continue"),
+			LabelSelector: strings.TrimSpace(c.Query("labelSelector")),
+			FieldSelector: strings.TrimSpace(c.Query("fieldSelector")),
+		},
+		status: strings.TrimSpace(c.Query("status")),
+		search: strings.ToLower(strings.TrimSpace(c.Query("search"))),
+	}
+
+	if _, err := labels.Parse(query.opts.LabelSelector); err != nil {
+		return query, fmt.Errorf("invalid label selector: %v", err)
+	}
+	// Parse errors of field selectors already say so
+	if _, err := fields.ParseSelector(query.opts.FieldSelector); err != nil {
+		return query, err
+	}
+
+	if value := c.Query("limit"); value != "" {
+		limit, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || limit <= 0 {
+			return query, fmt.Errorf("limit must be a positive number")
+		}
+		query.opts.Limit = min(limit, maxListLimit)
+	}
+
+	if query.status != "" && !spec.status {
+		return query, fmt.Errorf("%s cannot be filtered by status", spec.kind)
+	}
+
+	if value := c.Query("managedOnly"); value != "" {
+		managedOnly, err := strconv.ParseBool(value)
+		if err != nil {
+			return query, fmt.Errorf("managedOnly must be true or false")
+		}
+		if managedOnly {
+			if !spec.managed {
+				return query, fmt.Errorf("%s cannot be limited to managed objects", spec.kind)
+			}
+			query.opts.LabelSelector = strings.TrimPrefix(query.opts.LabelSelector+","+managedBySelector, ",")
+		}
+	}
+
+	if value := c.Query("sort"); value != "" {
+		field := strings.TrimPrefix(value, "-")
+		if !slices.Contains(spec.sorts, field) {
+			if len(spec.sorts) == 0 {
+				return query, fmt.Errorf("%s cannot be sorted", spec.kind)
+			}
+			return query, fmt.Errorf("%s cannot be sorted by %q, expected one of %s", spec.kind, field, strings.Join(spec.sorts, ", "))
+		}
+		// Sorting one page at a time would order objects differently from one page to the next
+		if query.opts.Limit > 0 || query.opts.Continue != "" {
+			return query, fmt.Errorf("sort cannot be combined with limit or continue")
+		}
+		query.sort = field
+		query.descending = strings.HasPrefix(value, "-")
+	}
+
+	return query, nil
+}
+
+// matches reports whether an object passes the search and status filters. The search term
+// matches part of the name, and the status matches regardless of case.
+func (q listQuery) matches(name, status string) bool {
+	if q.search != "" && !strings.Contains(strings.ToLower(name), q.search) {
+		return false
+	}
+	return q.status == "" || strings.EqualFold(status, q.status)
+}
+
+// pagination describes a page of count objects listed with the query
+func (q listQuery) pagination(list metav1.ListMeta, count int) *models.Pagination {
+	return &models.Pagination{
+		Limit:              q.opts.Limit,
+		Continue:           list.Continue,
+		RemainingItemCount: list.RemainingItemCount,
+		Count:              count,
+	}
+}
+
+// sortObjects orders objects by the sort order of the query and leaves them in the order of
+// the API server, by namespace and name, when there is none. restarts is only called for the
+// restarts order.
+func sortObjects[P metav1.Object](objects []P, query listQuery, restarts func(P) int32) {
+	var compare func(a, b P) int
+	switch query.sort {
+	case sortByName:
+		compare = func(a, b P) int {
+			return cmp.Or(strings.Compare(a.GetName(), b.GetName()), strings.Compare(a.GetNamespace(), b.GetNamespace()))
+		}
+	case sortByAge:
+		compare = func(a, b P) int {
+			return b.GetCreationTimestamp().Compare(a.GetCreationTimestamp().Time)
+		}
+	case sortByRestarts:
+		compare = func(a, b P) int {
+			return cmp.Compare(restarts(a), restarts(b))
+		}
+	default:
+		return
+	}
+
+	slices.SortStableFunc(objects, func(a, b P) int {
+		if query.descending {
+			return compare(b, a)
+		}
+		return compare(a, b)
+	})
+}
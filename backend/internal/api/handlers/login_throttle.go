@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kube-deploy/backend/internal/database"
+	"github.com/kube-deploy/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// lockoutResource is the audit log resource of account and IP lockouts
+const lockoutResource = "lockouts"
+
+// userThrottleKey is the login throttle key of an account
+func userThrottleKey(userID uint) string {
+	return fmt.Sprintf("user:%d", userID)
+}
+
+// emailThrottleKey is the login throttle key of an email address without an account. The
+// address is hashed, so that arbitrary input is not stored.
+func emailThrottleKey(email string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(email)))
+	return "email:" + hex.EncodeToString(sum[:16])
+}
+
+// ipThrottleKey is the login throttle key of a client IP
+func ipThrottleKey(ip string) string {
+	return "ip:" + ip
+}
+
+// loginAllowed responds with 429 Too Many Requests when logins for any of the throttle keys
+// are blocked
+func (h *AuthHandler) loginAllowed(c *gin.Context, keys ...string) bool {
+	blockedUntil, err := database.LoginBlockedUntil(keys...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to check login attempts",
+		})
+		return false
+	}
+
+	if wait := time.Until(blockedUntil); wait > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		c.JSON(http.StatusTooManyRequests, models.APIResponse{
+			Success: false,
+			Error:   "Too many failed login attempts, please try again later",
+		})
+		return false
+	}
+	return true
+}
+
+// recordLoginFailure counts a failed login for the client IP and for the account's throttle
+// key. Email addresses without an account are throttled like accounts, with a nil user, so
+// that delays and lockouts do not reveal which addresses have one.
+func (h *AuthHandler) recordLoginFailure(c *gin.Context, accountKey string, user *models.User) {
+	h.throttleFailure(c, ipThrottleKey(c.ClientIP()), h.throttle.IPMaxFailures, nil)
+	h.throttleFailure(c, accountKey, h.throttle.MaxFailures, user)
+}
+
+// throttleFailure delays the next login for a throttle key exponentially with the number of
+// failures, and locks it out once there are maxFailures of them
+func (h *AuthHandler) throttleFailure(c *gin.Context, key string, maxFailures int, user *models.User) {
+	failures, err := database.RecordLoginFailure(key, h.throttle.LockoutDuration)
+	if err != nil {
+		log.Printf("Failed to record failed login for %s: %v", key, err)
+		return
+	}
+
+	if failures >= maxFailures {
+		lockedUntil := time.Now().Add(h.throttle.LockoutDuration)
+		if err := database.BlockLogins(key, lockedUntil, true); err != nil {
+			log.Printf("Failed to lock out %s: %v", key, err)
+			return
+		}
+		h.auditLockout(c, user, failures, lockedUntil)
+		return
+	}
+
+	// The shift is capped so that large failure limits cannot overflow the delay
+	delay := min(h.throttle.BackoffBase<<min(failures-1, 30), h.throttle.LockoutDuration)
+	if err := database.BlockLogins(key, time.Now().Add(delay), false); err != nil {
+		log.Printf("Failed to delay logins for %s: %v", key, err)
+	}
+}
+
+// auditLockout records a lockout in the audit log, so that admins can find and unlock the
+// account. IP lockouts have no user.
+func (h *AuthHandler) auditLockout(c *gin.Context, user *models.User, failures int, lockedUntil time.Time) {
+	details, _ := json.Marshal(map[string]interface{}{
+		"failures":     failures,
+		"locked_until": lockedUntil.Format(time.RFC3339),
+	})
+
+	entry := models.AuditEntry{
+		Method:      c.Request.Method,
+		Route:       c.FullPath(),
+		Path:        c.Request.URL.Path,
+		Resource:    lockoutResource,
+		Name:        c.ClientIP(),
+		RequestBody: string(details),
+		Status:      http.StatusUnauthorized,
+		ClientIP:    c.ClientIP(),
+	}
+	if user != nil {
+		entry.UserID = user.ID
+		entry.Username = user.Username
+		entry.Name = user.Username
+	}
+
+	log.Printf("Locked out %s after %d failed logins until %s", entry.Name, failures, lockedUntil.Format(time.RFC3339))
+	if err := database.GetDB().Create(&entry).Error; err != nil {
+		log.Printf("Failed to record lockout of %s: %v", entry.Name, err)
+	}
+}
+
+// recordLogin stores the time and client IP of a successful login on the user
+func recordLogin(c *gin.Context, db *gorm.DB, user *models.User) {
+	now := time.Now()
+	user.LastLoginAt = &now
+	user.LastLoginIP = c.ClientIP()
+	if err := db.Model(user).Select("last_login_at", "last_login_ip").Updates(user).Error; err != nil {
+		log.Printf("Failed to record login of user %d: %v", user.ID, err)
+	}
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kube-deploy/backend/internal/config"
+	"github.com/kube-deploy/backend/internal/models"
+)
+
+// login posts a password login and returns the response status
+func login(h *AuthHandler, email, password string) int {
+	body, _ := json.Marshal(models.LoginRequest{Email: email, Password: password})
+
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/auth/login", bytes.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	h.Login(c)
+	return recorder.Code
+}
+
+func TestLoginLockoutDoesNotRevealAccounts(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := newTestDB(t)
+	if err := db.AutoMigrate(&models.LoginThrottle{}, &models.AuditEntry{}); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+
+	user := models.User{Email: "alice@example.com", Username: "alice", Role: models.RoleViewer, Active: true}
+	if err := user.HashPassword("password123"); err != nil {
+		t.Fatalf("failed to hash password: %v", err)
+	}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+
+	h := NewAuthHandler(true, config.LoginThrottleConfig{
+		MaxFailures:     3,
+		IPMaxFailures:   100,
+		LockoutDuration: time.Minute,
+	}, config.OIDCConfig{})
+
+	for _, email := range []string{"alice@example.com", "nobody@example.com"} {
+		var statuses []int
+		for range 4 {
+			statuses = append(statuses, login(h, email, "wrong-password"))
+		}
+		want := []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusUnauthorized, http.StatusTooManyRequests}
+		for i := range want {
+			if statuses[i] != want[i] {
+				t.Errorf("%s: expected statuses %v, got %v", email, want, statuses)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,508 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kube-deploy/backend/internal/k8s"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// podLogOptionsFromQuery maps the log query parameters onto PodLogOptions
+func podLogOptionsFromQuery(c *gin.Context) (*corev1.PodLogOptions, error) {
+	opts := &corev1.PodLogOptions{
+		Container: c.Query("container"),
+	}
+
+	tailLines := int64(100) // default
+	if tail := c.Query("tail"); tail != "" {
+		t, err := strconv.ParseInt(tail, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tail value %q", tail)
+		}
+		tailLines = t
+	}
+	if tailLines > 0 {
+		opts.TailLines = &tailLines
+	}
+
+	var err error
+	if opts.Follow, err = boolQuery(c, "follow"); err != nil {
+		return nil, err
+	}
+	if opts.Previous, err = boolQuery(c, "previous"); err != nil {
+		return nil, err
+	}
+	if opts.Timestamps, err = boolQuery(c, "timestamps"); err != nil {
+		return nil, err
+	}
+
+	if value := c.Query("sinceSeconds"); value != "" {
+		seconds, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || seconds <= 0 {
+			return nil, fmt.Errorf("invalid sinceSeconds value %q", value)
+		}
+		opts.SinceSeconds = &seconds
+	}
+
+	if value := c.Query("sinceTime"); value != "" {
+		sinceTime, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sinceTime value %q, expected RFC3339", value)
+		}
+		opts.SinceTime = &metav1.Time{Time: sinceTime}
+	}
+
+	if opts.SinceSeconds != nil && opts.SinceTime != nil {
+		return nil, fmt.Errorf("sinceSeconds and sinceTime cannot be used together")
+	}
+
+	if value := c.Query("limitBytes"); value != "" {
+		limit, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || limit <= 0 {
+			return nil, fmt.Errorf("invalid limitBytes value %q", value)
+		}
+		opts.LimitBytes = &limit
+	}
+
+	return opts, nil
+}
+
+// boolQuery parses an optional boolean query parameter
+func boolQuery(c *gin.Context, key string) (bool, error) {
+	value := c.Query(key)
+	if value == "" {
+		return false, nil
+	}
+
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s value %q", key, value)
+	}
+	return parsed, nil
+}
+
+// readLogLines reads a log stream line by line and sends every line, with an optional prefix,
+// to out until the stream ends or ctx is cancelled
+func readLogLines(ctx context.Context, stream io.Reader, prefix string, out chan<- string) {
+	reader := bufio.NewReader(stream)
+	for {
+		line, err := reader.ReadString('\n')
+		if line != "" {
+			select {
+			case out <- prefix + strings.TrimRight(line, "\r\n"):
+			case <-ctx.Done():
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// writeLogStream writes log lines to the client as they arrive, as Server-Sent Events when the
+// client asks for text/event-stream and as chunked plain text otherwise. Notices are sent as
+// events of their kind, or as lines tagged with it in plain text. notices may be nil.
+func writeLogStream(c *gin.Context, lines <-chan string, notices <-chan logNotice) {
+	useSSE := strings.Contains(c.GetHeader("Accept"), "text/event-stream")
+
+	if useSSE {
+		startSSE(c)
+	} else {
+		c.Header("Content-Type", "text/plain; charset=utf-8")
+		c.Header("X-Content-Type-Options", "nosniff")
+		c.Status(http.StatusOK)
+		c.Writer.Flush()
+	}
+
+	heartbeat := time.NewTicker(sseHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				if useSSE {
+					sendSSE(c, "end", "log stream closed")
+				}
+				return
+			}
+			if useSSE {
+				sendSSE(c, "log", line)
+			} else {
+				if _, err := c.Writer.WriteString(line + "\n"); err != nil {
+					return
+				}
+				c.Writer.Flush()
+			}
+		case notice := <-notices:
+			if useSSE {
+				sendSSE(c, notice.kind, notice.message)
+			} else {
+				if _, err := c.Writer.WriteString("[" + notice.kind + "] " + notice.message + "\n"); err != nil {
+					return
+				}
+				c.Writer.Flush()
+			}
+		case <-heartbeat.C:
+			if useSSE {
+				sendSSEHeartbeat(c)
+			}
+		case <-c.Request.Context().Done():
+			return
+		}
+	}
+}
+
+// maxLogWorkers bounds the number of pod log requests made concurrently for one deployment
+const maxLogWorkers = 8
+
+// maxFollowStreams bounds the number of container log streams followed at once for one deployment
+const maxFollowStreams = 50
+
+// logTarget identifies a single container to read logs from
+type logTarget struct {
+	Pod       string
+	Container string
+}
+
+// prefix returns the pod/container tag put in front of every line from this target
+func (t logTarget) prefix() string {
+	return fmt.Sprintf("[%s/%s] ", t.Pod, t.Container)
+}
+
+// logEntry is a log line tagged with the time the container wrote it
+type logEntry struct {
+	time time.Time
+	line string
+}
+
+// logTargets lists every container of the given pods, or only the named container when set
+func logTargets(pods []corev1.Pod, container string) []logTarget {
+	targets := make([]logTarget, 0, len(pods))
+	for _, pod := range pods {
+		for _, c := range pod.Spec.Containers {
+			if container != "" && c.Name != container {
+				continue
+			}
+			targets = append(targets, logTarget{Pod: pod.Name, Container: c.Name})
+		}
+	}
+	return targets
+}
+
+// splitLogTimestamp separates the RFC3339 timestamp the kubelet adds when timestamps are requested
+func splitLogTimestamp(line string) (time.Time, string) {
+	stamp, message, found := strings.Cut(line, " ")
+	if !found {
+		return time.Time{}, line
+	}
+
+	parsed, err := time.Parse(time.RFC3339Nano, stamp)
+	if err != nil {
+		return time.Time{}, line
+	}
+	return parsed, message
+}
+
+// collectLogs fetches the logs of every target with a bounded worker pool and interleaves the
+// lines by timestamp. Targets that fail are reported in the returned error list.
+func collectLogs(ctx context.Context, client k8s.ClusterAPI, namespace string, targets []logTarget, opts *corev1.PodLogOptions) (string, []string) {
+	keepTimestamps := opts.Timestamps
+
+	jobs := make(chan int)
+	entries := make([][]logEntry, len(targets))
+	failures := make([]string, len(targets))
+
+	var wg sync.WaitGroup
+	for w := 0; w < maxLogWorkers && w < len(targets); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				target := targets[i]
+
+				targetOpts := opts.DeepCopy()
+				targetOpts.Container = target.Container
+				targetOpts.Timestamps = true
+
+				logs, err := client.GetPodLogs(ctx, namespace, target.Pod, targetOpts)
+				if err != nil {
+					failures[i] = fmt.Sprintf("%s/%s: %v", target.Pod, target.Container, err)
+					continue
+				}
+
+				for _, line := range strings.Split(strings.TrimRight(logs, "\n"), "\n") {
+					if line == "" {
+						continue
+					}
+					stamp, message := splitLogTimestamp(line)
+					if keepTimestamps {
+						message = line
+					}
+					entries[i] = append(entries[i], logEntry{time: stamp, line: target.prefix() + message})
+				}
+			}
+		}()
+	}
+
+	for i := range targets {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	merged := make([]logEntry, 0)
+	errs := make([]string, 0)
+	for i := range targets {
+		merged = append(merged, entries[i]...)
+		if failures[i] != "" {
+			errs = append(errs, failures[i])
+		}
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].time.Before(merged[j].time)
+	})
+
+	var builder strings.Builder
+	for _, entry := range merged {
+		builder.WriteString(entry.line)
+		builder.WriteByte('\n')
+	}
+	return builder.String(), errs
+}
+
+// Retry delays of the pod watch of followed deployment logs, doubling after every consecutive
+// failure
+const (
+	followRetryInterval    = time.Second
+	followMaxRetryInterval = time.Minute
+)
+
+// Kinds of notices sent alongside followed log lines
+const (
+	// logNoticeWarning reports containers that are not followed
+	logNoticeWarning = "warning"
+	// logNoticeError reports why following ended
+	logNoticeError = "error"
+)
+
+// logNotice is a message about a followed log stream rather than a log line
+type logNotice struct {
+	kind    string
+	message string
+}
+
+// followedContainer is a container instance whose log is followed
+type followedContainer struct {
+	// opts requests the container's log the first time
+	opts   *corev1.PodLogOptions
+	active bool
+	// last is the time of the latest line sent, which a restarted stream resumes after
+	last time.Time
+	// skipped is set once the client has been told the container is not followed
+	skipped bool
+}
+
+// followLogs streams the logs of every running container in pods matching labelSelector into
+// out until ctx is cancelled. Pods and container restarts that appear while following, such as
+// during a rollout, are picked up through a pod watch and streamed from their first line.
+// Streams that end while their container still runs are resumed after the last line sent.
+// Containers beyond maxFollowStreams and watch errors that retrying cannot fix are reported
+// through notices. out is closed once every stream has ended.
+func followLogs(ctx context.Context, client k8s.ClusterAPI, namespace, labelSelector string, pods []corev1.Pod, opts *corev1.PodLogOptions, out chan<- string, notices chan<- logNotice) {
+	var mu sync.Mutex
+	containers := make(map[string]*followedContainer)
+	active := 0
+
+	var wg sync.WaitGroup
+	defer close(out)
+	defer wg.Wait()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	notify := func(notice logNotice) {
+		select {
+		case notices <- notice:
+		case <-ctx.Done():
+		}
+	}
+
+	start := func(pod *corev1.Pod, fresh bool) {
+		var skipped []string
+		for _, status := range pod.Status.ContainerStatuses {
+			if opts.Container != "" && status.Name != opts.Container {
+				continue
+			}
+			if status.State.Running == nil {
+				continue
+			}
+
+			key := fmt.Sprintf("%s/%s/%d", pod.Name, status.Name, status.RestartCount)
+			mu.Lock()
+			followed, ok := containers[key]
+			if !ok {
+				followed = &followedContainer{opts: opts.DeepCopy()}
+				followed.opts.Container = status.Name
+				followed.opts.Follow = true
+				followed.opts.Timestamps = true
+				if fresh {
+					followed.opts.TailLines = nil
+					followed.opts.SinceSeconds = nil
+					followed.opts.SinceTime = nil
+				}
+				containers[key] = followed
+			}
+			if followed.active {
+				mu.Unlock()
+				continue
+			}
+			if active >= maxFollowStreams {
+				if !followed.skipped {
+					followed.skipped = true
+					skipped = append(skipped, pod.Name+"/"+status.Name)
+				}
+				mu.Unlock()
+				continue
+			}
+			followed.active = true
+			active++
+
+			streamOpts := followed.opts.DeepCopy()
+			if !followed.last.IsZero() {
+				// Only whole seconds are passed on, lines up to the last one are dropped below
+				streamOpts.TailLines = nil
+				streamOpts.SinceSeconds = nil
+				streamOpts.SinceTime = &metav1.Time{Time: followed.last}
+			}
+			mu.Unlock()
+
+			target := logTarget{Pod: pod.Name, Container: status.Name}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				// Let the next pod event resume this container once its stream fails or ends
+				defer func() {
+					mu.Lock()
+					followed.active = false
+					active--
+					mu.Unlock()
+				}()
+
+				stream, err := client.StreamPodLogs(ctx, namespace, target.Pod, streamOpts)
+				if err != nil {
+					return
+				}
+				defer stream.Close()
+
+				reader := bufio.NewReader(stream)
+				for {
+					line, err := reader.ReadString('\n')
+					if line = strings.TrimRight(line, "\r\n"); line != "" {
+						stamp, message := splitLogTimestamp(line)
+						mu.Lock()
+						repeated := !stamp.IsZero() && !stamp.After(followed.last)
+						if !repeated && !stamp.IsZero() {
+							followed.last = stamp
+						}
+						mu.Unlock()
+
+						if opts.Timestamps {
+							message = line
+						}
+						if !repeated {
+							select {
+							case out <- target.prefix() + message:
+							case <-ctx.Done():
+								return
+							}
+						}
+					}
+					if err != nil {
+						return
+					}
+				}
+			}()
+		}
+
+		if len(skipped) > 0 {
+			notify(logNotice{
+				kind:    logNoticeWarning,
+				message: fmt.Sprintf("At most %d containers are followed at once, not following %s", maxFollowStreams, strings.Join(skipped, ", ")),
+			})
+		}
+	}
+
+	for i := range pods {
+		start(&pods[i], false)
+	}
+
+	failures := 0
+	for ctx.Err() == nil {
+		err := watchFollowedPods(ctx, client, namespace, labelSelector, func(pod *corev1.Pod) { start(pod, true) })
+		if ctx.Err() != nil {
+			return
+		}
+
+		delay := followRetryInterval
+		if err != nil {
+			if permanentWatchError(err) {
+				notify(logNotice{kind: logNoticeError, message: fmt.Sprintf("Stopped following new pods: %v", err)})
+				return
+			}
+			failures++
+			delay = min(followRetryInterval<<min(failures-1, 30), followMaxRetryInterval)
+		} else {
+			failures = 0
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+		}
+	}
+}
+
+// watchFollowedPods calls started for every pod added or modified until the watch ends, and
+// returns the error the watch failed with
+func watchFollowedPods(ctx context.Context, client k8s.ClusterAPI, namespace, labelSelector string, started func(*corev1.Pod)) error {
+	w, err := client.WatchPods(ctx, namespace, labelSelector)
+	if err != nil {
+		return err
+	}
+	defer w.Stop()
+
+	for event := range w.ResultChan() {
+		switch event.Type {
+		case watch.Error:
+			return apierrors.FromObject(event.Object)
+		case watch.Added, watch.Modified:
+			if pod, ok := event.Object.(*corev1.Pod); ok {
+				started(pod)
+			}
+		}
+	}
+	return nil
+}
+
+// permanentWatchError reports whether retrying a failed watch cannot help, e.g. because the
+// caller may not watch pods
+func permanentWatchError(err error) bool {
+	return apierrors.IsForbidden(err) || apierrors.IsUnauthorized(err) || apierrors.IsNotFound(err) ||
+		apierrors.IsBadRequest(err) || apierrors.IsInvalid(err) || apierrors.IsMethodNotSupported(err)
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kube-deploy/backend/internal/k8s"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// followClient serves a container's log in parts, one per stream, and reports the pod as
+// modified until every part has been streamed. Later pod watches are forbidden.
+type followClient struct {
+	k8s.ClusterAPI
+	pod *corev1.Pod
+
+	mu      sync.Mutex
+	parts   []string
+	streams []*corev1.PodLogOptions
+	watches int
+}
+
+func (f *followClient) StreamPodLogs(ctx context.Context, namespace, name string, opts *corev1.PodLogOptions) (io.ReadCloser, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.streams = append(f.streams, opts.DeepCopy())
+	part := ""
+	if len(f.streams) <= len(f.parts) {
+		part = f.parts[len(f.streams)-1]
+	}
+	return io.NopCloser(strings.NewReader(part)), nil
+}
+
+func (f *followClient) streamed() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.streams)
+}
+
+func (f *followClient) WatchPods(ctx context.Context, namespace, labelSelector string) (watch.Interface, error) {
+	f.mu.Lock()
+	f.watches++
+	first := f.watches == 1
+	f.mu.Unlock()
+	if !first {
+		return nil, apierrors.NewForbidden(schema.GroupResource{Resource: "pods"}, "", nil)
+	}
+
+	w := watch.NewFake()
+	go func() {
+		defer w.Stop()
+		for f.streamed() < len(f.parts) && ctx.Err() == nil {
+			w.Modify(f.pod)
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+	return w, nil
+}
+
+func TestFollowLogsResumesAfterLastLine(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "web-1", Namespace: "default"},
+		Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{
+			Name:  "web",
+			State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+		}}},
+	}
+	client := &followClient{
+		pod: pod,
+		parts: []string{
+			"2026-01-01T10:00:00.100000000Z first\n2026-01-01T10:00:00.200000000Z second\n",
+			// The API server resumes from the start of the second
+			"2026-01-01T10:00:00.100000000Z first\n2026-01-01T10:00:00.200000000Z second\n2026-01-01T10:00:01.000000000Z third\n",
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tail := int64(10)
+	lines := make(chan string)
+	notices := make(chan logNotice, 1)
+	go followLogs(ctx, client, "default", "app=web", []corev1.Pod{*pod}, &corev1.PodLogOptions{TailLines: &tail}, lines, notices)
+
+	var received []string
+	for line := range lines {
+		received = append(received, line)
+	}
+
+	want := []string{"[web-1/web] first", "[web-1/web] second", "[web-1/web] third"}
+	if strings.Join(received, "\n") != strings.Join(want, "\n") {
+		t.Errorf("expected lines %q, got %q", want, received)
+	}
+
+	resumed := client.streams[1]
+	if resumed.TailLines != nil || resumed.SinceTime == nil || !resumed.SinceTime.Time.Equal(time.Date(2026, 1, 1, 10, 0, 0, 200000000, time.UTC)) {
+		t.Errorf("expected the second stream to resume after the last line, got tail %v since %v", resumed.TailLines, resumed.SinceTime)
+	}
+
+	select {
+	case notice := <-notices:
+		if notice.kind != logNoticeError {
+			t.Errorf("expected an error notice, got %q: %s", notice.kind, notice.message)
+		}
+	default:
+		t.Error("expected the forbidden pod watch to be reported")
+	}
+}
@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kube-deploy/backend/internal/k8s"
+	"github.com/kube-deploy/backend/internal/middleware"
+	"github.com/kube-deploy/backend/internal/models"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// maxManifestSize limits the size of an uploaded manifest bundle
+const maxManifestSize = 10 << 20
+
+// manifestKinds lists the kinds non-admin callers may apply when requests run as the backend's
+// own service account. Applying RBAC objects, webhooks or cluster-wide resources with its
+// permissions would let any deployer escalate beyond the portal's roles. Admins may apply any
+// kind, including Namespaces, as far as the service account's RBAC allows.
+var manifestKinds = map[schema.GroupKind]bool{
+	{Group: "", Kind: "ConfigMap"}:                          true,
+	{Group: "", Kind: "Secret"}:                             true,
+	{Group: "", Kind: "PersistentVolumeClaim"}:              true,
+	{Group: "", Kind: "Pod"}:                                true,
+	{Group: "", Kind: "Service"}:                            true,
+	{Group: "apps", Kind: "Deployment"}:                     true,
+	{Group: "apps", Kind: "StatefulSet"}:                    true,
+	{Group: "batch", Kind: "Job"}:                           true,
+	{Group: "batch", Kind: "CronJob"}:                       true,
+	{Group: "networking.k8s.io", Kind: "Ingress"}:           true,
+	{Group: "autoscaling", Kind: "HorizontalPodAutoscaler"}: true,
+}
+
+type ManifestHandler struct {
+	// anyKind lifts the manifestKinds restriction for every caller. It is set when requests
+	// impersonate the portal user, so that cluster RBAC decides what they may apply.
+	anyKind bool
+}
+
+func NewManifestHandler(anyKind bool) *ManifestHandler {
+	return &ManifestHandler{anyKind: anyKind}
+}
+
+// ApplyManifests handles applying arbitrary Kubernetes manifests
+// @Summary Apply Kubernetes manifests
+// @Description Apply multi-document YAML or JSON manifests in dependency order. The manifests can be sent as the raw request body or as a multipart "file" upload. Unless requests impersonate the portal user (K8S_IMPERSONATE), callers other than admins can only apply ConfigMap, Secret, PersistentVolumeClaim, Pod, Service, Deployment, StatefulSet, Job, CronJob, Ingress and HorizontalPodAutoscaler objects. Admins can also apply Namespaces and other cluster-scoped objects the backend's service account may manage.
+// @Tags manifests
+// @Accept plain
+// @Accept json
+// @Accept mpfd
+// @Produce json
+// @Param namespace query string false "Namespace for objects that do not set one" default(default)
+// @Param dryRun query bool false "Validate on the API server without persisting"
+// @Param manifests body string true "YAML or JSON manifests"
+// @Success 200 {object} models.APIResponse{data=models.ManifestApplyResponse}
+// @Success 207 {object} models.APIResponse{data=models.ManifestApplyResponse}
+// @Failure 400 {object} models.APIResponse
+// @Router /manifests [post]
+func (h *ManifestHandler) ApplyManifests(c *gin.Context) {
+	namespace := c.DefaultQuery("namespace", "default")
+
+	opts, err := writeOptionsFromQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Invalid request: %v", err),
+		})
+		return
+	}
+
+	data, err := h.readManifests(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Invalid request: %v", err),
+		})
+		return
+	}
+
+	objects, err := k8s.DecodeManifests(data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Invalid manifest: %v", err),
+		})
+		return
+	}
+	if len(objects) == 0 {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "No Kubernetes objects found in manifest",
+		})
+		return
+	}
+
+	k8s.SortManifests(objects)
+
+	client := kubeClient(c)
+	access := middleware.GetNamespaceAccess(c)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	response := models.ManifestApplyResponse{
+		Results: make([]models.ManifestObjectResult, 0, len(objects)),
+	}
+	for _, obj := range objects {
+		result := models.ManifestObjectResult{
+			APIVersion: obj.GetAPIVersion(),
+			Kind:       obj.GetKind(),
+			Name:       obj.GetName(),
+		}
+
+		err := h.checkManifestKind(c, obj)
+		if err == nil {
+			err = checkManifestNamespace(client, access, namespace, obj)
+		}
+		if err != nil {
+			result.Namespace = obj.GetNamespace()
+			result.Action = "failed"
+			result.Error = err.Error()
+			response.Failed++
+			response.Results = append(response.Results, result)
+			continue
+		}
+
+		action, _, err := client.ApplyObject(ctx, namespace, obj, opts)
+		result.Namespace = obj.GetNamespace()
+		if err != nil {
+			result.Action = "failed"
+			result.Error = err.Error()
+			response.Failed++
+		} else {
+			result.Action = action
+			switch action {
+			case k8s.ApplyActionCreated:
+				response.Created++
+			case k8s.ApplyActionUpdated:
+				response.Updated++
+			default:
+				response.Unchanged++
+			}
+		}
+
+		response.Results = append(response.Results, result)
+	}
+
+	if response.Failed > 0 {
+		c.JSON(http.StatusMultiStatus, models.APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("%d of %d objects failed to apply", response.Failed, len(objects)),
+			Data:    response,
+		})
+		return
+	}
+
+	message := fmt.Sprintf("Applied %d objects", len(objects))
+	if opts.DryRun {
+		message = fmt.Sprintf("Validated %d objects (dry run)", len(objects))
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: message,
+		Data:    response,
+	})
+}
+
+// checkManifestKind rejects objects whose kind is not in manifestKinds, unless any kind may
+// be applied or the caller is an admin
+func (h *ManifestHandler) checkManifestKind(c *gin.Context, obj *unstructured.Unstructured) error {
+	if h.anyKind || c.GetString("role") == models.RoleAdmin || manifestKinds[obj.GroupVersionKind().GroupKind()] {
+		return nil
+	}
+	return fmt.Errorf("%s objects cannot be applied through the portal", obj.GetKind())
+}
+
+// checkManifestNamespace rejects objects outside the caller's namespaces. Callers limited to
+// some namespaces may not apply cluster-scoped objects at all.
+func checkManifestNamespace(client k8s.ClusterAPI, access middleware.NamespaceAccess, defaultNamespace string, obj *unstructured.Unstructured) error {
+	if access.Unrestricted() {
+		return nil
+	}
+
+	namespaced, err := client.ResolveNamespace(obj, defaultNamespace)
+	if err != nil {
+		return err
+	}
+	if !namespaced {
+		return fmt.Errorf("you may not apply cluster-scoped %s objects", obj.GetKind())
+	}
+	if !access.Allows(obj.GetNamespace()) {
+		return fmt.Errorf("you do not have access to namespace %s", obj.GetNamespace())
+	}
+	return nil
+}
+
+// readManifests reads the manifest bundle from a multipart upload or the raw request body
+func (h *ManifestHandler) readManifests(c *gin.Context) ([]byte, error) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxManifestSize)
+
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		fileHeader, err := c.FormFile("file")
+		if err != nil {
+			return nil, fmt.Errorf("missing manifest file: %w", err)
+		}
+		file, err := fileHeader.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open manifest file: %w", err)
+		}
+		defer file.Close()
+		return io.ReadAll(file)
+	}
+
+	return io.ReadAll(c.Request.Body)
+}
@@ -4,24 +4,24 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"sort"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/kube-deploy/backend/internal/k8s"
+	"github.com/kube-deploy/backend/internal/middleware"
 	"github.com/kube-deploy/backend/internal/models"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 )
 
-type NamespaceHandler struct {
-	k8sClient *k8s.Client
-}
+type NamespaceHandler struct{}
 
-func NewNamespaceHandler(k8sClient *k8s.Client) *NamespaceHandler {
-	return &NamespaceHandler{k8sClient: k8sClient}
+func NewNamespaceHandler() *NamespaceHandler {
+	return &NamespaceHandler{}
 }
 
 // ListNamespaces handles listing all namespaces
 // @Summary List all namespaces
-// @Description Get a list of all namespaces in the cluster
+// @Description Get a list of the namespaces in the cluster that the caller may use
 // @Tags namespaces
 // @Accept json
 // @Produce json
@@ -32,9 +32,26 @@ func (h *NamespaceHandler) ListNamespaces(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	namespaces, err := h.k8sClient.GetNamespaces(ctx)
+	access := middleware.GetNamespaceAccess(c)
+
+	namespaces, err := kubeClient(c).GetNamespaces(ctx)
+	if apierrors.IsForbidden(err) && !access.Unrestricted() {
+		// Impersonated users are rarely allowed to list namespaces cluster-wide, so fall back
+		// to the namespaces bound to their teams
+		nsNames := make([]string, 0, len(access))
+		for namespace := range access {
+			nsNames = append(nsNames, namespace)
+		}
+		sort.Strings(nsNames)
+
+		c.JSON(http.StatusOK, models.APIResponse{
+			Success: true,
+			Data:    nsNames,
+		})
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.APIResponse{
+		c.JSON(statusForError(err), models.APIResponse{
 			Success: false,
 			Error:   fmt.Sprintf("Failed to list namespaces: %v", err),
 		})
@@ -43,6 +60,9 @@ func (h *NamespaceHandler) ListNamespaces(c *gin.Context) {
 
 	nsNames := make([]string, 0, len(namespaces.Items))
 	for _, ns := range namespaces.Items {
+		if !access.Allows(ns.Name) {
+			continue
+		}
 		nsNames = append(nsNames, ns.Name)
 	}
 
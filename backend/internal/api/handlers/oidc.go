@@ -0,0 +1,430 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/gin-gonic/gin"
+	"github.com/kube-deploy/backend/internal/config"
+	"github.com/kube-deploy/backend/internal/database"
+	"github.com/kube-deploy/backend/internal/models"
+	"github.com/kube-deploy/backend/internal/utils"
+	"golang.org/x/oauth2"
+	"gorm.io/gorm"
+)
+
+const (
+	// oidcStateCookie carries the state, nonce and PKCE verifier from the login redirect to
+	// the callback
+	oidcStateCookie = "kd_oidc"
+	// oidcStateTTL is how long a user has to complete the login at the provider
+	oidcStateTTL = 10 * time.Minute
+	// oidcCookiePath limits the state cookie to the OIDC routes
+	oidcCookiePath = "/api/auth/oidc"
+)
+
+// oidcProvider discovers the OpenID Connect provider on first use, so that the server starts
+// even while the provider is unreachable
+type oidcProvider struct {
+	config config.OIDCConfig
+
+	mu       sync.Mutex
+	provider *oidc.Provider
+}
+
+// oidcIdentity is the part of an ID token used to provision a user
+type oidcIdentity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Username      string
+	FullName      string
+	Groups        []string
+}
+
+// load returns the discovered provider and the OAuth2 configuration for it
+func (p *oidcProvider) load(ctx context.Context) (*oidc.Provider, *oauth2.Config, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.provider == nil {
+		provider, err := oidc.NewProvider(ctx, p.config.IssuerURL)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to discover OIDC provider: %w", err)
+		}
+		p.provider = provider
+	}
+
+	return p.provider, &oauth2.Config{
+		ClientID:     p.config.ClientID,
+		ClientSecret: p.config.ClientSecret,
+		RedirectURL:  p.config.RedirectURL,
+		Endpoint:     p.provider.Endpoint(),
+		Scopes:       p.config.Scopes,
+	}, nil
+}
+
+// role maps the user's groups onto the most privileged role configured for any of them
+func (p *oidcProvider) role(groups []string) string {
+	role := p.config.DefaultRole
+	for _, group := range groups {
+		mapped, ok := p.config.RoleMapping[group]
+		if ok && slices.Index(models.Roles, mapped) > slices.Index(models.Roles, role) {
+			role = mapped
+		}
+	}
+	return role
+}
+
+// teams returns the names of the teams the user's groups correspond to
+func (p *oidcProvider) teams(groups []string) []string {
+	teams := make([]string, 0, len(groups))
+	for _, group := range groups {
+		if p.config.TeamGroupPrefix == "" {
+			teams = append(teams, group)
+		} else if team, found := strings.CutPrefix(group, p.config.TeamGroupPrefix); found && team != "" {
+			teams = append(teams, team)
+		}
+	}
+	return teams
+}
+
+// OIDCLogin godoc
+// @Summary Start OIDC login
+// @Description Redirect to the OpenID Connect provider to sign in with the authorization code flow and PKCE
+// @Tags auth
+// @Success 302 {string} string "Redirect to the provider"
+// @Failure 404 {object} models.APIResponse
+// @Failure 502 {object} models.APIResponse
+// @Router /auth/oidc/login [get]
+func (h *AuthHandler) OIDCLogin(c *gin.Context) {
+	if h.oidc == nil {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Error:   "Single sign-on is not configured",
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, oauthConfig, err := h.oidc.load(ctx)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	state, stateErr := utils.RandomID()
+	nonce, nonceErr := utils.RandomID()
+	if stateErr != nil || nonceErr != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to start login",
+		})
+		return
+	}
+	verifier := oauth2.GenerateVerifier()
+
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, state+"."+nonce+"."+verifier, int(oidcStateTTL.Seconds()),
+		oidcCookiePath, "", c.Request.TLS != nil, true)
+
+	c.Redirect(http.StatusFound, oauthConfig.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier)))
+}
+
+// OIDCCallback godoc
+// @Summary Complete OIDC login
+// @Description Exchange the authorization code, provision the user from the ID token on first login and start a session. Role and team memberships follow the user's groups on every login. With a frontend redirect configured the tokens are passed in the URL fragment.
+// @Tags auth
+// @Produce json
+// @Param code query string true "Authorization code"
+// @Param state query string true "State from the login redirect"
+// @Success 200 {object} models.APIResponse{data=models.LoginResponse}
+// @Success 302 {string} string "Redirect to the frontend"
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 502 {object} models.APIResponse
+// @Router /auth/oidc/callback [get]
+func (h *AuthHandler) OIDCCallback(c *gin.Context) {
+	if h.oidc == nil {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Error:   "Single sign-on is not configured",
+		})
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		c.JSON(http.StatusServiceUnavailable, models.APIResponse{
+			Success: false,
+			Error:   "Database not available. Authentication is disabled.",
+		})
+		return
+	}
+
+	if providerError := c.Query("error"); providerError != "" {
+		c.JSON(http.StatusUnauthorized, models.APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Login failed: %s %s", providerError, c.Query("error_description")),
+		})
+		return
+	}
+
+	cookie, err := c.Cookie(oidcStateCookie)
+	c.SetCookie(oidcStateCookie, "", -1, oidcCookiePath, "", c.Request.TLS != nil, true)
+	parts := strings.Split(cookie, ".")
+	if err != nil || len(parts) != 3 || subtle.ConstantTimeCompare([]byte(parts[0]), []byte(c.Query("state"))) != 1 {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid or expired login state, please sign in again",
+		})
+		return
+	}
+	nonce, verifier := parts[1], parts[2]
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	provider, oauthConfig, err := h.oidc.load(ctx)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	token, err := oauthConfig.Exchange(ctx, c.Query("code"), oauth2.VerifierOption(verifier))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Failed to exchange authorization code: %v", err),
+		})
+		return
+	}
+
+	identity, err := h.verifyIDToken(ctx, provider, token, nonce)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Invalid ID token: %v", err),
+		})
+		return
+	}
+
+	user, err := h.provisionOIDCUser(db, identity)
+	if errors.Is(err, errOIDCEmailTaken) {
+		c.JSON(http.StatusConflict, models.APIResponse{
+			Success: false,
+			Error:   "An account with this email address already exists. Ask an administrator to change its email address or remove it before signing in with single sign-on.",
+		})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to provision OIDC user %s: %v", identity.Subject, err)
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to provision user",
+		})
+		return
+	}
+
+	if !user.Active {
+		c.JSON(http.StatusUnauthorized, models.APIResponse{
+			Success: false,
+			Error:   "Account is disabled",
+		})
+		return
+	}
+
+	recordLogin(c, db, user)
+
+	response, err := h.startSession(c, db, *user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to generate token",
+		})
+		return
+	}
+
+	if h.oidc.config.FrontendRedirectURL != "" {
+		// The fragment never reaches a server, so the tokens stay out of access logs
+		fragment := url.Values{
+			"token":         {response.Token},
+			"refresh_token": {response.RefreshToken},
+			"expires_in":    {strconv.FormatInt(response.ExpiresIn, 10)},
+		}
+		c.Redirect(http.StatusFound, h.oidc.config.FrontendRedirectURL+"#"+fragment.Encode())
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    response,
+	})
+}
+
+// verifyIDToken verifies the ID token returned with the access token and extracts the identity
+func (h *AuthHandler) verifyIDToken(ctx context.Context, provider *oidc.Provider, token *oauth2.Token, nonce string) (*oidcIdentity, error) {
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, errors.New("no id_token in token response")
+	}
+
+	idToken, err := provider.Verifier(&oidc.Config{ClientID: h.oidc.config.ClientID}).Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(idToken.Nonce), []byte(nonce)) != 1 {
+		return nil, errors.New("nonce mismatch")
+	}
+
+	var claims map[string]interface{}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, err
+	}
+
+	identity := &oidcIdentity{Subject: idToken.Subject}
+	identity.Email, _ = claims["email"].(string)
+	identity.EmailVerified, _ = claims["email_verified"].(bool)
+	identity.Username, _ = claims["preferred_username"].(string)
+	identity.FullName, _ = claims["name"].(string)
+
+	switch groups := claims[h.oidc.config.GroupsClaim].(type) {
+	case string:
+		identity.Groups = []string{groups}
+	case []interface{}:
+		for _, group := range groups {
+			if name, ok := group.(string); ok {
+				identity.Groups = append(identity.Groups, name)
+			}
+		}
+	}
+
+	return identity, nil
+}
+
+// errOIDCEmailTaken is returned for a first-time OIDC login whose email address belongs to
+// another account
+var errOIDCEmailTaken = errors.New("email address belongs to another account")
+
+// provisionOIDCUser finds or creates the user for an OIDC identity and updates their role and
+// team memberships from the identity's groups. Users are only ever matched by their OIDC
+// subject. Existing accounts are never linked by email address, since whoever controls the
+// provider's claims would otherwise take over the account. A first login whose email address
+// is already in use fails with errOIDCEmailTaken.
+func (h *AuthHandler) provisionOIDCUser(db *gorm.DB, identity *oidcIdentity) (*models.User, error) {
+	var user models.User
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("oidc_subject = ?", identity.Subject).First(&user).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			user, err = h.newOIDCUser(tx, identity)
+			if err != nil {
+				return err
+			}
+		case err != nil:
+			return err
+		}
+
+		user.OIDCSubject = &identity.Subject
+		user.Role = h.oidc.role(identity.Groups)
+		if identity.FullName != "" {
+			user.FullName = identity.FullName
+		}
+		if err := tx.Save(&user).Error; err != nil {
+			return err
+		}
+
+		return syncTeamMemberships(tx, user.ID, h.oidc.teams(identity.Groups))
+	})
+	return &user, err
+}
+
+// newOIDCUser builds a user for a first-time OIDC login. Usernames that are already taken get
+// a random suffix, email addresses that are already taken fail the login with
+// errOIDCEmailTaken. OIDC users have no password, so they cannot use password login.
+func (h *AuthHandler) newOIDCUser(tx *gorm.DB, identity *oidcIdentity) (models.User, error) {
+	// Addresses the provider has not verified are not recorded, so that nobody can claim
+	// someone else's address through a provider that lets users set it freely
+	email := identity.Email
+	if email == "" || !identity.EmailVerified {
+		email = identity.Subject + "@oidc.invalid"
+	}
+
+	var emailTaken int64
+	if err := tx.Model(&models.User{}).Unscoped().Where("email = ?", email).Count(&emailTaken).Error; err != nil {
+		return models.User{}, err
+	}
+	if emailTaken > 0 {
+		return models.User{}, errOIDCEmailTaken
+	}
+
+	username := identity.Username
+	if username == "" {
+		username, _, _ = strings.Cut(email, "@")
+	}
+
+	var taken int64
+	if err := tx.Model(&models.User{}).Unscoped().Where("username = ?", username).Count(&taken).Error; err != nil {
+		return models.User{}, err
+	}
+	if taken > 0 {
+		suffix, err := utils.RandomID()
+		if err != nil {
+			return models.User{}, err
+		}
+		username += "-" + suffix[:6]
+	}
+
+	return models.User{
+		Email:    email,
+		Username: username,
+		Active:   true,
+	}, nil
+}
+
+// syncTeamMemberships makes the user a member of exactly the named teams. Names without a
+// matching team are ignored.
+func syncTeamMemberships(tx *gorm.DB, userID uint, teamNames []string) error {
+	var teamIDs []uint
+	if len(teamNames) > 0 {
+		if err := tx.Model(&models.Team{}).Where("name IN ?", teamNames).Pluck("id", &teamIDs).Error; err != nil {
+			return err
+		}
+	}
+
+	stale := tx.Where("user_id = ?", userID)
+	if len(teamIDs) > 0 {
+		stale = stale.Where("team_id NOT IN ?", teamIDs)
+	}
+	if err := stale.Delete(&models.TeamMembership{}).Error; err != nil {
+		return err
+	}
+
+	for _, teamID := range teamIDs {
+		membership := models.TeamMembership{TeamID: teamID, UserID: userID}
+		if err := tx.Where(membership).FirstOrCreate(&membership).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,334 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/kube-deploy/backend/internal/config"
+	"github.com/kube-deploy/backend/internal/database"
+	"github.com/kube-deploy/backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const (
+	testClientID = "kube-deploy"
+	testState    = "test-state"
+	testNonce    = "test-nonce"
+	testCode     = "test-code"
+)
+
+// testIssuer is an OpenID Connect provider that answers every authorization code with an ID
+// token carrying claims
+type testIssuer struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	claims jwt.MapClaims
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	issuer := &testIssuer{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeTestJSON(w, map[string]interface{}{
+			"issuer":                                issuer.server.URL,
+			"authorization_endpoint":                issuer.server.URL + "/authorize",
+			"token_endpoint":                        issuer.server.URL + "/token",
+			"jwks_uri":                              issuer.server.URL + "/keys",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		writeTestJSON(w, map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "test",
+				"alg": "RS256",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("code") != testCode || r.FormValue("code_verifier") == "" {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+
+		claims := jwt.MapClaims{
+			"iss":   issuer.server.URL,
+			"aud":   testClientID,
+			"iat":   time.Now().Unix(),
+			"exp":   time.Now().Add(time.Hour).Unix(),
+			"nonce": testNonce,
+		}
+		for name, value := range issuer.claims {
+			claims[name] = value
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = "test"
+		idToken, err := token.SignedString(key)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		writeTestJSON(w, map[string]interface{}{
+			"access_token": "test-access-token",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+			"id_token":     idToken,
+		})
+	})
+
+	issuer.server = httptest.NewServer(mux)
+	t.Cleanup(issuer.server.Close)
+	return issuer
+}
+
+func writeTestJSON(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(value)
+}
+
+// handler returns an AuthHandler that signs users in through the issuer
+func (i *testIssuer) handler() *AuthHandler {
+	return NewAuthHandler(true, config.LoginThrottleConfig{}, config.OIDCConfig{
+		IssuerURL:       i.server.URL,
+		ClientID:        testClientID,
+		RedirectURL:     "http://localhost/api/auth/oidc/callback",
+		Scopes:          []string{"openid", "profile", "email"},
+		GroupsClaim:     "groups",
+		RoleMapping:     map[string]string{"developers": models.RoleDeployer},
+		DefaultRole:     models.RoleViewer,
+		TeamGroupPrefix: "team-",
+	})
+}
+
+// callback completes a login whose ID token carries claims, as the browser would after being
+// redirected back from the provider
+func (i *testIssuer) callback(h *AuthHandler, claims jwt.MapClaims) *httptest.ResponseRecorder {
+	i.claims = claims
+
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/auth/oidc/callback?code="+testCode+"&state="+testState, nil)
+	c.Request.AddCookie(&http.Cookie{Name: oidcStateCookie, Value: testState + "." + testNonce + ".test-verifier"})
+
+	h.OIDCCallback(c)
+	return recorder
+}
+
+// newTestDB points the database package at an empty SQLite database for the test
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.Team{}, &models.TeamMembership{}, &models.TeamNamespace{}, &models.Session{}, &models.RefreshToken{}); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+
+	previous := database.DB
+	database.DB = db
+	t.Cleanup(func() { database.DB = previous })
+	return db
+}
+
+func TestOIDCCallbackProvisionsUser(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := newTestDB(t)
+	issuer := newTestIssuer(t)
+
+	team := models.Team{Name: "payments"}
+	if err := db.Create(&team).Error; err != nil {
+		t.Fatalf("failed to create team: %v", err)
+	}
+
+	recorder := issuer.callback(issuer.handler(), jwt.MapClaims{
+		"sub":                "subject-1",
+		"email":              "alice@example.com",
+		"email_verified":     true,
+		"preferred_username": "alice",
+		"name":               "Alice",
+		"groups":             []string{"developers", "team-payments", "unrelated"},
+	})
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", recorder.Code, recorder.Body)
+	}
+
+	var response struct {
+		Data models.LoginResponse `json:"data"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if response.Data.Token == "" || response.Data.RefreshToken == "" {
+		t.Errorf("expected an access and a refresh token, got %+v", response.Data)
+	}
+
+	var user models.User
+	if err := db.Where("oidc_subject = ?", "subject-1").First(&user).Error; err != nil {
+		t.Fatalf("user was not provisioned: %v", err)
+	}
+	if user.Email != "alice@example.com" || user.Username != "alice" || user.FullName != "Alice" {
+		t.Errorf("unexpected profile %q %q %q", user.Email, user.Username, user.FullName)
+	}
+	if user.Role != models.RoleDeployer {
+		t.Errorf("expected role %s from the developers group, got %s", models.RoleDeployer, user.Role)
+	}
+	if user.Password != "" {
+		t.Error("OIDC users must not have a password")
+	}
+
+	var memberships []models.TeamMembership
+	db.Where("user_id = ?", user.ID).Find(&memberships)
+	if len(memberships) != 1 || memberships[0].TeamID != team.ID {
+		t.Errorf("expected membership of team %d only, got %+v", team.ID, memberships)
+	}
+}
+
+func TestOIDCCallbackUpdatesReturningUser(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := newTestDB(t)
+	issuer := newTestIssuer(t)
+	h := issuer.handler()
+
+	claims := jwt.MapClaims{
+		"sub":            "subject-1",
+		"email":          "alice@example.com",
+		"email_verified": true,
+		"groups":         []string{"developers"},
+	}
+	if recorder := issuer.callback(h, claims); recorder.Code != http.StatusOK {
+		t.Fatalf("first login: expected status 200, got %d: %s", recorder.Code, recorder.Body)
+	}
+
+	// Leaving the developers group drops the user back to the default role
+	claims["groups"] = []string{}
+	if recorder := issuer.callback(h, claims); recorder.Code != http.StatusOK {
+		t.Fatalf("second login: expected status 200, got %d: %s", recorder.Code, recorder.Body)
+	}
+
+	var users []models.User
+	db.Find(&users)
+	if len(users) != 1 {
+		t.Fatalf("expected the returning user to be matched by subject, got %d users", len(users))
+	}
+	if users[0].Role != models.RoleViewer {
+		t.Errorf("expected role %s, got %s", models.RoleViewer, users[0].Role)
+	}
+}
+
+func TestOIDCCallbackDoesNotLinkAccountByEmail(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := newTestDB(t)
+	issuer := newTestIssuer(t)
+
+	existing := models.User{Email: "alice@example.com", Username: "alice", Role: models.RoleAdmin, Active: true}
+	if err := existing.HashPassword("password123"); err != nil {
+		t.Fatalf("failed to hash password: %v", err)
+	}
+	if err := db.Create(&existing).Error; err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+
+	recorder := issuer.callback(issuer.handler(), jwt.MapClaims{
+		"sub":            "attacker",
+		"email":          "alice@example.com",
+		"email_verified": true,
+	})
+	if recorder.Code != http.StatusConflict {
+		t.Fatalf("expected status 409, got %d: %s", recorder.Code, recorder.Body)
+	}
+
+	var user models.User
+	db.First(&user, existing.ID)
+	if user.OIDCSubject != nil {
+		t.Errorf("password account was linked to subject %q", *user.OIDCSubject)
+	}
+	if user.Role != models.RoleAdmin {
+		t.Errorf("password account role changed to %s", user.Role)
+	}
+
+	var count int64
+	db.Model(&models.User{}).Count(&count)
+	if count != 1 {
+		t.Errorf("expected no new user, got %d users", count)
+	}
+}
+
+func TestOIDCCallbackIgnoresUnverifiedEmail(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := newTestDB(t)
+	issuer := newTestIssuer(t)
+
+	recorder := issuer.callback(issuer.handler(), jwt.MapClaims{
+		"sub":            "subject-1",
+		"email":          "alice@example.com",
+		"email_verified": false,
+	})
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", recorder.Code, recorder.Body)
+	}
+
+	var user models.User
+	if err := db.Where("oidc_subject = ?", "subject-1").First(&user).Error; err != nil {
+		t.Fatalf("user was not provisioned: %v", err)
+	}
+	if user.Email != "subject-1@oidc.invalid" {
+		t.Errorf("expected the unverified address not to be recorded, got %s", user.Email)
+	}
+}
+
+func TestOIDCCallbackRejectsInvalidLogins(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := newTestDB(t)
+	issuer := newTestIssuer(t)
+	h := issuer.handler()
+
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+	}{
+		{"nonce mismatch", jwt.MapClaims{"sub": "subject-1", "nonce": "other-nonce"}},
+		{"wrong audience", jwt.MapClaims{"sub": "subject-1", "aud": "other-client"}},
+		{"expired", jwt.MapClaims{"sub": "subject-1", "exp": time.Now().Add(-time.Hour).Unix()}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if recorder := issuer.callback(h, test.claims); recorder.Code != http.StatusUnauthorized {
+				t.Errorf("expected status 401, got %d: %s", recorder.Code, recorder.Body)
+			}
+		})
+	}
+
+	var count int64
+	db.Model(&models.User{}).Count(&count)
+	if count != 0 {
+		t.Errorf("expected no user to be provisioned, got %d", count)
+	}
+}
@@ -4,23 +4,20 @@ import (
 	"context"
 	"fmt"
 	"net/http"
-	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/kube-deploy/backend/internal/k8s"
 	"github.com/kube-deploy/backend/internal/models"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1apply "k8s.io/client-go/applyconfigurations/core/v1"
 )
 
-type PodHandler struct {
-	k8sClient *k8s.Client
-}
+type PodHandler struct{}
 
-func NewPodHandler(k8sClient *k8s.Client) *PodHandler {
-	return &PodHandler{k8sClient: k8sClient}
+func NewPodHandler() *PodHandler {
+	return &PodHandler{}
 }
 
 // CreatePod handles pod creation
@@ -30,8 +27,13 @@ func NewPodHandler(k8sClient *k8s.Client) *PodHandler {
 // @Accept json
 // @Produce json
 // @Param pod body models.PodCreateRequest true "Pod configuration"
+// @Param apply query bool false "Use server-side apply so an existing pod is updated"
+// @Param dryRun query bool false "Validate on the API server without persisting"
+// @Success 200 {object} models.APIResponse{data=models.PodResponse}
 // @Success 201 {object} models.APIResponse{data=models.PodResponse}
 // @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
 // @Failure 500 {object} models.APIResponse
 // @Router /pods [post]
 func (h *PodHandler) CreatePod(c *gin.Context) {
@@ -44,6 +46,19 @@ func (h *PodHandler) CreatePod(c *gin.Context) {
 		return
 	}
 
+	if !requireNamespace(c, req.Namespace) {
+		return
+	}
+
+	opts, err := writeOptionsFromQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Invalid request: %v", err),
+		})
+		return
+	}
+
 	// Build pod spec
 	pod := h.buildPodSpec(&req)
 
@@ -51,58 +66,90 @@ func (h *PodHandler) CreatePod(c *gin.Context) {
 	defer cancel()
 
 	// Create pod
-	createdPod, err := h.k8sClient.CreatePod(ctx, req.Namespace, pod)
+	createdPod, err := kubeClient(c).CreatePod(ctx, req.Namespace, pod, opts)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.APIResponse{
+		c.JSON(statusForError(err), models.APIResponse{
 			Success: false,
 			Error:   fmt.Sprintf("Failed to create pod: %v", err),
 		})
 		return
 	}
 
-	c.JSON(http.StatusCreated, models.APIResponse{
+	status, message := writeResult(opts, "Pod")
+	c.JSON(status, models.APIResponse{
 		Success: true,
-		Message: "Pod created successfully",
+		Message: message,
 		Data:    h.podToResponse(createdPod),
 	})
 }
 
 // ListPods handles listing pods
 // @Summary List all pods
-// @Description Get a list of all pods in the cluster or a specific namespace
+// @Description Get a list of all pods in the cluster or a specific namespace. With a limit the pods are returned a page at a time, pass the continue token of the response to get the next page. The status filter and search term are applied to each page after it has been fetched, so a page can hold fewer pods than the limit or none at all while its continue token is still set. Keep following the continue token until it is empty. Sorting cannot be combined with pagination.
 // @Tags pods
 // @Accept json
 // @Produce json
 // @Param namespace query string false "Namespace filter"
+// @Param limit query int false "Maximum number of pods per page"
+// @Param continue query string false "Continue token of the previous page"
+// @Param labelSelector query string false "Label selector, e.g. app=web"
+// @Param fieldSelector query string false "Field selector, e.g. spec.nodeName=node-1"
+// @Param status query string false "Status filter, e.g. Running or CrashLoopBackOff"
+// @Param search query string false "Only return pods whose name contains this term"
+// @Param sort query string false "Sort order: name, age (youngest first) or restarts, prefix with - to reverse. Not allowed with limit or continue."
+// @Param managedOnly query bool false "Only return pods created through this API"
+// @Param consistent query bool false "Read from the API server instead of the cache"
 // @Success 200 {object} models.APIResponse{data=[]models.PodResponse}
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 410 {object} models.APIResponse
 // @Failure 500 {object} models.APIResponse
 // @Router /pods [get]
 func (h *PodHandler) ListPods(c *gin.Context) {
-	namespace := c.Query("namespace")
-	if namespace == "" {
-		namespace = corev1.NamespaceAll
+	query, ok := parseListQuery(c, listSpec{
+		kind:    "pods",
+		status:  true,
+		managed: true,
+		sorts:   []string{sortByName, sortByAge, sortByRestarts},
+	})
+	if !ok {
+		return
+	}
+	namespace, access, ok := listNamespace(c)
+	if !ok {
+		return
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	podList, err := h.k8sClient.ListPods(ctx, namespace)
+	podList, err := kubeClient(c).ListPods(ctx, namespace, query.opts)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.APIResponse{
+		c.JSON(statusForError(err), models.APIResponse{
 			Success: false,
 			Error:   fmt.Sprintf("Failed to list pods: %v", err),
 		})
 		return
 	}
 
-	pods := make([]models.PodResponse, 0, len(podList.Items))
-	for _, pod := range podList.Items {
-		pods = append(pods, h.podToResponse(&pod))
+	matching := make([]*corev1.Pod, 0, len(podList.Items))
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		if access.Allows(pod.Namespace) && query.matches(pod.Name, podStatus(pod)) {
+			matching = append(matching, pod)
+		}
+	}
+	sortObjects(matching, query, podRestarts)
+
+	pods := make([]models.PodResponse, 0, len(matching))
+	for _, pod := range matching {
+		pods = append(pods, h.podToResponse(pod))
 	}
 
 	c.JSON(http.StatusOK, models.APIResponse{
-		Success: true,
-		Data:    pods,
+		Success:    true,
+		Data:       pods,
+		Pagination: query.pagination(podList.ListMeta, len(pods)),
 	})
 }
 
@@ -114,7 +161,8 @@ func (h *PodHandler) ListPods(c *gin.Context) {
 // @Produce json
 // @Param namespace path string true "Namespace"
 // @Param name path string true "Pod name"
-// @Success 200 {object} models.APIResponse{data=models.PodResponse}
+// @Param consistent query bool false "Read from the API server instead of the cache"
+// @Success 200 {object} models.APIResponse{data=models.PodDetailResponse}
 // @Failure 404 {object} models.APIResponse
 // @Router /pods/{namespace}/{name} [get]
 func (h *PodHandler) GetPod(c *gin.Context) {
@@ -124,9 +172,9 @@ func (h *PodHandler) GetPod(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	pod, err := h.k8sClient.GetPod(ctx, namespace, name)
+	pod, err := kubeClient(c).GetPod(ctx, namespace, name)
 	if err != nil {
-		c.JSON(http.StatusNotFound, models.APIResponse{
+		c.JSON(statusForError(err), models.APIResponse{
 			Success: false,
 			Error:   fmt.Sprintf("Pod not found: %v", err),
 		})
@@ -135,7 +183,7 @@ func (h *PodHandler) GetPod(c *gin.Context) {
 
 	c.JSON(http.StatusOK, models.APIResponse{
 		Success: true,
-		Data:    h.podToResponse(pod),
+		Data:    h.podToDetailResponse(pod),
 	})
 }
 
@@ -157,9 +205,9 @@ func (h *PodHandler) DeletePod(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	err := h.k8sClient.DeletePod(ctx, namespace, name)
+	err := kubeClient(c).DeletePod(ctx, namespace, name)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.APIResponse{
+		c.JSON(statusForError(err), models.APIResponse{
 			Success: false,
 			Error:   fmt.Sprintf("Failed to delete pod: %v", err),
 		})
@@ -174,33 +222,50 @@ func (h *PodHandler) DeletePod(c *gin.Context) {
 
 // GetPodLogs handles getting pod logs
 // @Summary Get pod logs
-// @Description Retrieve logs from a specific pod
+// @Description Retrieve logs from a specific pod. With follow=true the logs are streamed as they are written, as Server-Sent Events when the client accepts text/event-stream and as chunked plain text otherwise.
 // @Tags pods
 // @Accept json
 // @Produce json
+// @Produce plain
+// @Produce text/event-stream
 // @Param namespace path string true "Namespace"
 // @Param name path string true "Pod name"
 // @Param tail query int false "Number of lines to tail" default(100)
+// @Param follow query bool false "Stream new log lines as they are written"
+// @Param container query string false "Container name, defaults to the pod's only container"
+// @Param previous query bool false "Return logs of the previous terminated container instance"
+// @Param sinceSeconds query int false "Only return logs newer than this many seconds"
+// @Param sinceTime query string false "Only return logs after this RFC3339 timestamp"
+// @Param timestamps query bool false "Prefix every line with its RFC3339 timestamp"
+// @Param limitBytes query int false "Maximum number of bytes to return"
 // @Success 200 {object} models.APIResponse{data=object{logs=string}}
+// @Failure 400 {object} models.APIResponse
 // @Failure 500 {object} models.APIResponse
 // @Router /pods/{namespace}/{name}/logs [get]
 func (h *PodHandler) GetPodLogs(c *gin.Context) {
 	namespace := c.Param("namespace")
 	name := c.Param("name")
-	tailLines := int64(100) // default
 
-	if tail := c.Query("tail"); tail != "" {
-		if t, err := strconv.ParseInt(tail, 10, 64); err == nil {
-			tailLines = t
-		}
+	opts, err := podLogOptionsFromQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Invalid request: %v", err),
+		})
+		return
+	}
+
+	if opts.Follow {
+		h.streamPodLogs(c, namespace, name, opts)
+		return
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	logs, err := h.k8sClient.GetPodLogs(ctx, namespace, name, tailLines)
+	logs, err := kubeClient(c).GetPodLogs(ctx, namespace, name, opts)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.APIResponse{
+		c.JSON(statusForError(err), models.APIResponse{
 			Success: false,
 			Error:   fmt.Sprintf("Failed to get pod logs: %v", err),
 		})
@@ -215,77 +280,83 @@ func (h *PodHandler) GetPodLogs(c *gin.Context) {
 	})
 }
 
+// streamPodLogs follows a pod's logs until the container exits or the client disconnects
+func (h *PodHandler) streamPodLogs(c *gin.Context, namespace, name string, opts *corev1.PodLogOptions) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	stream, err := kubeClient(c).StreamPodLogs(ctx, namespace, name, opts)
+	if err != nil {
+		c.JSON(statusForError(err), models.APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Failed to stream pod logs: %v", err),
+		})
+		return
+	}
+	defer stream.Close()
+
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		readLogLines(ctx, stream, "", lines)
+	}()
+
+	writeLogStream(c, lines, nil)
+}
+
 // buildPodSpec builds a Kubernetes pod spec from the request
-func (h *PodHandler) buildPodSpec(req *models.PodCreateRequest) *corev1.Pod {
-	// Build container ports
-	containerPorts := make([]corev1.ContainerPort, 0, len(req.Ports))
+func (h *PodHandler) buildPodSpec(req *models.PodCreateRequest) *corev1apply.PodApplyConfiguration {
+	container := corev1apply.Container().
+		WithName(req.Name).
+		WithImage(req.Image)
+
+	// Add container ports
 	for _, port := range req.Ports {
-		containerPorts = append(containerPorts, corev1.ContainerPort{
-			ContainerPort: port,
-		})
+		container.WithPorts(corev1apply.ContainerPort().WithContainerPort(port))
 	}
 
-	// Build environment variables
-	envVars := make([]corev1.EnvVar, 0, len(req.Env))
+	// Add environment variables
 	for _, env := range req.Env {
-		envVars = append(envVars, corev1.EnvVar{
-			Name:  env.Name,
-			Value: env.Value,
-		})
+		container.WithEnv(corev1apply.EnvVar().WithName(env.Name).WithValue(env.Value))
 	}
 
-	// Build resource requirements
-	resources := corev1.ResourceRequirements{}
-	if req.Resources.CPU != "" || req.Resources.Memory != "" {
-		resources.Requests = corev1.ResourceList{}
-		resources.Limits = corev1.ResourceList{}
-
-		if req.Resources.CPU != "" {
-			resources.Requests[corev1.ResourceCPU] = resource.MustParse(req.Resources.CPU)
-			resources.Limits[corev1.ResourceCPU] = resource.MustParse(req.Resources.CPU)
-		}
-		if req.Resources.Memory != "" {
-			resources.Requests[corev1.ResourceMemory] = resource.MustParse(req.Resources.Memory)
-			resources.Limits[corev1.ResourceMemory] = resource.MustParse(req.Resources.Memory)
-		}
+	if resources := resourceRequirements(req.Resources); resources != nil {
+		container.WithResources(resources)
 	}
 
-	pod := &corev1.Pod{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      req.Name,
-			Namespace: req.Namespace,
-			Labels: map[string]string{
-				"app":         req.Name,
-				"managed-by":  "kube-deploy",
-				"deployed-at": time.Now().Format("2006-01-02"),
-			},
-		},
-		Spec: corev1.PodSpec{
-			Containers: []corev1.Container{
-				{
-					Name:      req.Name,
-					Image:     req.Image,
-					Ports:     containerPorts,
-					Env:       envVars,
-					Resources: resources,
-				},
-			},
-			RestartPolicy: corev1.RestartPolicyAlways,
-		},
+	return corev1apply.Pod(req.Name, req.Namespace).
+		WithLabels(map[string]string{
+			"app":         req.Name,
+			"managed-by":  "kube-deploy",
+			"deployed-at": time.Now().Format("2006-01-02"),
+		}).
+		WithSpec(corev1apply.PodSpec().
+			WithContainers(container).
+			WithRestartPolicy(corev1.RestartPolicyAlways))
+}
+
+// resourceRequirements builds the requests and limits of a container, or returns nil when the
+// request sets neither CPU nor memory. Limits equal requests.
+func resourceRequirements(req models.ResourceRequests) *corev1apply.ResourceRequirementsApplyConfiguration {
+	if req.CPU == "" && req.Memory == "" {
+		return nil
 	}
 
-	return pod
+	list := corev1.ResourceList{}
+	if req.CPU != "" {
+		list[corev1.ResourceCPU] = resource.MustParse(req.CPU)
+	}
+	if req.Memory != "" {
+		list[corev1.ResourceMemory] = resource.MustParse(req.Memory)
+	}
+	return corev1apply.ResourceRequirements().
+		WithRequests(list).
+		WithLimits(list.DeepCopy())
 }
 
 // podToResponse converts a Kubernetes pod to a response model
 func (h *PodHandler) podToResponse(pod *corev1.Pod) models.PodResponse {
-	restarts := int32(0)
 	image := ""
-
-	if len(pod.Status.ContainerStatuses) > 0 {
-		restarts = pod.Status.ContainerStatuses[0].RestartCount
-	}
-
 	if len(pod.Spec.Containers) > 0 {
 		image = pod.Spec.Containers[0].Image
 	}
@@ -293,11 +364,217 @@ func (h *PodHandler) podToResponse(pod *corev1.Pod) models.PodResponse {
 	return models.PodResponse{
 		Name:      pod.Name,
 		Namespace: pod.Namespace,
-		Status:    string(pod.Status.Phase),
+		Status:    podStatus(pod),
 		Phase:     string(pod.Status.Phase),
 		CreatedAt: pod.CreationTimestamp.Format(time.RFC3339),
 		Image:     image,
-		Restarts:  restarts,
+		Restarts:  podRestarts(pod),
 		Labels:    pod.Labels,
 	}
 }
+
+// podRestarts returns how often the containers of a pod have restarted in total
+func podRestarts(pod *corev1.Pod) int32 {
+	restarts := int32(0)
+	for _, status := range pod.Status.ContainerStatuses {
+		restarts += status.RestartCount
+	}
+	return restarts
+}
+
+// podToDetailResponse converts a Kubernetes pod to a detailed response model
+func (h *PodHandler) podToDetailResponse(pod *corev1.Pod) models.PodDetailResponse {
+	conditions := make([]models.PodCondition, 0, len(pod.Status.Conditions))
+	for _, condition := range pod.Status.Conditions {
+		conditions = append(conditions, models.PodCondition{
+			Type:               string(condition.Type),
+			Status:             string(condition.Status),
+			Reason:             condition.Reason,
+			Message:            condition.Message,
+			LastTransitionTime: formatTime(condition.LastTransitionTime),
+		})
+	}
+
+	owners := make([]models.OwnerReference, 0, len(pod.OwnerReferences))
+	for _, owner := range pod.OwnerReferences {
+		owners = append(owners, models.OwnerReference{
+			Kind:       owner.Kind,
+			Name:       owner.Name,
+			Controller: owner.Controller != nil && *owner.Controller,
+		})
+	}
+
+	startTime := ""
+	if pod.Status.StartTime != nil {
+		startTime = formatTime(*pod.Status.StartTime)
+	}
+
+	return models.PodDetailResponse{
+		PodResponse:     h.podToResponse(pod),
+		NodeName:        pod.Spec.NodeName,
+		PodIP:           pod.Status.PodIP,
+		HostIP:          pod.Status.HostIP,
+		QOSClass:        string(pod.Status.QOSClass),
+		StartTime:       startTime,
+		Reason:          pod.Status.Reason,
+		Message:         pod.Status.Message,
+		InitContainers:  containerStatuses(pod.Spec.InitContainers, pod.Status.InitContainerStatuses),
+		Containers:      containerStatuses(pod.Spec.Containers, pod.Status.ContainerStatuses),
+		Conditions:      conditions,
+		OwnerReferences: owners,
+	}
+}
+
+// containerStatuses pairs every container in the spec with its reported status. Containers
+// without a status yet, e.g. while the pod is being scheduled, are reported as waiting.
+func containerStatuses(containers []corev1.Container, statuses []corev1.ContainerStatus) []models.ContainerStatus {
+	byName := make(map[string]corev1.ContainerStatus, len(statuses))
+	for _, status := range statuses {
+		byName[status.Name] = status
+	}
+
+	result := make([]models.ContainerStatus, 0, len(containers))
+	for _, container := range containers {
+		response := models.ContainerStatus{
+			Name:  container.Name,
+			Image: container.Image,
+			State: "waiting",
+		}
+
+		status, ok := byName[container.Name]
+		if ok {
+			response.ImageID = status.ImageID
+			response.RestartCount = status.RestartCount
+			response.Ready = status.Ready
+
+			switch {
+			case status.State.Running != nil:
+				response.State = "running"
+				response.StartedAt = formatTime(status.State.Running.StartedAt)
+			case status.State.Terminated != nil:
+				terminated := status.State.Terminated
+				exitCode := terminated.ExitCode
+				response.State = "terminated"
+				response.Reason = terminated.Reason
+				response.Message = terminated.Message
+				response.ExitCode = &exitCode
+				response.StartedAt = formatTime(terminated.StartedAt)
+				response.FinishedAt = formatTime(terminated.FinishedAt)
+			case status.State.Waiting != nil:
+				response.Reason = status.State.Waiting.Reason
+				response.Message = status.State.Waiting.Message
+			}
+		}
+
+		result = append(result, response)
+	}
+	return result
+}
+
+// podStatus computes the human readable status of a pod the same way kubectl get pods does,
+// e.g. CrashLoopBackOff, ImagePullBackOff, Init:0/1 or Terminating
+func podStatus(pod *corev1.Pod) string {
+	reason := string(pod.Status.Phase)
+	if pod.Status.Reason != "" {
+		reason = pod.Status.Reason
+	}
+
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodScheduled && condition.Reason == corev1.PodReasonSchedulingGated {
+			reason = corev1.PodReasonSchedulingGated
+		}
+	}
+
+	restartableInit := make(map[string]bool, len(pod.Spec.InitContainers))
+	for _, container := range pod.Spec.InitContainers {
+		if container.RestartPolicy != nil && *container.RestartPolicy == corev1.ContainerRestartPolicyAlways {
+			restartableInit[container.Name] = true
+		}
+	}
+
+	initializing := false
+	for i, container := range pod.Status.InitContainerStatuses {
+		switch {
+		case container.State.Terminated != nil && container.State.Terminated.ExitCode == 0:
+			continue
+		case restartableInit[container.Name] && container.Started != nil && *container.Started:
+			// Sidecar containers keep running for the lifetime of the pod
+			continue
+		case container.State.Terminated != nil:
+			terminated := container.State.Terminated
+			switch {
+			case terminated.Reason != "":
+				reason = "Init:" + terminated.Reason
+			case terminated.Signal != 0:
+				reason = fmt.Sprintf("Init:Signal:%d", terminated.Signal)
+			default:
+				reason = fmt.Sprintf("Init:ExitCode:%d", terminated.ExitCode)
+			}
+		case container.State.Waiting != nil && container.State.Waiting.Reason != "" && container.State.Waiting.Reason != "PodInitializing":
+			reason = "Init:" + container.State.Waiting.Reason
+		default:
+			reason = fmt.Sprintf("Init:%d/%d", i, len(pod.Spec.InitContainers))
+		}
+		initializing = true
+		break
+	}
+
+	if !initializing || podConditionTrue(pod, corev1.PodInitialized) {
+		hasRunning := false
+		for i := len(pod.Status.ContainerStatuses) - 1; i >= 0; i-- {
+			container := pod.Status.ContainerStatuses[i]
+
+			switch {
+			case container.State.Waiting != nil && container.State.Waiting.Reason != "":
+				reason = container.State.Waiting.Reason
+			case container.State.Terminated != nil && container.State.Terminated.Reason != "":
+				reason = container.State.Terminated.Reason
+			case container.State.Terminated != nil:
+				if container.State.Terminated.Signal != 0 {
+					reason = fmt.Sprintf("Signal:%d", container.State.Terminated.Signal)
+				} else {
+					reason = fmt.Sprintf("ExitCode:%d", container.State.Terminated.ExitCode)
+				}
+			case container.Ready && container.State.Running != nil:
+				hasRunning = true
+			}
+		}
+
+		// A pod with a completed container is still running while another one is
+		if reason == "Completed" && hasRunning {
+			if podConditionTrue(pod, corev1.PodReady) {
+				reason = "Running"
+			} else {
+				reason = "NotReady"
+			}
+		}
+	}
+
+	if pod.DeletionTimestamp != nil {
+		if pod.Status.Reason == "NodeLost" {
+			reason = "Unknown"
+		} else {
+			reason = "Terminating"
+		}
+	}
+
+	return reason
+}
+
+// podConditionTrue reports whether a pod condition is set to True
+func podConditionTrue(pod *corev1.Pod, conditionType corev1.PodConditionType) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == conditionType {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// formatTime formats a Kubernetes timestamp, leaving unset timestamps empty
+func formatTime(t metav1.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
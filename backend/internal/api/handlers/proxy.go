@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kube-deploy/backend/internal/models"
+)
+
+// proxyStrippedRequestHeaders are portal credentials that must never reach the proxied
+// application, and impersonation headers that the API server would otherwise honour with the
+// backend's own credentials
+var proxyStrippedRequestHeaders = []string{
+	"Authorization",
+	"Cookie",
+	"Impersonate-User",
+	"Impersonate-Group",
+	"Impersonate-Uid",
+}
+
+// proxyStrippedRequestHeaderPrefix covers the Impersonate-Extra-<key> headers
+const proxyStrippedRequestHeaderPrefix = "Impersonate-Extra-"
+
+// proxyStrippedResponseHeaders would otherwise apply to the portal's own origin
+var proxyStrippedResponseHeaders = []string{
+	"Set-Cookie",
+	"Strict-Transport-Security",
+	"Public-Key-Pins",
+	"WWW-Authenticate",
+	"Access-Control-Allow-Origin",
+	"Access-Control-Allow-Credentials",
+	"Access-Control-Allow-Headers",
+	"Access-Control-Allow-Methods",
+	"Access-Control-Expose-Headers",
+	"Access-Control-Max-Age",
+}
+
+type ProxyHandler struct{}
+
+func NewProxyHandler() *ProxyHandler {
+	return &ProxyHandler{}
+}
+
+// ProxyPod handles proxying HTTP requests to a pod port
+// @Summary Proxy HTTP to a pod
+// @Description Reverse-proxy an HTTP request to a pod port through the Kubernetes API server proxy
+// @Tags pods
+// @Param namespace path string true "Namespace"
+// @Param name path string true "Pod name"
+// @Param port path string true "Port number or name, optionally prefixed with the scheme, e.g. https:8443"
+// @Param path path string true "Path on the pod"
+// @Success 200 {string} string "Response from the pod"
+// @Failure 502 {object} models.APIResponse
+// @Router /pods/{namespace}/{name}/proxy/{port}/{path} [get]
+func (h *ProxyHandler) ProxyPod(c *gin.Context) {
+	h.proxy(c, "pods")
+}
+
+// ProxyService handles proxying HTTP requests to a service port
+// @Summary Proxy HTTP to a service
+// @Description Reverse-proxy an HTTP request to a service port through the Kubernetes API server proxy
+// @Tags services
+// @Param namespace path string true "Namespace"
+// @Param name path string true "Service name"
+// @Param port path string true "Port number or name, optionally prefixed with the scheme, e.g. https:8443"
+// @Param path path string true "Path on the service"
+// @Success 200 {string} string "Response from the service"
+// @Failure 502 {object} models.APIResponse
+// @Router /services/{namespace}/{name}/proxy/{port}/{path} [get]
+func (h *ProxyHandler) ProxyService(c *gin.Context) {
+	h.proxy(c, "services")
+}
+
+// proxy forwards the request to the API server proxy subresource of a pod or service
+func (h *ProxyHandler) proxy(c *gin.Context, resource string) {
+	namespace := c.Param("namespace")
+	name := c.Param("name")
+	port := c.Param("port")
+	path := c.Param("path")
+
+	transport, err := kubeClient(c).ProxyTransport()
+	if err != nil {
+		c.JSON(statusForError(err), models.APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Failed to create proxy transport: %v", err),
+		})
+		return
+	}
+
+	target := kubeClient(c).ProxyURL(resource, namespace, name, port)
+	// The portal path up to and including the port, e.g. /api/pods/default/web/proxy/8080
+	publicPrefix := strings.TrimSuffix(c.Request.URL.Path, path)
+
+	query := c.Request.URL.Query()
+	query.Del("token")
+
+	proxy := &httputil.ReverseProxy{
+		Transport: transport,
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.Out.URL.Scheme = target.Scheme
+			r.Out.URL.Host = target.Host
+			r.Out.URL.Path = strings.TrimSuffix(target.Path, "/") + path
+			r.Out.URL.RawPath = ""
+			r.Out.URL.RawQuery = query.Encode()
+			r.Out.Host = target.Host
+
+			for _, header := range proxyStrippedRequestHeaders {
+				r.Out.Header.Del(header)
+			}
+			for header := range r.Out.Header {
+				if strings.HasPrefix(header, proxyStrippedRequestHeaderPrefix) {
+					r.Out.Header.Del(header)
+				}
+			}
+		},
+		ModifyResponse: func(resp *http.Response) error {
+			for _, header := range proxyStrippedResponseHeaders {
+				resp.Header.Del(header)
+			}
+
+			if location := resp.Header.Get("Location"); location != "" {
+				resp.Header.Set("Location", rewriteProxyLocation(location, target, publicPrefix))
+			}
+
+			// Proxied pages are served from the portal's origin. The sandbox gives them an opaque
+			// origin so their scripts cannot read the portal's storage or call the API as the
+			// user. Browsers enforce every CSP header, so the application's own policy still applies.
+			resp.Header.Add("Content-Security-Policy", "sandbox")
+			resp.Header.Set("X-Content-Type-Options", "nosniff")
+			return nil
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			c.JSON(http.StatusBadGateway, models.APIResponse{
+				Success: false,
+				Error:   fmt.Sprintf("Proxy request failed: %v", err),
+			})
+		},
+	}
+
+	proxy.ServeHTTP(c.Writer, c.Request)
+}
+
+// rewriteProxyLocation maps redirect locations from the proxied application back onto the
+// portal's proxy route, so that following a redirect stays inside the proxy
+func rewriteProxyLocation(location string, target *url.URL, publicPrefix string) string {
+	parsed, err := url.Parse(location)
+	if err != nil {
+		return location
+	}
+
+	// Redirects to other hosts are left alone
+	if parsed.Host != "" && parsed.Host != target.Host {
+		return location
+	}
+
+	targetPrefix := strings.TrimSuffix(target.Path, "/")
+	switch {
+	case strings.HasPrefix(parsed.Path, targetPrefix+"/") || parsed.Path == targetPrefix:
+		parsed.Path = publicPrefix + strings.TrimPrefix(parsed.Path, targetPrefix)
+	case strings.HasPrefix(parsed.Path, "/"):
+		parsed.Path = publicPrefix + parsed.Path
+	default:
+		// Relative redirects already resolve against the proxied path
+		return location
+	}
+
+	parsed.Scheme = ""
+	parsed.Host = ""
+	return parsed.String()
+}
@@ -7,19 +7,16 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/kube-deploy/backend/internal/k8s"
 	"github.com/kube-deploy/backend/internal/models"
 	corev1 "k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	corev1apply "k8s.io/client-go/applyconfigurations/core/v1"
 )
 
-type ServiceHandler struct {
-	k8sClient *k8s.Client
-}
+type ServiceHandler struct{}
 
-func NewServiceHandler(k8sClient *k8s.Client) *ServiceHandler {
-	return &ServiceHandler{k8sClient: k8sClient}
+func NewServiceHandler() *ServiceHandler {
+	return &ServiceHandler{}
 }
 
 // CreateService handles service creation
@@ -29,8 +26,13 @@ func NewServiceHandler(k8sClient *k8s.Client) *ServiceHandler {
 // @Accept json
 // @Produce json
 // @Param service body models.ServiceCreateRequest true "Service configuration"
+// @Param apply query bool false "Use server-side apply so an existing service is updated"
+// @Param dryRun query bool false "Validate on the API server without persisting"
+// @Success 200 {object} models.APIResponse{data=models.ServiceResponse}
 // @Success 201 {object} models.APIResponse{data=models.ServiceResponse}
 // @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
 // @Failure 500 {object} models.APIResponse
 // @Router /services [post]
 func (h *ServiceHandler) CreateService(c *gin.Context) {
@@ -43,63 +45,106 @@ func (h *ServiceHandler) CreateService(c *gin.Context) {
 		return
 	}
 
+	if !requireNamespace(c, req.Namespace) {
+		return
+	}
+
+	opts, err := writeOptionsFromQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Invalid request: %v", err),
+		})
+		return
+	}
+
 	service := h.buildServiceSpec(&req)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	createdService, err := h.k8sClient.CreateService(ctx, req.Namespace, service)
+	createdService, err := kubeClient(c).CreateService(ctx, req.Namespace, service, opts)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.APIResponse{
+		c.JSON(statusForError(err), models.APIResponse{
 			Success: false,
 			Error:   fmt.Sprintf("Failed to create service: %v", err),
 		})
 		return
 	}
 
-	c.JSON(http.StatusCreated, models.APIResponse{
+	status, message := writeResult(opts, "Service")
+	c.JSON(status, models.APIResponse{
 		Success: true,
-		Message: "Service created successfully",
+		Message: message,
 		Data:    h.serviceToResponse(createdService),
 	})
 }
 
 // ListServices handles listing services
 // @Summary List all services
-// @Description Get a list of all services in the cluster or a specific namespace
+// @Description Get a list of all services in the cluster or a specific namespace. With a limit the services are returned a page at a time, pass the continue token of the response to get the next page. The search term is applied to each page after it has been fetched, so a page can hold fewer services than the limit or none at all while its continue token is still set. Keep following the continue token until it is empty. Sorting cannot be combined with pagination.
 // @Tags services
 // @Accept json
 // @Produce json
 // @Param namespace query string false "Namespace filter"
+// @Param limit query int false "Maximum number of services per page"
+// @Param continue query string false "Continue token of the previous page"
+// @Param labelSelector query string false "Label selector, e.g. app=web"
+// @Param fieldSelector query string false "Field selector, e.g. metadata.name=web"
+// @Param search query string false "Only return services whose name contains this term"
+// @Param sort query string false "Sort order: name or age (youngest first), prefix with - to reverse. Not allowed with limit or continue."
+// @Param managedOnly query bool false "Only return services created through this API"
+// @Param consistent query bool false "Read from the API server instead of the cache"
 // @Success 200 {object} models.APIResponse{data=[]models.ServiceResponse}
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 410 {object} models.APIResponse
 // @Failure 500 {object} models.APIResponse
 // @Router /services [get]
 func (h *ServiceHandler) ListServices(c *gin.Context) {
-	namespace := c.Query("namespace")
-	if namespace == "" {
-		namespace = corev1.NamespaceAll
+	query, ok := parseListQuery(c, listSpec{
+		kind:    "services",
+		managed: true,
+		sorts:   []string{sortByName, sortByAge},
+	})
+	if !ok {
+		return
+	}
+	namespace, access, ok := listNamespace(c)
+	if !ok {
+		return
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	serviceList, err := h.k8sClient.ListServices(ctx, namespace)
+	serviceList, err := kubeClient(c).ListServices(ctx, namespace, query.opts)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.APIResponse{
+		c.JSON(statusForError(err), models.APIResponse{
 			Success: false,
 			Error:   fmt.Sprintf("Failed to list services: %v", err),
 		})
 		return
 	}
 
-	services := make([]models.ServiceResponse, 0, len(serviceList.Items))
-	for _, service := range serviceList.Items {
-		services = append(services, h.serviceToResponse(&service))
+	matching := make([]*corev1.Service, 0, len(serviceList.Items))
+	for i := range serviceList.Items {
+		service := &serviceList.Items[i]
+		if access.Allows(service.Namespace) && query.matches(service.Name, "") {
+			matching = append(matching, service)
+		}
+	}
+	sortObjects(matching, query, nil)
+
+	services := make([]models.ServiceResponse, 0, len(matching))
+	for _, service := range matching {
+		services = append(services, h.serviceToResponse(service))
 	}
 
 	c.JSON(http.StatusOK, models.APIResponse{
-		Success: true,
-		Data:    services,
+		Success:    true,
+		Data:       services,
+		Pagination: query.pagination(serviceList.ListMeta, len(services)),
 	})
 }
 
@@ -111,6 +156,7 @@ func (h *ServiceHandler) ListServices(c *gin.Context) {
 // @Produce json
 // @Param namespace path string true "Namespace"
 // @Param name path string true "Service name"
+// @Param consistent query bool false "Read from the API server instead of the cache"
 // @Success 200 {object} models.APIResponse{data=models.ServiceResponse}
 // @Failure 404 {object} models.APIResponse
 // @Router /services/{namespace}/{name} [get]
@@ -121,9 +167,9 @@ func (h *ServiceHandler) GetService(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	service, err := h.k8sClient.GetService(ctx, namespace, name)
+	service, err := kubeClient(c).GetService(ctx, namespace, name)
 	if err != nil {
-		c.JSON(http.StatusNotFound, models.APIResponse{
+		c.JSON(statusForError(err), models.APIResponse{
 			Success: false,
 			Error:   fmt.Sprintf("Service not found: %v", err),
 		})
@@ -154,9 +200,9 @@ func (h *ServiceHandler) DeleteService(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	err := h.k8sClient.DeleteService(ctx, namespace, name)
+	err := kubeClient(c).DeleteService(ctx, namespace, name)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.APIResponse{
+		c.JSON(statusForError(err), models.APIResponse{
 			Success: false,
 			Error:   fmt.Sprintf("Failed to delete service: %v", err),
 		})
@@ -169,47 +215,40 @@ func (h *ServiceHandler) DeleteService(c *gin.Context) {
 	})
 }
 
-func (h *ServiceHandler) buildServiceSpec(req *models.ServiceCreateRequest) *corev1.Service {
-	// Build service ports
-	servicePorts := make([]corev1.ServicePort, 0, len(req.Ports))
+func (h *ServiceHandler) buildServiceSpec(req *models.ServiceCreateRequest) *corev1apply.ServiceApplyConfiguration {
+	spec := corev1apply.ServiceSpec().
+		WithType(corev1.ServiceType(req.Type)).
+		WithSelector(req.Selector)
+
+	// Add service ports
 	for _, port := range req.Ports {
 		protocol := corev1.ProtocolTCP
 		if port.Protocol != "" {
 			protocol = corev1.Protocol(port.Protocol)
 		}
 
-		servicePort := corev1.ServicePort{
-			Name:       port.Name,
-			Port:       port.Port,
-			TargetPort: intstr.FromInt(int(port.TargetPort)),
-			Protocol:   protocol,
+		servicePort := corev1apply.ServicePort().
+			WithPort(port.Port).
+			WithTargetPort(intstr.FromInt32(port.TargetPort)).
+			WithProtocol(protocol)
+		if port.Name != "" {
+			servicePort.WithName(port.Name)
 		}
 
 		// Add NodePort only for NodePort type services
 		if req.Type == "NodePort" && port.NodePort > 0 {
-			servicePort.NodePort = port.NodePort
+			servicePort.WithNodePort(port.NodePort)
 		}
 
-		servicePorts = append(servicePorts, servicePort)
-	}
-
-	service := &corev1.Service{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      req.Name,
-			Namespace: req.Namespace,
-			Labels: map[string]string{
-				"managed-by":  "kube-deploy",
-				"created-at": time.Now().Format("2006-01-02"),
-			},
-		},
-		Spec: corev1.ServiceSpec{
-			Type:     corev1.ServiceType(req.Type),
-			Selector: req.Selector,
-			Ports:    servicePorts,
-		},
+		spec.WithPorts(servicePort)
 	}
 
-	return service
+	return corev1apply.Service(req.Name, req.Namespace).
+		WithLabels(map[string]string{
+			"managed-by": "kube-deploy",
+			"created-at": time.Now().Format("2006-01-02"),
+		}).
+		WithSpec(spec)
 }
 
 func (h *ServiceHandler) serviceToResponse(service *corev1.Service) models.ServiceResponse {
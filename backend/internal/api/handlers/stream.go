@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sseHeartbeatInterval keeps idle event streams alive through proxies and load balancers
+const sseHeartbeatInterval = 15 * time.Second
+
+// startSSE prepares the response for a Server-Sent Events stream
+func startSSE(c *gin.Context) {
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+}
+
+// sendSSE writes a single named event and flushes it to the client
+func sendSSE(c *gin.Context, event string, data interface{}) {
+	c.SSEvent(event, data)
+	c.Writer.Flush()
+}
+
+// sendSSEHeartbeat writes an SSE comment line that clients ignore
+func sendSSEHeartbeat(c *gin.Context) {
+	_, _ = c.Writer.WriteString(": heartbeat\n\n")
+	c.Writer.Flush()
+}
@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kube-deploy/backend/internal/database"
+	"github.com/kube-deploy/backend/internal/middleware"
+	"github.com/kube-deploy/backend/internal/models"
+	"github.com/kube-deploy/backend/internal/utils"
+	"gorm.io/gorm"
+)
+
+// defaultAPITokenExpiry is how long a personal access token is valid when no expiry is given
+const defaultAPITokenExpiry = 90 * 24 * time.Hour
+
+// apiTokenPrefixLength is the number of leading token characters kept to recognise a token
+const apiTokenPrefixLength = 8
+
+type TokenHandler struct{}
+
+func NewTokenHandler() *TokenHandler {
+	return &TokenHandler{}
+}
+
+// CreateToken handles creating a personal access token
+// @Summary Create an API token
+// @Description Create a long-lived personal access token, e.g. for CI pipelines. The token acts as the current user, limited to its scopes and namespaces, and is only returned once.
+// @Tags tokens
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param token body models.APITokenCreateRequest true "Token configuration"
+// @Success 201 {object} models.APIResponse{data=models.APITokenCreateResponse}
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /tokens [post]
+func (h *TokenHandler) CreateToken(c *gin.Context) {
+	db, userID, ok := h.tokenOwner(c)
+	if !ok {
+		return
+	}
+
+	var req models.APITokenCreateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Invalid request: %v", err),
+		})
+		return
+	}
+
+	for _, scope := range req.Scopes {
+		if !middleware.ValidScope(scope) {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false,
+				Error:   fmt.Sprintf("Invalid request: invalid scope %q, expected resource:verb", scope),
+			})
+			return
+		}
+	}
+
+	expiry := defaultAPITokenExpiry
+	if req.ExpiresInDays > 0 {
+		expiry = time.Duration(req.ExpiresInDays) * 24 * time.Hour
+	}
+
+	token, hash, err := utils.GenerateAPIToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to generate token",
+		})
+		return
+	}
+
+	apiToken := models.APIToken{
+		UserID:     userID,
+		Name:       req.Name,
+		TokenHash:  hash,
+		Prefix:     token[:apiTokenPrefixLength],
+		Scopes:     req.Scopes,
+		Namespaces: req.Namespaces,
+		ExpiresAt:  time.Now().Add(expiry),
+	}
+	if err := db.Create(&apiToken).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to create token",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.APIResponse{
+		Success: true,
+		Message: "Token created successfully. Store it now, it will not be shown again.",
+		Data: models.APITokenCreateResponse{
+			Token:    token,
+			APIToken: apiToken,
+		},
+	})
+}
+
+// ListTokens handles listing the current user's personal access tokens
+// @Summary List API tokens
+// @Description Get the current user's personal access tokens. The tokens themselves are never returned.
+// @Tags tokens
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.APIResponse{data=[]models.APIToken}
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /tokens [get]
+func (h *TokenHandler) ListTokens(c *gin.Context) {
+	db, userID, ok := h.tokenOwner(c)
+	if !ok {
+		return
+	}
+
+	tokens := make([]models.APIToken, 0)
+	if err := db.Where("user_id = ?", userID).Order("created_at DESC").Find(&tokens).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to list tokens",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    tokens,
+	})
+}
+
+// DeleteToken handles revoking a personal access token
+// @Summary Delete an API token
+// @Description Revoke one of the current user's personal access tokens
+// @Tags tokens
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Token ID"
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Router /tokens/{id} [delete]
+func (h *TokenHandler) DeleteToken(c *gin.Context) {
+	db, userID, ok := h.tokenOwner(c)
+	if !ok {
+		return
+	}
+
+	result := db.Where("id = ? AND user_id = ?", c.Param("id"), userID).Delete(&models.APIToken{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to delete token",
+		})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Error:   "Token not found",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Token deleted successfully",
+	})
+}
+
+// tokenOwner returns the database and the user whose tokens are managed. Tokens can only be
+// managed with a login session, so that a leaked token cannot mint new ones.
+func (h *TokenHandler) tokenOwner(c *gin.Context) (*gorm.DB, uint, bool) {
+	db := database.GetDB()
+	if db == nil {
+		c.JSON(http.StatusServiceUnavailable, models.APIResponse{
+			Success: false,
+			Error:   "Database not available. API tokens are disabled.",
+		})
+		return nil, 0, false
+	}
+
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, models.APIResponse{
+			Success: false,
+			Error:   "Unauthorized",
+		})
+		return nil, 0, false
+	}
+
+	if _, ok := c.Get("apiTokenID"); ok {
+		c.JSON(http.StatusForbidden, models.APIResponse{
+			Success: false,
+			Error:   "API tokens cannot be used to manage API tokens",
+		})
+		return nil, 0, false
+	}
+
+	return db, userID, true
+}
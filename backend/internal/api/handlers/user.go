@@ -0,0 +1,463 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kube-deploy/backend/internal/database"
+	"github.com/kube-deploy/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+const (
+	// defaultUserPageSize is the number of users returned per page when no page size is given
+	defaultUserPageSize = 20
+	// maxUserPageSize caps the number of users returned per page
+	maxUserPageSize = 100
+)
+
+type UserHandler struct{}
+
+func NewUserHandler() *UserHandler {
+	return &UserHandler{}
+}
+
+// ListUsers handles listing users
+// @Summary List users
+// @Description Get a page of users, optionally filtered by a search term matched against email, username and full name. Deleted users are not listed.
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param q query string false "Search term"
+// @Param role query string false "Role filter"
+// @Param active query bool false "Active filter"
+// @Param page query int false "Page number, starting at 1" default(1)
+// @Param page_size query int false "Users per page" default(20)
+// @Success 200 {object} models.APIResponse{data=models.UserListResponse}
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 503 {object} models.APIResponse
+// @Router /admin/users [get]
+func (h *UserHandler) ListUsers(c *gin.Context) {
+	db, ok := h.userDB(c)
+	if !ok {
+		return
+	}
+
+	page, pageSize, err := h.pagination(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Invalid request: %v", err),
+		})
+		return
+	}
+
+	query := db.Model(&models.User{})
+	if search := strings.TrimSpace(c.Query("q")); search != "" {
+		pattern := "%" + escapeLike(strings.ToLower(search)) + "%"
+		query = query.Where("LOWER(email) LIKE ? OR LOWER(username) LIKE ? OR LOWER(full_name) LIKE ?", pattern, pattern, pattern)
+	}
+	if role := c.Query("role"); role != "" {
+		query = query.Where("role = ?", role)
+	}
+	if value := c.Query("active"); value != "" {
+		active, err := strconv.ParseBool(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false,
+				Error:   "Invalid request: active must be true or false",
+			})
+			return
+		}
+		query = query.Where("active = ?", active)
+	}
+
+	response := models.UserListResponse{
+		Users:    make([]models.User, 0),
+		Page:     page,
+		PageSize: pageSize,
+	}
+	if err := query.Count(&response.Total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to list users",
+		})
+		return
+	}
+	if err := query.Order("id").Offset((page - 1) * pageSize).Limit(pageSize).Find(&response.Users).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to list users",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    response,
+	})
+}
+
+// GetUser handles getting a single user
+// @Summary Get a user
+// @Description Get a user by ID
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Success 200 {object} models.APIResponse{data=models.User}
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Router /admin/users/{id} [get]
+func (h *UserHandler) GetUser(c *gin.Context) {
+	db, ok := h.userDB(c)
+	if !ok {
+		return
+	}
+
+	user, ok := h.findUser(c, db)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    user,
+	})
+}
+
+// UpdateUser handles changing a user's email address, role, name or active state
+// @Summary Update a user
+// @Description Change a user's email address, full name, role or active state. Changing the email address or the role, or deactivating the user, ends their login sessions so the change applies immediately. Admins cannot demote or deactivate themselves.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Param user body models.UserUpdateRequest true "Changes"
+// @Success 200 {object} models.APIResponse{data=models.User}
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /admin/users/{id} [put]
+func (h *UserHandler) UpdateUser(c *gin.Context) {
+	db, ok := h.userDB(c)
+	if !ok {
+		return
+	}
+
+	var req models.UserUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Invalid request: %v", err),
+		})
+		return
+	}
+
+	user, ok := h.findUser(c, db)
+	if !ok {
+		return
+	}
+
+	roleChanged := req.Role != nil && *req.Role != user.Role
+	deactivated := req.Active != nil && !*req.Active && user.Active
+	if (roleChanged || deactivated) && user.ID == c.GetUint("userID") {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "You cannot change your own role or deactivate yourself",
+		})
+		return
+	}
+
+	emailChanged := req.Email != nil && *req.Email != user.Email
+	if emailChanged {
+		// Soft-deleted users are still in the unique indexes
+		var taken int64
+		if err := db.Model(&models.User{}).Unscoped().Where("email = ?", *req.Email).Count(&taken).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, models.APIResponse{
+				Success: false,
+				Error:   "Failed to update user",
+			})
+			return
+		}
+		if taken > 0 {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false,
+				Error:   "User with this email already exists",
+			})
+			return
+		}
+		user.Email = *req.Email
+	}
+	if req.FullName != nil {
+		user.FullName = *req.FullName
+	}
+	if req.Role != nil {
+		user.Role = *req.Role
+	}
+	if req.Active != nil {
+		user.Active = *req.Active
+	}
+
+	if err := db.Model(&user).Select("email", "full_name", "role", "active").Updates(&user).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to update user",
+		})
+		return
+	}
+
+	// Access tokens carry the email address and the role, so existing sessions would keep the
+	// old ones
+	if emailChanged || roleChanged || deactivated {
+		if err := database.RevokeUserSessions(user.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, models.APIResponse{
+				Success: false,
+				Error:   "Failed to revoke sessions",
+			})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "User updated successfully",
+		Data:    user,
+	})
+}
+
+// DeleteUser handles deleting a user
+// @Summary Delete a user
+// @Description Soft delete a user. Their login sessions end and their API tokens are deleted. The email address, username and single sign-on identity are freed for new accounts. Admins cannot delete themselves.
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /admin/users/{id} [delete]
+func (h *UserHandler) DeleteUser(c *gin.Context) {
+	db, ok := h.userDB(c)
+	if !ok {
+		return
+	}
+
+	user, ok := h.findUser(c, db)
+	if !ok {
+		return
+	}
+
+	if user.ID == c.GetUint("userID") {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "You cannot delete yourself",
+		})
+		return
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Soft-deleted rows stay in the unique indexes, so the email address, username and OIDC
+		// subject are released for new accounts first
+		if err := tx.Model(&user).Updates(map[string]interface{}{
+			"email":        fmt.Sprintf("deleted-%d-%s", user.ID, user.Email),
+			"username":     fmt.Sprintf("deleted-%d-%s", user.ID, user.Username),
+			"oidc_subject": nil,
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&user).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", user.ID).Delete(&models.APIToken{}).Error
+	})
+	if err == nil {
+		err = database.RevokeUserSessions(user.ID)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to delete user",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "User deleted successfully",
+	})
+}
+
+// ResetPassword handles an admin setting a new password for a user
+// @Summary Reset a user's password
+// @Description Set a new password for a user and end their login sessions
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Param request body models.PasswordResetRequest true "New password"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /admin/users/{id}/password [post]
+func (h *UserHandler) ResetPassword(c *gin.Context) {
+	db, ok := h.userDB(c)
+	if !ok {
+		return
+	}
+
+	var req models.PasswordResetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Invalid request: %v", err),
+		})
+		return
+	}
+
+	user, ok := h.findUser(c, db)
+	if !ok {
+		return
+	}
+
+	if err := user.HashPassword(req.NewPassword); err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to hash password",
+		})
+		return
+	}
+
+	if err := db.Model(&user).Update("password", user.Password).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to reset password",
+		})
+		return
+	}
+
+	// Sessions started with the old password end here
+	if err := database.RevokeUserSessions(user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to revoke sessions",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Password reset successfully",
+	})
+}
+
+// UnlockUser handles lifting a login lockout
+// @Summary Unlock a user
+// @Description Forget a user's failed logins and lift their lockout. Lockouts are recorded in the audit log under the lockouts resource.
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Success 200 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /admin/users/{id}/unlock [post]
+func (h *UserHandler) UnlockUser(c *gin.Context) {
+	db, ok := h.userDB(c)
+	if !ok {
+		return
+	}
+
+	user, ok := h.findUser(c, db)
+	if !ok {
+		return
+	}
+
+	if err := database.ClearLoginFailures(userThrottleKey(user.ID)); err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to unlock user",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "User unlocked successfully",
+	})
+}
+
+// userDB returns the database, which user administration cannot work without
+func (h *UserHandler) userDB(c *gin.Context) (*gorm.DB, bool) {
+	db := database.GetDB()
+	if db == nil {
+		c.JSON(http.StatusServiceUnavailable, models.APIResponse{
+			Success: false,
+			Error:   "Database not available. User management is disabled.",
+		})
+		return nil, false
+	}
+	return db, true
+}
+
+// findUser loads the user named by the :id path parameter. Deleted users are not found.
+func (h *UserHandler) findUser(c *gin.Context, db *gorm.DB) (models.User, bool) {
+	var user models.User
+	err := db.First(&user, "id = ?", c.Param("id")).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Error:   "User not found",
+		})
+		return user, false
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to load user",
+		})
+		return user, false
+	}
+	return user, true
+}
+
+// pagination parses the page and page_size query parameters
+func (h *UserHandler) pagination(c *gin.Context) (int, int, error) {
+	page := 1
+	if value := c.Query("page"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			return 0, 0, fmt.Errorf("page must be a positive number")
+		}
+		page = parsed
+	}
+
+	pageSize := defaultUserPageSize
+	if value := c.Query("page_size"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			return 0, 0, fmt.Errorf("page_size must be a positive number")
+		}
+		pageSize = min(parsed, maxUserPageSize)
+	}
+
+	return page, pageSize, nil
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
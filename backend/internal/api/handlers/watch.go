@@ -0,0 +1,372 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/kube-deploy/backend/internal/k8s"
+	"github.com/kube-deploy/backend/internal/middleware"
+	"github.com/kube-deploy/backend/internal/models"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+const (
+	// watchPingInterval is how often the server pings a change feed connection
+	watchPingInterval = 30 * time.Second
+	// watchPongWait is how long the server waits for a pong before dropping the connection
+	watchPongWait = 60 * time.Second
+	// watchRetryInterval is how long a closed watch waits before it is re-established. Failed
+	// watches wait twice as long after every consecutive failure, up to watchMaxRetryInterval.
+	watchRetryInterval    = time.Second
+	watchMaxRetryInterval = time.Minute
+)
+
+// watchKinds are the kinds the change feed can carry, in the order they are watched
+var watchKinds = []string{k8s.ResourcePods, k8s.ResourceDeployments, k8s.ResourceServices}
+
+type WatchHandler struct {
+	upgrader    websocket.Upgrader
+	pods        *PodHandler
+	deployments *DeploymentHandler
+	services    *ServiceHandler
+}
+
+func NewWatchHandler(allowedOrigins []string) *WatchHandler {
+	return &WatchHandler{
+		upgrader:    newUpgrader(allowedOrigins),
+		pods:        NewPodHandler(),
+		deployments: NewDeploymentHandler(),
+		services:    NewServiceHandler(),
+	}
+}
+
+// Watch handles the resource change feed
+// @Summary Watch resource changes
+// @Description Upgrade to a WebSocket that sends a JSON models.WatchEvent whenever a pod, deployment or service is added, modified or deleted. Objects have the same shape as in the list endpoints. Without a resource version every current object is sent as ADDED first, followed by a BOOKMARK whose resource version the client should resume from. Each event carries the resource version of its kind, pass the latest one back as kind:version to resume after reconnecting. A RESET event means that version expired, the client must drop the objects of that kind before the current ones are sent again. An ERROR event means the watch of a kind failed. The server retries it with growing delays of up to a minute and reports the failure again only after a retry worked. Objects in namespaces the caller may not use are left out. The server pings the connection every 30 seconds. Browsers may pass the JWT in the token query parameter.
+// @Tags watch
+// @Security BearerAuth
+// @Param kinds query string false "Comma separated kinds to watch: pods, deployments, services" default(pods,deployments,services)
+// @Param namespace query string false "Namespace filter"
+// @Param resourceVersion query []string false "Resource versions to resume after as kind:version, e.g. pods:1234" collectionFormat(multi)
+// @Success 101 {string} string "Switching Protocols"
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /watch [get]
+func (h *WatchHandler) Watch(c *gin.Context) {
+	kinds, err := parseWatchKinds(c.Query("kinds"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Invalid request: %v", err),
+		})
+		return
+	}
+	for _, kind := range kinds {
+		if !middleware.Allowed(c, middleware.VerbRead, kind) {
+			c.JSON(http.StatusForbidden, models.APIResponse{
+				Success: false,
+				Error:   "You are not allowed to read " + kind,
+			})
+			return
+		}
+	}
+
+	resourceVersions, err := parseResourceVersions(c.QueryArray("resourceVersion"), kinds)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Invalid request: %v", err),
+		})
+		return
+	}
+
+	namespace, access, ok := listNamespace(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	feed := &watchFeed{
+		client:    kubeClient(c),
+		namespace: namespace,
+		access:    access,
+		events:    make(chan models.WatchEvent),
+	}
+
+	// The watches are started before upgrading so that a failure, e.g. when an impersonated
+	// user may not watch the kind, is reported with a status code. An expired resource
+	// version is reset once the feed runs.
+	watches := make(map[string]watch.Interface, len(kinds))
+	stopWatches := func() {
+		for _, w := range watches {
+			w.Stop()
+		}
+	}
+	for _, kind := range kinds {
+		w, err := feed.client.WatchResource(ctx, kind, namespace, resourceVersions[kind])
+		if err != nil && !resourceVersionExpired(err) {
+			stopWatches()
+			c.JSON(statusForError(err), models.APIResponse{
+				Success: false,
+				Error:   fmt.Sprintf("Failed to watch %s: %v", kind, err),
+			})
+			return
+		}
+		if w != nil {
+			watches[kind] = w
+		}
+	}
+
+	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// The upgrader has already written an error response
+		stopWatches()
+		return
+	}
+	defer conn.Close()
+
+	for _, kind := range kinds {
+		go h.watchKind(ctx, feed, kind, watches[kind], resourceVersions[kind])
+	}
+	go discardMessages(conn, cancel)
+
+	ticker := time.NewTicker(watchPingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case event := <-feed.events:
+			conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			if err := conn.WriteJSON(event); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(10*time.Second)); err != nil {
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// watchFeed collects the events of every watched kind for a single connection
+type watchFeed struct {
+	client    k8s.ClusterAPI
+	namespace string
+	access    middleware.NamespaceAccess
+	events    chan models.WatchEvent
+}
+
+// send hands an event to the connection, returning false once the feed has ended
+func (f *watchFeed) send(ctx context.Context, event models.WatchEvent) bool {
+	select {
+	case f.events <- event:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// watchKind forwards the events of a kind until ctx is done. The API server closes watches
+// periodically, so they are re-established from the last resource version seen. Failed
+// watches are retried with exponential backoff, and only the first of consecutive failures is
+// reported to the client. w may be nil when no watch has been started yet.
+func (h *WatchHandler) watchKind(ctx context.Context, feed *watchFeed, kind string, w watch.Interface, resourceVersion string) {
+	failures := 0
+	for {
+		var err error
+		if w == nil {
+			w, resourceVersion, err = h.startWatch(ctx, feed, kind, resourceVersion)
+		}
+		if w != nil {
+			resourceVersion, err = h.forward(ctx, feed, kind, w, resourceVersion)
+			w.Stop()
+			w = nil
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		delay := watchRetryInterval
+		if err != nil {
+			if failures == 0 {
+				feed.send(ctx, models.WatchEvent{Type: models.WatchError, Kind: kind, Error: err.Error()})
+			}
+			failures++
+			delay = min(watchRetryInterval<<min(failures-1, 30), watchMaxRetryInterval)
+		} else {
+			failures = 0
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// startWatch starts watching a kind after resourceVersion. When that version has expired the
+// client is told to reset the kind and the watch starts over from the current objects. It
+// returns the error when the watch could not be started.
+func (h *WatchHandler) startWatch(ctx context.Context, feed *watchFeed, kind, resourceVersion string) (watch.Interface, string, error) {
+	w, err := feed.client.WatchResource(ctx, kind, feed.namespace, resourceVersion)
+	if resourceVersionExpired(err) {
+		feed.send(ctx, models.WatchEvent{Type: models.WatchReset, Kind: kind})
+		resourceVersion = ""
+		w, err = feed.client.WatchResource(ctx, kind, feed.namespace, resourceVersion)
+	}
+	if err != nil {
+		return nil, resourceVersion, err
+	}
+	return w, resourceVersion, nil
+}
+
+// forward sends the events of a watch to the feed until the watch ends, and returns the
+// resource version to resume from together with the error the watch failed with
+func (h *WatchHandler) forward(ctx context.Context, feed *watchFeed, kind string, w watch.Interface, resourceVersion string) (string, error) {
+	for {
+		var event watch.Event
+		select {
+		case received, ok := <-w.ResultChan():
+			if !ok {
+				return resourceVersion, nil
+			}
+			event = received
+		case <-ctx.Done():
+			return resourceVersion, nil
+		}
+
+		switch event.Type {
+		case watch.Error:
+			err := apierrors.FromObject(event.Object)
+			if resourceVersionExpired(err) {
+				feed.send(ctx, models.WatchEvent{Type: models.WatchReset, Kind: kind})
+				return "", nil
+			}
+			return resourceVersion, err
+
+		case watch.Bookmark:
+			if accessor, err := meta.Accessor(event.Object); err == nil {
+				resourceVersion = accessor.GetResourceVersion()
+				feed.send(ctx, models.WatchEvent{Type: models.WatchBookmark, Kind: kind, ResourceVersion: resourceVersion})
+			}
+
+		case watch.Added, watch.Modified, watch.Deleted:
+			accessor, err := meta.Accessor(event.Object)
+			if err != nil {
+				continue
+			}
+			if accessor.GetResourceVersion() != "" {
+				resourceVersion = accessor.GetResourceVersion()
+			}
+			// The resource version still advances past objects the caller may not see
+			if !feed.access.Allows(accessor.GetNamespace()) {
+				continue
+			}
+			object, ok := h.objectResponse(event.Object)
+			if !ok {
+				continue
+			}
+			feed.send(ctx, models.WatchEvent{
+				Type:            string(event.Type),
+				Kind:            kind,
+				ResourceVersion: resourceVersion,
+				Object:          object,
+			})
+		}
+	}
+}
+
+// objectResponse converts a watched object to the response of its list endpoint
+func (h *WatchHandler) objectResponse(obj runtime.Object) (interface{}, bool) {
+	switch object := obj.(type) {
+	case *corev1.Pod:
+		return h.pods.podToResponse(object), true
+	case *appsv1.Deployment:
+		return h.deployments.deploymentToResponse(object), true
+	case *corev1.Service:
+		return h.services.serviceToResponse(object), true
+	default:
+		return nil, false
+	}
+}
+
+// discardMessages reads and drops client messages so that pongs and close frames are
+// processed, and cancels the feed when the client goes away or stops answering pings
+func discardMessages(conn *websocket.Conn, cancel context.CancelFunc) {
+	defer cancel()
+
+	conn.SetReadDeadline(time.Now().Add(watchPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(watchPongWait))
+	})
+
+	for {
+		if _, _, err := conn.NextReader(); err != nil {
+			return
+		}
+		conn.SetReadDeadline(time.Now().Add(watchPongWait))
+	}
+}
+
+// parseWatchKinds reads the comma separated kinds query parameter. Every kind is watched when
+// it is empty.
+func parseWatchKinds(value string) ([]string, error) {
+	if strings.TrimSpace(value) == "" {
+		return watchKinds, nil
+	}
+
+	var kinds []string
+	for _, kind := range strings.Split(value, ",") {
+		kind = strings.TrimSpace(kind)
+		if !slices.Contains(watchKinds, kind) {
+			return nil, fmt.Errorf("unknown kind %q, expected one of %s", kind, strings.Join(watchKinds, ", "))
+		}
+		if !slices.Contains(kinds, kind) {
+			kinds = append(kinds, kind)
+		}
+	}
+	return kinds, nil
+}
+
+// parseResourceVersions reads the kind:version pairs of the resourceVersion query parameter,
+// given repeatedly or comma separated
+func parseResourceVersions(values []string, kinds []string) (map[string]string, error) {
+	versions := make(map[string]string)
+	for _, value := range values {
+		for _, pair := range strings.Split(value, ",") {
+			kind, version, found := strings.Cut(strings.TrimSpace(pair), ":")
+			if !found || version == "" {
+				return nil, fmt.Errorf("invalid resource version %q, expected kind:version", pair)
+			}
+			if !slices.Contains(kinds, kind) {
+				return nil, fmt.Errorf("resource version given for %s, which is not watched", kind)
+			}
+			versions[kind] = version
+		}
+	}
+	return versions, nil
+}
+
+// resourceVersionExpired reports whether a watch failed because its resource version is too
+// old for the API server to resume from
+func resourceVersionExpired(err error) bool {
+	return err != nil && (apierrors.IsResourceExpired(err) || apierrors.IsGone(err))
+}
@@ -1,13 +1,116 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kube-deploy/backend/internal/models"
 )
 
 type Config struct {
 	KubeConfigPath string
 	Port           string
+	// StrictAuth rejects unauthenticated API requests whenever the database is available
+	StrictAuth bool
+	// AuthDisabled allows running without a database, which makes every caller an admin
+	AuthDisabled bool
+	// ExecAllowedRoles may open a terminal in pods next to admins
+	ExecAllowedRoles []string
+	// ImpersonateUsers runs Kubernetes requests as the portal user instead of the backend's own
+	// service account, so that cluster RBAC applies per user
+	ImpersonateUsers bool
+	// PasswordLogin allows signing up and logging in with a password. It can be turned off
+	// when every user signs in through OIDC.
+	PasswordLogin bool
+	LoginThrottle LoginThrottleConfig
+	OIDC          OIDCConfig
+	// JWTSigningKeyFile is a PEM encoded RSA or ECDSA private key that access tokens are signed
+	// with instead of JWT_SECRET
+	JWTSigningKeyFile string
+	// JWTVerificationKeyFiles are further PEM encoded keys whose tokens are accepted, e.g. the
+	// previous and the next signing key during a rotation
+	JWTVerificationKeyFiles []string
+	// DefaultCluster is the cluster used by requests that do not name one. It defaults to the
+	// cluster the backend runs in, or else the kubeconfig's current context.
+	DefaultCluster string
+	// ClusterEncryptionKey encrypts the kubeconfigs of clusters registered through the API.
+	// Registering clusters is disabled without it.
+	ClusterEncryptionKey string
+	// ClusterHealthInterval is how often the connection to every cluster is checked
+	ClusterHealthInterval time.Duration
+	// InformerCache serves pod, deployment, service and event reads from informers instead of
+	// listing them from the API server on every request
+	InformerCache bool
+	// TrustedProxies are the addresses or CIDR ranges of reverse proxies whose
+	// X-Forwarded-For header is believed. Without any the client IP is the connection's peer.
+	TrustedProxies []string
+}
+
+// LoginThrottleConfig configures the protection of password logins against brute forcing.
+// Each failed attempt delays the next one for the account and the client IP by BackoffBase,
+// doubling with every further failure, until MaxFailures, or IPMaxFailures for an IP, locks
+// them out for LockoutDuration.
+type LoginThrottleConfig struct {
+	MaxFailures     int
+	IPMaxFailures   int
+	BackoffBase     time.Duration
+	LockoutDuration time.Duration
+}
+
+// OIDCConfig configures single sign-on through an OpenID Connect provider
+type OIDCConfig struct {
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is the backend's callback URL registered with the provider
+	RedirectURL string
+	Scopes      []string
+	// GroupsClaim is the ID token claim listing the user's groups
+	GroupsClaim string
+	// RoleMapping maps provider groups onto roles. Users in several mapped groups get the
+	// most privileged role, users in none get DefaultRole.
+	RoleMapping map[string]string
+	DefaultRole string
+	// TeamGroupPrefix is stripped from group names to find the team of the same name. Groups
+	// without the prefix are ignored when it is set.
+	TeamGroupPrefix string
+	// FrontendRedirectURL receives the tokens in its URL fragment after a successful login.
+	// Without it the callback responds with JSON.
+	FrontendRedirectURL string
+}
+
+// Enabled reports whether OIDC login is configured
+func (c OIDCConfig) Enabled() bool {
+	return c.IssuerURL != "" && c.ClientID != ""
+}
+
+// Validate reports settings that name roles that do not exist
+func (c *Config) Validate() error {
+	for _, role := range c.ExecAllowedRoles {
+		if !slices.Contains(models.Roles, role) {
+			return fmt.Errorf("EXEC_ALLOWED_ROLES lists %q, which is not one of %s", role, strings.Join(models.Roles, ", "))
+		}
+	}
+	return c.OIDC.Validate()
+}
+
+// Validate reports roles in DefaultRole and RoleMapping that do not exist, which would
+// otherwise leave users without any permissions
+func (c OIDCConfig) Validate() error {
+	if !slices.Contains(models.Roles, c.DefaultRole) {
+		return fmt.Errorf("OIDC_DEFAULT_ROLE %q is not one of %s", c.DefaultRole, strings.Join(models.Roles, ", "))
+	}
+	for group, role := range c.RoleMapping {
+		if !slices.Contains(models.Roles, role) {
+			return fmt.Errorf("OIDC_ROLE_MAPPING maps group %q to %q, which is not one of %s", group, role, strings.Join(models.Roles, ", "))
+		}
+	}
+	return nil
 }
 
 func Load() *Config {
@@ -27,8 +130,114 @@ func Load() *Config {
 		port = "8080"
 	}
 
+	strictAuth, _ := strconv.ParseBool(os.Getenv("AUTH_STRICT"))
+	authDisabled, _ := strconv.ParseBool(strings.TrimSpace(os.Getenv("AUTH_DISABLED")))
+	impersonateUsers, _ := strconv.ParseBool(os.Getenv("K8S_IMPERSONATE"))
+
+	passwordLogin := true
+	if value, err := strconv.ParseBool(os.Getenv("PASSWORD_LOGIN")); err == nil {
+		passwordLogin = value
+	}
+
+	informerCache := true
+	if value, err := strconv.ParseBool(os.Getenv("K8S_CACHE")); err == nil {
+		informerCache = value
+	}
+
+	clusterHealthInterval := 30 * time.Second
+	if value, err := time.ParseDuration(os.Getenv("CLUSTER_HEALTH_INTERVAL")); err == nil && value > 0 {
+		clusterHealthInterval = value
+	}
+
 	return &Config{
-		KubeConfigPath: kubeconfig,
-		Port:           port,
+		KubeConfigPath:   kubeconfig,
+		Port:             port,
+		StrictAuth:       strictAuth,
+		AuthDisabled:     authDisabled,
+		ExecAllowedRoles: splitList(os.Getenv("EXEC_ALLOWED_ROLES")),
+		ImpersonateUsers: impersonateUsers,
+		PasswordLogin:    passwordLogin,
+		LoginThrottle:    loadLoginThrottle(),
+		OIDC:             loadOIDC(),
+
+		JWTSigningKeyFile:       os.Getenv("JWT_SIGNING_KEY_FILE"),
+		JWTVerificationKeyFiles: splitList(os.Getenv("JWT_VERIFICATION_KEY_FILES")),
+
+		DefaultCluster:        os.Getenv("DEFAULT_CLUSTER"),
+		ClusterEncryptionKey:  os.Getenv("CLUSTER_ENCRYPTION_KEY"),
+		ClusterHealthInterval: clusterHealthInterval,
+		InformerCache:         informerCache,
+		TrustedProxies:        splitList(os.Getenv("TRUSTED_PROXIES")),
+	}
+}
+
+func loadLoginThrottle() LoginThrottleConfig {
+	throttle := LoginThrottleConfig{
+		MaxFailures:     5,
+		IPMaxFailures:   20,
+		BackoffBase:     time.Second,
+		LockoutDuration: 15 * time.Minute,
+	}
+	if value, err := strconv.Atoi(os.Getenv("LOGIN_MAX_FAILURES")); err == nil && value > 0 {
+		throttle.MaxFailures = value
+	}
+	if value, err := strconv.Atoi(os.Getenv("LOGIN_IP_MAX_FAILURES")); err == nil && value > 0 {
+		throttle.IPMaxFailures = value
+	}
+	if value, err := time.ParseDuration(os.Getenv("LOGIN_BACKOFF_BASE")); err == nil && value >= 0 {
+		throttle.BackoffBase = value
+	}
+	if value, err := time.ParseDuration(os.Getenv("LOGIN_LOCKOUT_DURATION")); err == nil && value > 0 {
+		throttle.LockoutDuration = value
+	}
+	return throttle
+}
+
+func loadOIDC() OIDCConfig {
+	scopes := splitList(os.Getenv("OIDC_SCOPES"))
+	if len(scopes) == 0 {
+		scopes = []string{"openid", "profile", "email"}
+	}
+
+	groupsClaim := os.Getenv("OIDC_GROUPS_CLAIM")
+	if groupsClaim == "" {
+		groupsClaim = "groups"
+	}
+
+	defaultRole := os.Getenv("OIDC_DEFAULT_ROLE")
+	if defaultRole == "" {
+		defaultRole = models.RoleViewer
+	}
+
+	// OIDC_ROLE_MAPPING is a list of group:role pairs, e.g. platform:admin,developers:deployer
+	roleMapping := make(map[string]string)
+	for _, pair := range splitList(os.Getenv("OIDC_ROLE_MAPPING")) {
+		if group, role, found := strings.Cut(pair, ":"); found {
+			roleMapping[strings.TrimSpace(group)] = strings.TrimSpace(role)
+		}
+	}
+
+	return OIDCConfig{
+		IssuerURL:           os.Getenv("OIDC_ISSUER_URL"),
+		ClientID:            os.Getenv("OIDC_CLIENT_ID"),
+		ClientSecret:        os.Getenv("OIDC_CLIENT_SECRET"),
+		RedirectURL:         os.Getenv("OIDC_REDIRECT_URL"),
+		Scopes:              scopes,
+		GroupsClaim:         groupsClaim,
+		RoleMapping:         roleMapping,
+		DefaultRole:         defaultRole,
+		TeamGroupPrefix:     os.Getenv("OIDC_TEAM_GROUP_PREFIX"),
+		FrontendRedirectURL: os.Getenv("OIDC_FRONTEND_REDIRECT_URL"),
+	}
+}
+
+// splitList splits a comma-separated environment variable, dropping empty items
+func splitList(value string) []string {
+	items := make([]string, 0)
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
 	}
+	return items
 }
@@ -0,0 +1,33 @@
+package config
+
+import "testing"
+
+func TestOIDCConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  OIDCConfig
+		wantErr bool
+	}{
+		{"known roles", OIDCConfig{DefaultRole: "viewer", RoleMapping: map[string]string{"ops": "admin", "dev": "deployer"}}, false},
+		{"unknown default role", OIDCConfig{DefaultRole: "reader"}, true},
+		{"unknown mapped role", OIDCConfig{DefaultRole: "viewer", RoleMapping: map[string]string{"ops": "Admin"}}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := test.config.Validate(); (err != nil) != test.wantErr {
+				t.Errorf("Validate() error = %v, want error %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfigValidateExecAllowedRoles(t *testing.T) {
+	valid := Config{ExecAllowedRoles: []string{"deployer"}, OIDC: OIDCConfig{DefaultRole: "viewer"}}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("Validate() error = %v, want none", err)
+	}
+	invalid := Config{ExecAllowedRoles: []string{"operator"}, OIDC: OIDCConfig{DefaultRole: "viewer"}}
+	if err := invalid.Validate(); err == nil {
+		t.Error("Validate() accepted an unknown exec role")
+	}
+}
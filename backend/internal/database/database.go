@@ -33,9 +33,16 @@ func Connect() error {
 	log.Println("Connected to PostgreSQL database")
 
 	// Auto-migrate the schema
-	if err := DB.AutoMigrate(&models.User{}); err != nil {
+	if err := DB.AutoMigrate(&models.User{}, &models.Team{}, &models.TeamMembership{}, &models.TeamNamespace{}, &models.AuditEntry{}, &models.APIToken{}, &models.Session{}, &models.RefreshToken{}, &models.LoginThrottle{}, &models.Cluster{}); err != nil {
 		return fmt.Errorf("failed to migrate database: %w", err)
 	}
+	// Team namespace bindings used to be unique per team and namespace, which kept a team from
+	// binding the same namespace in several clusters
+	if DB.Migrator().HasIndex(&models.TeamNamespace{}, "idx_team_namespaces_team_namespace") {
+		if err := DB.Migrator().DropIndex(&models.TeamNamespace{}, "idx_team_namespaces_team_namespace"); err != nil {
+			return fmt.Errorf("failed to migrate database: %w", err)
+		}
+	}
 
 	log.Println("Database migration completed")
 
@@ -0,0 +1,63 @@
+package database
+
+import (
+	"time"
+
+	"github.com/kube-deploy/backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LoginBlockedUntil returns the latest time any of the throttle keys is blocked until. The
+// zero time means logins are allowed.
+func LoginBlockedUntil(keys ...string) (time.Time, error) {
+	var throttles []models.LoginThrottle
+	if err := DB.Where("key IN ?", keys).Find(&throttles).Error; err != nil {
+		return time.Time{}, err
+	}
+
+	var blockedUntil time.Time
+	for _, throttle := range throttles {
+		if throttle.BlockedUntil.After(blockedUntil) {
+			blockedUntil = throttle.BlockedUntil
+		}
+	}
+	return blockedUntil, nil
+}
+
+// RecordLoginFailure counts a failed login for a throttle key and returns the updated count.
+// Failures older than window are forgotten.
+func RecordLoginFailure(key string, window time.Duration) (int, error) {
+	now := time.Now()
+	throttle := models.LoginThrottle{Key: key, Failures: 1, LastFailureAt: now}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "key"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"failures":        gorm.Expr("CASE WHEN login_throttles.last_failure_at < ? THEN 1 ELSE login_throttles.failures + 1 END", now.Add(-window)),
+				"last_failure_at": now,
+			}),
+		}).Create(&throttle).Error
+		if err != nil {
+			return err
+		}
+		return tx.First(&throttle, "key = ?", key).Error
+	})
+	return throttle.Failures, err
+}
+
+// BlockLogins blocks logins for a throttle key until the given time. With reset the failure
+// count starts over, e.g. once the key has been locked out.
+func BlockLogins(key string, until time.Time, reset bool) error {
+	updates := map[string]interface{}{"blocked_until": until}
+	if reset {
+		updates["failures"] = 0
+	}
+	return DB.Model(&models.LoginThrottle{}).Where("key = ?", key).Updates(updates).Error
+}
+
+// ClearLoginFailures forgets the failed logins of throttle keys and lifts their blocks, after a
+// successful login or when an admin unlocks an account
+func ClearLoginFailures(keys ...string) error {
+	return DB.Where("key IN ?", keys).Delete(&models.LoginThrottle{}).Error
+}
@@ -1,6 +1,7 @@
 package database
 
 import (
+	"fmt"
 	"log"
 
 	"github.com/kube-deploy/backend/internal/models"
@@ -25,21 +26,21 @@ func SeedDemoUsers() error {
 			username: "demo",
 			password: "demo123",
 			fullName: "Demo User",
-			role:     "user",
+			role:     models.RoleViewer,
 		},
 		{
 			email:    "admin@kubedeploy.io",
 			username: "admin",
 			password: "admin123",
 			fullName: "Admin User",
-			role:     "admin",
+			role:     models.RoleAdmin,
 		},
 		{
 			email:    "developer@kubedeploy.io",
 			username: "developer",
 			password: "dev123",
 			fullName: "Developer User",
-			role:     "user",
+			role:     models.RoleDeployer,
 		},
 	}
 
@@ -81,3 +82,41 @@ func SeedDemoUsers() error {
 	log.Println("Demo user seeding completed")
 	return nil
 }
+
+// SeedDemoTeams creates a demo team that binds the default namespace to the non-admin demo
+// users, so they keep access to it now that namespaces are scoped to teams
+func SeedDemoTeams() error {
+	if DB == nil {
+		log.Println("Database not available, skipping demo team seeding")
+		return nil
+	}
+
+	var team models.Team
+	if err := DB.Where("name = ?", "demo").First(&team).Error; err == nil {
+		log.Println("Demo team already exists, skipping")
+		return nil
+	}
+
+	team = models.Team{
+		Name:        "demo",
+		Description: "Demo team with access to the default namespace",
+		Namespaces:  []models.TeamNamespace{{Namespace: "default"}},
+	}
+	if err := DB.Create(&team).Error; err != nil {
+		return fmt.Errorf("failed to create demo team: %w", err)
+	}
+
+	var users []models.User
+	if err := DB.Where("username IN ?", []string{"demo", "developer"}).Find(&users).Error; err != nil {
+		return fmt.Errorf("failed to load demo users: %w", err)
+	}
+	for _, user := range users {
+		membership := models.TeamMembership{TeamID: team.ID, UserID: user.ID}
+		if err := DB.Create(&membership).Error; err != nil {
+			log.Printf("Failed to add %s to the demo team: %v", user.Username, err)
+		}
+	}
+
+	log.Println("Demo team seeding completed")
+	return nil
+}
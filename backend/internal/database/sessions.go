@@ -0,0 +1,33 @@
+package database
+
+import (
+	"time"
+
+	"github.com/kube-deploy/backend/internal/models"
+)
+
+// SessionActive reports whether a login session exists, has neither expired nor been revoked,
+// and belongs to an active user
+func SessionActive(sessionID string) (bool, error) {
+	var count int64
+	err := DB.Model(&models.Session{}).
+		Joins("JOIN users ON users.id = sessions.user_id AND users.deleted_at IS NULL").
+		Where("sessions.id = ? AND sessions.revoked_at IS NULL AND sessions.expires_at > ? AND users.active", sessionID, time.Now()).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// RevokeSession ends a login session, invalidating its access and refresh tokens
+func RevokeSession(sessionID string) error {
+	return DB.Model(&models.Session{}).
+		Where("id = ? AND revoked_at IS NULL", sessionID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// RevokeUserSessions ends every login session of a user, e.g. when the user is deactivated or
+// changes their password
+func RevokeUserSessions(userID uint) error {
+	return DB.Model(&models.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
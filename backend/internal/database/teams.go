@@ -0,0 +1,37 @@
+package database
+
+import (
+	"github.com/kube-deploy/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// UserTeamNames returns the names of the teams a user belongs to
+func UserTeamNames(userID uint) ([]string, error) {
+	var teams []string
+	err := DB.Model(&models.Team{}).
+		Joins("JOIN team_memberships ON team_memberships.team_id = teams.id").
+		Where("team_memberships.user_id = ?", userID).
+		Order("teams.name").
+		Pluck("teams.name", &teams).Error
+	return teams, err
+}
+
+// AssignTeamNamespaceClusters binds the team namespaces that were bound before bindings named
+// a cluster to the given cluster, and returns how many it bound. Bindings without a cluster
+// grant nothing, so that they do not follow the default cluster when it changes.
+func AssignTeamNamespaceClusters(cluster string) (int64, error) {
+	var assigned int64
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		// The team may have bound the namespace in the cluster explicitly as well
+		bound := tx.Table("team_namespaces AS bound").Select("1").
+			Where("bound.team_id = team_namespaces.team_id AND bound.namespace = team_namespaces.namespace AND bound.cluster = ?", cluster)
+		if err := tx.Where("cluster = ? AND EXISTS (?)", "", bound).Delete(&models.TeamNamespace{}).Error; err != nil {
+			return err
+		}
+
+		result := tx.Model(&models.TeamNamespace{}).Where("cluster = ?", "").Update("cluster", cluster)
+		assigned = result.RowsAffected
+		return result.Error
+	})
+	return assigned, err
+}
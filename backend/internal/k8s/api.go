@@ -0,0 +1,70 @@
+package k8s
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/watch"
+	appsv1apply "k8s.io/client-go/applyconfigurations/apps/v1"
+	corev1apply "k8s.io/client-go/applyconfigurations/core/v1"
+)
+
+// ClusterAPI is the set of cluster operations the API handlers use. Client implements it
+// against a real cluster, or against an in-memory cluster when created with NewFakeClient.
+type ClusterAPI interface {
+	// Impersonate returns a client that acts as the given user and groups
+	Impersonate(username string, groups []string) (ClusterAPI, error)
+	// Consistent returns a client that reads from the API server instead of the cache
+	Consistent() ClusterAPI
+	StartCache(name string)
+	StopCache()
+	CacheState() string
+	TestConnection(ctx context.Context) error
+	GetNamespaces(ctx context.Context) (*corev1.NamespaceList, error)
+
+	CreatePod(ctx context.Context, namespace string, pod *corev1apply.PodApplyConfiguration, opts WriteOptions) (*corev1.Pod, error)
+	GetPod(ctx context.Context, namespace, name string) (*corev1.Pod, error)
+	ListPods(ctx context.Context, namespace string, opts metav1.ListOptions) (*corev1.PodList, error)
+	WatchPods(ctx context.Context, namespace, labelSelector string) (watch.Interface, error)
+	DeletePod(ctx context.Context, namespace, name string) error
+	GetPodLogs(ctx context.Context, namespace, name string, opts *corev1.PodLogOptions) (string, error)
+	StreamPodLogs(ctx context.Context, namespace, name string, opts *corev1.PodLogOptions) (io.ReadCloser, error)
+	ExecPod(ctx context.Context, namespace, name string, opts ExecOptions) (int, error)
+
+	CreateDeployment(ctx context.Context, namespace string, deployment *appsv1apply.DeploymentApplyConfiguration, opts WriteOptions) (*appsv1.Deployment, error)
+	GetDeployment(ctx context.Context, namespace, name string) (*appsv1.Deployment, error)
+	ListDeployments(ctx context.Context, namespace string, opts metav1.ListOptions) (*appsv1.DeploymentList, error)
+	DeleteDeployment(ctx context.Context, namespace, name string) error
+	ScaleDeployment(ctx context.Context, namespace, name string, replicas int32) error
+	UpdateDeploymentImage(ctx context.Context, namespace, name, container, image string) (*appsv1.Deployment, error)
+	PauseDeployment(ctx context.Context, namespace, name string) (*appsv1.Deployment, error)
+	ResumeDeployment(ctx context.Context, namespace, name string) (*appsv1.Deployment, error)
+	RestartDeployment(ctx context.Context, namespace, name string) (*appsv1.Deployment, error)
+	RollbackDeployment(ctx context.Context, namespace, name string, revision int64) (*appsv1.Deployment, error)
+	ListDeploymentRevisions(ctx context.Context, deployment *appsv1.Deployment) ([]appsv1.ReplicaSet, error)
+	ListDeploymentPods(ctx context.Context, deployment *appsv1.Deployment) ([]corev1.Pod, error)
+	WatchRollout(ctx context.Context, namespace, name string) (<-chan RolloutUpdate, error)
+
+	CreateService(ctx context.Context, namespace string, service *corev1apply.ServiceApplyConfiguration, opts WriteOptions) (*corev1.Service, error)
+	GetService(ctx context.Context, namespace, name string) (*corev1.Service, error)
+	ListServices(ctx context.Context, namespace string, opts metav1.ListOptions) (*corev1.ServiceList, error)
+	DeleteService(ctx context.Context, namespace, name string) error
+
+	ListEvents(ctx context.Context, namespace, kind, name string, opts metav1.ListOptions) (*corev1.EventList, error)
+
+	WatchResource(ctx context.Context, resource, namespace, resourceVersion string) (watch.Interface, error)
+
+	ApplyObject(ctx context.Context, defaultNamespace string, obj *unstructured.Unstructured, opts WriteOptions) (string, *unstructured.Unstructured, error)
+	ResolveNamespace(obj *unstructured.Unstructured, defaultNamespace string) (bool, error)
+
+	ProxyURL(resource, namespace, name, port string) *url.URL
+	ProxyTransport() (http.RoundTripper, error)
+}
+
+var _ ClusterAPI = (*Client)(nil)
@@ -0,0 +1,206 @@
+package k8s
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	appsv1listers "k8s.io/client-go/listers/apps/v1"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+)
+
+// States of a client's informer cache, reported by CacheState
+const (
+	// CacheDisabled clients read from the API server, e.g. impersonated clients
+	CacheDisabled = "disabled"
+	// CacheSyncing clients read from the API server until the initial lists have completed
+	CacheSyncing = "syncing"
+	CacheSynced  = "synced"
+	// CacheFailed clients gave up syncing, e.g. because the backend may not list every
+	// namespace, and read from the API server
+	CacheFailed = "failed"
+)
+
+// cacheSyncTimeout is how long the cache may take to sync before it is given up
+const cacheSyncTimeout = 2 * time.Minute
+
+// clusterCache serves reads of pods, deployments, ReplicaSets, services and events from
+// shared informers, which keep a copy of every such object in the cluster up to date through
+// watches instead of listing them on every request
+type clusterCache struct {
+	factory  informers.SharedInformerFactory
+	stop     chan struct{}
+	stopOnce sync.Once
+	state    atomic.Value
+
+	pods        corev1listers.PodLister
+	deployments appsv1listers.DeploymentLister
+	replicaSets appsv1listers.ReplicaSetLister
+	services    corev1listers.ServiceLister
+	events      corev1listers.EventLister
+}
+
+// newClusterCache creates the informers of a cluster without starting them
+func newClusterCache(clientset kubernetes.Interface) *clusterCache {
+	factory := informers.NewSharedInformerFactoryWithOptions(clientset, 0, informers.WithTransform(stripManagedFields))
+
+	cache := &clusterCache{
+		factory:     factory,
+		stop:        make(chan struct{}),
+		pods:        factory.Core().V1().Pods().Lister(),
+		deployments: factory.Apps().V1().Deployments().Lister(),
+		replicaSets: factory.Apps().V1().ReplicaSets().Lister(),
+		services:    factory.Core().V1().Services().Lister(),
+		events:      factory.Core().V1().Events().Lister(),
+	}
+	cache.state.Store(CacheSyncing)
+	return cache
+}
+
+// start runs the informers and marks the cache synced once every initial list has completed,
+// or failed when that takes longer than cacheSyncTimeout
+func (cc *clusterCache) start(name string) {
+	cc.factory.Start(cc.stop)
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), cacheSyncTimeout)
+		defer cancel()
+		go func() {
+			select {
+			case <-cc.stop:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+
+		for informerType, synced := range cc.factory.WaitForCacheSync(ctx.Done()) {
+			if synced {
+				continue
+			}
+			// A cache stopped in the meantime stays disabled
+			if cc.state.CompareAndSwap(CacheSyncing, CacheFailed) {
+				log.Printf("Cache of cluster %s did not sync %v within %s, reading from the API server instead", name, informerType, cacheSyncTimeout)
+				cc.shutdown()
+			}
+			return
+		}
+		cc.state.CompareAndSwap(CacheSyncing, CacheSynced)
+	}()
+}
+
+// shutdown stops the informers
+func (cc *clusterCache) shutdown() {
+	cc.stopOnce.Do(func() {
+		close(cc.stop)
+		go cc.factory.Shutdown()
+	})
+}
+
+// synced reports whether reads can be served from the cache
+func (cc *clusterCache) synced() bool {
+	return cc.state.Load() == CacheSynced
+}
+
+// StartCache starts serving reads of pods, deployments, ReplicaSets, services and events from
+// an informer cache once it has synced. The backend's own credentials fill the cache, so
+// clients returned by Impersonate never read from it. It must be called before the client is
+// used concurrently.
+func (c *Client) StartCache(name string) {
+	if c.cache != nil {
+		return
+	}
+	c.cache = newClusterCache(c.clientset)
+	c.cache.start(name)
+}
+
+// StopCache stops the informers of the cache. Reads go to the API server afterwards.
+func (c *Client) StopCache() {
+	if c.cache != nil {
+		c.cache.shutdown()
+		c.cache.state.Store(CacheDisabled)
+	}
+}
+
+// CacheState reports whether reads are served from the cache
+func (c *Client) CacheState() string {
+	if c.cache == nil {
+		return CacheDisabled
+	}
+	return c.cache.state.Load().(string)
+}
+
+// Consistent returns a client whose reads go to the API server instead of the cache, for
+// callers that must see their own writes
+func (c *Client) Consistent() ClusterAPI {
+	return c.consistent()
+}
+
+// consistent returns a copy of the client without the cache
+func (c *Client) consistent() *Client {
+	consistent := *c
+	consistent.cache = nil
+	return &consistent
+}
+
+// syncedCache returns the cache when reads can be served from it, and nil otherwise
+func (c *Client) syncedCache() *clusterCache {
+	if c.cache == nil || !c.cache.synced() {
+		return nil
+	}
+	return c.cache
+}
+
+// listCache returns the cache together with the parsed label selector of opts when a list can
+// be served from the cache, and a nil cache otherwise. Paginated lists and field selectors are
+// left to the API server, which implements them.
+func (c *Client) listCache(opts metav1.ListOptions) (*clusterCache, labels.Selector, error) {
+	cache := c.syncedCache()
+	if cache == nil || opts.Limit > 0 || opts.Continue != "" || opts.FieldSelector != "" {
+		return nil, nil, nil
+	}
+
+	selector, err := labels.Parse(opts.LabelSelector)
+	if err != nil {
+		return nil, nil, apierrors.NewBadRequest(fmt.Sprintf("invalid label selector: %v", err))
+	}
+	return cache, selector, nil
+}
+
+// cachedItems copies objects out of the cache, sorted by namespace and name like the lists
+// of the API server. Cached objects are shared and must not be modified.
+func cachedItems[T any, P interface {
+	*T
+	metav1.Object
+	DeepCopy() P
+}](objects []P) []T {
+	slices.SortFunc(objects, func(a, b P) int {
+		return cmp.Or(strings.Compare(a.GetNamespace(), b.GetNamespace()), strings.Compare(a.GetName(), b.GetName()))
+	})
+
+	items := make([]T, 0, len(objects))
+	for _, object := range objects {
+		items = append(items, *object.DeepCopy())
+	}
+	return items
+}
+
+// stripManagedFields drops the managed fields of cached objects, which no handler reads and
+// which make up a large part of their size
+func stripManagedFields(obj interface{}) (interface{}, error) {
+	if accessor, err := meta.Accessor(obj); err == nil {
+		accessor.SetManagedFields(nil)
+	}
+	return obj, nil
+}
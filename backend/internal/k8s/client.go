@@ -2,45 +2,183 @@ package k8s
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	corev1apply "k8s.io/client-go/applyconfigurations/core/v1"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
 	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 )
 
 type Client struct {
-	clientset *kubernetes.Clientset
+	// config is nil for the fake cluster, which has no API server to connect to
+	config    *rest.Config
+	clientset kubernetes.Interface
+	dynamic   dynamic.Interface
+	mapper    meta.ResettableRESTMapper
+	// cache serves reads once StartCache has been called and it has synced
+	cache *clusterCache
 }
 
-// NewClient creates a new Kubernetes client
-func NewClient(kubeconfigPath string) (*Client, error) {
-	var config *rest.Config
-	var err error
+// NewInClusterClient creates a client with the service account of the pod the backend runs in
+func NewInClusterClient() (*Client, error) {
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, err
+	}
+	return newClientForConfig(config, nil)
+}
 
-	// Try in-cluster config first
-	config, err = rest.InClusterConfig()
+// LoadKubeconfig creates a client for every context in a kubeconfig file, keyed by context
+// name, and returns the name of the current context. Contexts that cannot be loaded are left
+// out and reported in the returned error alongside the clients that could.
+func LoadKubeconfig(path string) (map[string]*Client, string, error) {
+	kubeconfig, err := clientcmd.LoadFromFile(path)
 	if err != nil {
-		// Fall back to kubeconfig file
-		config, err = clientcmd.BuildConfigFromFlags("", kubeconfigPath)
+		return nil, "", fmt.Errorf("failed to load kubeconfig: %w", err)
+	}
+
+	clients := make(map[string]*Client, len(kubeconfig.Contexts))
+	var errs []error
+	for name := range kubeconfig.Contexts {
+		client, err := newClientForContext(kubeconfig, name)
 		if err != nil {
-			return nil, fmt.Errorf("failed to build config: %w", err)
+			errs = append(errs, fmt.Errorf("context %s: %w", name, err))
+			continue
+		}
+		clients[name] = client
+	}
+	return clients, kubeconfig.CurrentContext, errors.Join(errs...)
+}
+
+// MinifyKubeconfig reduces a kubeconfig to the given context, or the current one when
+// contextName is empty, and the cluster and user it refers to. It returns the reduced
+// kubeconfig and the name of its context. Registered clusters are stored in this form so that
+// no unrelated credentials are kept.
+func MinifyKubeconfig(data []byte, contextName string) ([]byte, string, error) {
+	kubeconfig, err := clientcmd.Load(data)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid kubeconfig: %w", err)
+	}
+
+	if contextName != "" {
+		kubeconfig.CurrentContext = contextName
+	}
+	if err := clientcmdapi.MinifyConfig(kubeconfig); err != nil {
+		return nil, "", fmt.Errorf("invalid kubeconfig: %w", err)
+	}
+	if err := checkEmbeddedKubeconfig(kubeconfig); err != nil {
+		return nil, "", err
+	}
+
+	minified, err := clientcmd.Write(*kubeconfig)
+	if err != nil {
+		return nil, "", err
+	}
+	return minified, kubeconfig.CurrentContext, nil
+}
+
+// NewClientFromKubeconfig creates a client for the current context of a kubeconfig that was
+// supplied through the API. Kubeconfigs that run credential plugins or refer to local files
+// are rejected, since they would run commands or read files on the server.
+func NewClientFromKubeconfig(data []byte) (*Client, error) {
+	kubeconfig, err := clientcmd.Load(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid kubeconfig: %w", err)
+	}
+	if err := checkEmbeddedKubeconfig(kubeconfig); err != nil {
+		return nil, err
+	}
+	return newClientForContext(kubeconfig, kubeconfig.CurrentContext)
+}
+
+// checkEmbeddedKubeconfig rejects kubeconfig settings that act on the server's filesystem or
+// run programs on it
+func checkEmbeddedKubeconfig(kubeconfig *clientcmdapi.Config) error {
+	for name, cluster := range kubeconfig.Clusters {
+		if cluster.CertificateAuthority != "" {
+			return fmt.Errorf("cluster %s: certificate authority files are not supported, embed the certificate data instead", name)
+		}
+	}
+	for name, user := range kubeconfig.AuthInfos {
+		switch {
+		case user.Exec != nil || user.AuthProvider != nil:
+			return fmt.Errorf("user %s: credential plugins are not supported, use a token or client certificate", name)
+		case user.ClientCertificate != "" || user.ClientKey != "" || user.TokenFile != "":
+			return fmt.Errorf("user %s: credential files are not supported, embed the credentials instead", name)
 		}
 	}
+	return nil
+}
+
+// newClientForContext creates a client for one context of a kubeconfig
+func newClientForContext(kubeconfig *clientcmdapi.Config, contextName string) (*Client, error) {
+	if _, ok := kubeconfig.Contexts[contextName]; !ok {
+		return nil, fmt.Errorf("context %q not found in kubeconfig", contextName)
+	}
+
+	config, err := clientcmd.NewNonInteractiveClientConfig(*kubeconfig, contextName, &clientcmd.ConfigOverrides{}, nil).ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build config: %w", err)
+	}
+	return newClientForConfig(config, nil)
+}
 
+// newClientForConfig creates the clients for a REST config. A nil mapper creates a new
+// discovery-backed one, otherwise the given mapper and its discovery cache are shared.
+func newClientForConfig(config *rest.Config, mapper meta.ResettableRESTMapper) (*Client, error) {
 	clientset, err := kubernetes.NewForConfig(config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create clientset: %w", err)
 	}
 
-	return &Client{clientset: clientset}, nil
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dynamic client: %w", err)
+	}
+
+	if mapper == nil {
+		// Discovery results are cached in memory and refreshed on a mapping miss
+		mapper = restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(clientset.Discovery()))
+	}
+
+	return &Client{
+		config:    config,
+		clientset: clientset,
+		dynamic:   dynamicClient,
+		mapper:    mapper,
+	}, nil
+}
+
+// Impersonate returns a client that acts as the given user and groups, so that the cluster's
+// own RBAC decides what each request may do and the audit log records the portal user. The
+// backend's credentials must be allowed to impersonate users and groups. The fake cluster has
+// no RBAC, so it is returned unchanged.
+func (c *Client) Impersonate(username string, groups []string) (ClusterAPI, error) {
+	if c.config == nil {
+		return c, nil
+	}
+
+	config := rest.CopyConfig(c.config)
+	config.Impersonate = rest.ImpersonationConfig{
+		UserName: username,
+		Groups:   groups,
+	}
+	return newClientForConfig(config, c.mapper)
 }
 
 // GetClientset returns the underlying Kubernetes clientset
-func (c *Client) GetClientset() *kubernetes.Clientset {
+func (c *Client) GetClientset() kubernetes.Interface {
 	return c.clientset
 }
 
@@ -55,19 +193,52 @@ func (c *Client) GetNamespaces(ctx context.Context) (*corev1.NamespaceList, erro
 	return c.clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
 }
 
-// CreatePod creates a new pod
-func (c *Client) CreatePod(ctx context.Context, namespace string, pod *corev1.Pod) (*corev1.Pod, error) {
-	return c.clientset.CoreV1().Pods(namespace).Create(ctx, pod, metav1.CreateOptions{})
+// CreatePod creates a new pod, or applies it with server-side apply when opts.Apply is set
+func (c *Client) CreatePod(ctx context.Context, namespace string, pod *corev1apply.PodApplyConfiguration, opts WriteOptions) (*corev1.Pod, error) {
+	if opts.Apply {
+		return c.clientset.CoreV1().Pods(namespace).Apply(ctx, pod, opts.applyOptions())
+	}
+	created := &corev1.Pod{}
+	if err := fromApplyConfiguration(pod, created); err != nil {
+		return nil, err
+	}
+	return c.clientset.CoreV1().Pods(namespace).Create(ctx, created, opts.createOptions())
 }
 
 // GetPod gets a pod by name and namespace
 func (c *Client) GetPod(ctx context.Context, namespace, name string) (*corev1.Pod, error) {
+	if cache := c.syncedCache(); cache != nil {
+		pod, err := cache.pods.Pods(namespace).Get(name)
+		if err != nil {
+			return nil, err
+		}
+		return pod.DeepCopy(), nil
+	}
 	return c.clientset.CoreV1().Pods(namespace).Get(ctx, name, metav1.GetOptions{})
 }
 
-// ListPods lists all pods in a namespace (or all namespaces if namespace is empty)
-func (c *Client) ListPods(ctx context.Context, namespace string) (*corev1.PodList, error) {
-	return c.clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+// ListPods lists the pods in a namespace (or all namespaces if namespace is empty) that match
+// the selectors of opts, one page at a time when opts has a limit
+func (c *Client) ListPods(ctx context.Context, namespace string, opts metav1.ListOptions) (*corev1.PodList, error) {
+	cache, selector, err := c.listCache(opts)
+	if err != nil {
+		return nil, err
+	}
+	if cache != nil {
+		pods, err := cache.pods.Pods(namespace).List(selector)
+		if err != nil {
+			return nil, err
+		}
+		return &corev1.PodList{Items: cachedItems(pods)}, nil
+	}
+	return c.clientset.CoreV1().Pods(namespace).List(ctx, opts)
+}
+
+// WatchPods watches pods in a namespace that match a label selector
+func (c *Client) WatchPods(ctx context.Context, namespace, labelSelector string) (watch.Interface, error) {
+	return c.clientset.CoreV1().Pods(namespace).Watch(ctx, metav1.ListOptions{
+		LabelSelector: labelSelector,
+	})
 }
 
 // DeletePod deletes a pod
@@ -76,25 +247,28 @@ func (c *Client) DeletePod(ctx context.Context, namespace, name string) error {
 }
 
 // GetPodLogs gets logs from a pod
-func (c *Client) GetPodLogs(ctx context.Context, namespace, name string, tailLines int64) (string, error) {
-	podLogOpts := corev1.PodLogOptions{}
-	if tailLines > 0 {
-		podLogOpts.TailLines = &tailLines
-	}
-
-	req := c.clientset.CoreV1().Pods(namespace).GetLogs(name, &podLogOpts)
-	podLogs, err := req.Stream(ctx)
+func (c *Client) GetPodLogs(ctx context.Context, namespace, name string, opts *corev1.PodLogOptions) (string, error) {
+	podLogs, err := c.StreamPodLogs(ctx, namespace, name, opts)
 	if err != nil {
-		return "", fmt.Errorf("error opening log stream: %w", err)
+		return "", err
 	}
 	defer podLogs.Close()
 
-	buf := new(io.Reader)
-	*buf = podLogs
-	logs, err := io.ReadAll(*buf)
+	logs, err := io.ReadAll(podLogs)
 	if err != nil {
 		return "", fmt.Errorf("error reading logs: %w", err)
 	}
 
 	return string(logs), nil
 }
+
+// StreamPodLogs opens a log stream for a pod. With opts.Follow set, the stream stays open
+// and delivers new lines as the container writes them until ctx is cancelled.
+func (c *Client) StreamPodLogs(ctx context.Context, namespace, name string, opts *corev1.PodLogOptions) (io.ReadCloser, error) {
+	req := c.clientset.CoreV1().Pods(namespace).GetLogs(name, opts)
+	podLogs, err := req.Stream(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error opening log stream: %w", err)
+	}
+	return podLogs, nil
+}
@@ -3,25 +3,58 @@ package k8s
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strconv"
+	"time"
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	appsv1apply "k8s.io/client-go/applyconfigurations/apps/v1"
+	corev1apply "k8s.io/client-go/applyconfigurations/core/v1"
+	"k8s.io/client-go/util/retry"
 )
 
-// CreateDeployment creates a new deployment
-func (c *Client) CreateDeployment(ctx context.Context, namespace string, deployment *appsv1.Deployment) (*appsv1.Deployment, error) {
-	return c.clientset.AppsV1().Deployments(namespace).Create(ctx, deployment, metav1.CreateOptions{})
+// CreateDeployment creates a new deployment, or applies it with server-side apply when opts.Apply is set
+func (c *Client) CreateDeployment(ctx context.Context, namespace string, deployment *appsv1apply.DeploymentApplyConfiguration, opts WriteOptions) (*appsv1.Deployment, error) {
+	if opts.Apply {
+		return c.clientset.AppsV1().Deployments(namespace).Apply(ctx, deployment, opts.applyOptions())
+	}
+	created := &appsv1.Deployment{}
+	if err := fromApplyConfiguration(deployment, created); err != nil {
+		return nil, err
+	}
+	return c.clientset.AppsV1().Deployments(namespace).Create(ctx, created, opts.createOptions())
 }
 
 // GetDeployment gets a deployment by name and namespace
 func (c *Client) GetDeployment(ctx context.Context, namespace, name string) (*appsv1.Deployment, error) {
+	if cache := c.syncedCache(); cache != nil {
+		deployment, err := cache.deployments.Deployments(namespace).Get(name)
+		if err != nil {
+			return nil, err
+		}
+		return deployment.DeepCopy(), nil
+	}
 	return c.clientset.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
 }
 
-// ListDeployments lists all deployments in a namespace
-func (c *Client) ListDeployments(ctx context.Context, namespace string) (*appsv1.DeploymentList, error) {
-	return c.clientset.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{})
+// ListDeployments lists the deployments in a namespace that match the selectors of opts, one
+// page at a time when opts has a limit
+func (c *Client) ListDeployments(ctx context.Context, namespace string, opts metav1.ListOptions) (*appsv1.DeploymentList, error) {
+	cache, selector, err := c.listCache(opts)
+	if err != nil {
+		return nil, err
+	}
+	if cache != nil {
+		deployments, err := cache.deployments.Deployments(namespace).List(selector)
+		if err != nil {
+			return nil, err
+		}
+		return &appsv1.DeploymentList{Items: cachedItems(deployments)}, nil
+	}
+	return c.clientset.AppsV1().Deployments(namespace).List(ctx, opts)
 }
 
 // DeleteDeployment deletes a deployment
@@ -31,7 +64,7 @@ func (c *Client) DeleteDeployment(ctx context.Context, namespace, name string) e
 
 // ScaleDeployment scales a deployment to the specified number of replicas
 func (c *Client) ScaleDeployment(ctx context.Context, namespace, name string, replicas int32) error {
-	deployment, err := c.GetDeployment(ctx, namespace, name)
+	deployment, err := c.consistent().GetDeployment(ctx, namespace, name)
 	if err != nil {
 		return fmt.Errorf("failed to get deployment: %w", err)
 	}
@@ -41,19 +74,240 @@ func (c *Client) ScaleDeployment(ctx context.Context, namespace, name string, re
 	return err
 }
 
-// CreateService creates a new service
-func (c *Client) CreateService(ctx context.Context, namespace string, service *corev1.Service) (*corev1.Service, error) {
-	return c.clientset.CoreV1().Services(namespace).Create(ctx, service, metav1.CreateOptions{})
+const (
+	// RevisionAnnotation is set by the deployment controller on every ReplicaSet it owns
+	RevisionAnnotation = "deployment.kubernetes.io/revision"
+	// ChangeCauseAnnotation records why a revision was created
+	ChangeCauseAnnotation = "kubernetes.io/change-cause"
+	// RestartedAtAnnotation on the pod template triggers a rolling restart, as with kubectl rollout restart
+	RestartedAtAnnotation = "kubectl.kubernetes.io/restartedAt"
+)
+
+// UpdateDeploymentImage sets the image of a container in a deployment. When container is
+// empty, the deployment must have exactly one container.
+func (c *Client) UpdateDeploymentImage(ctx context.Context, namespace, name, container, image string) (*appsv1.Deployment, error) {
+	return c.updateDeployment(ctx, namespace, name, func(deployment *appsv1.Deployment) error {
+		containers := deployment.Spec.Template.Spec.Containers
+		if container == "" {
+			if len(containers) != 1 {
+				return apierrors.NewBadRequest(fmt.Sprintf("deployment has %d containers, a container name is required", len(containers)))
+			}
+			containers[0].Image = image
+			return nil
+		}
+
+		for i := range containers {
+			if containers[i].Name == container {
+				containers[i].Image = image
+				return nil
+			}
+		}
+		return apierrors.NewBadRequest(fmt.Sprintf("container %q not found in deployment", container))
+	})
+}
+
+// PauseDeployment stops the deployment controller from rolling out template changes
+func (c *Client) PauseDeployment(ctx context.Context, namespace, name string) (*appsv1.Deployment, error) {
+	return c.updateDeployment(ctx, namespace, name, func(deployment *appsv1.Deployment) error {
+		deployment.Spec.Paused = true
+		return nil
+	})
+}
+
+// ResumeDeployment resumes a paused deployment
+func (c *Client) ResumeDeployment(ctx context.Context, namespace, name string) (*appsv1.Deployment, error) {
+	return c.updateDeployment(ctx, namespace, name, func(deployment *appsv1.Deployment) error {
+		deployment.Spec.Paused = false
+		return nil
+	})
+}
+
+// RestartDeployment triggers a rolling restart by stamping the pod template
+func (c *Client) RestartDeployment(ctx context.Context, namespace, name string) (*appsv1.Deployment, error) {
+	return c.updateDeployment(ctx, namespace, name, func(deployment *appsv1.Deployment) error {
+		if deployment.Spec.Paused {
+			return apierrors.NewBadRequest("cannot restart a paused deployment, resume it first")
+		}
+		if deployment.Spec.Template.Annotations == nil {
+			deployment.Spec.Template.Annotations = map[string]string{}
+		}
+		deployment.Spec.Template.Annotations[RestartedAtAnnotation] = time.Now().Format(time.RFC3339)
+		return nil
+	})
+}
+
+// RollbackDeployment restores the pod template of a previous revision. A revision of 0
+// rolls back to the revision before the current one.
+func (c *Client) RollbackDeployment(ctx context.Context, namespace, name string, revision int64) (*appsv1.Deployment, error) {
+	return c.updateDeployment(ctx, namespace, name, func(deployment *appsv1.Deployment) error {
+		if deployment.Spec.Paused {
+			return apierrors.NewBadRequest("cannot roll back a paused deployment, resume it first")
+		}
+
+		replicaSets, err := c.consistent().ListDeploymentRevisions(ctx, deployment)
+		if err != nil {
+			return err
+		}
+
+		target, err := findRevision(replicaSets, revision)
+		if err != nil {
+			return err
+		}
+
+		template := target.Spec.Template.DeepCopy()
+		delete(template.Labels, appsv1.DefaultDeploymentUniqueLabelKey)
+		deployment.Spec.Template = *template
+		return nil
+	})
+}
+
+// ListDeploymentRevisions returns the ReplicaSets owned by a deployment, newest revision first
+func (c *Client) ListDeploymentRevisions(ctx context.Context, deployment *appsv1.Deployment) ([]appsv1.ReplicaSet, error) {
+	selector, err := metav1.LabelSelectorAsSelector(deployment.Spec.Selector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid deployment selector: %w", err)
+	}
+
+	var replicaSets []appsv1.ReplicaSet
+	if cache := c.syncedCache(); cache != nil {
+		cached, err := cache.replicaSets.ReplicaSets(deployment.Namespace).List(selector)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list replica sets: %w", err)
+		}
+		replicaSets = cachedItems(cached)
+	} else {
+		list, err := c.clientset.AppsV1().ReplicaSets(deployment.Namespace).List(ctx, metav1.ListOptions{
+			LabelSelector: selector.String(),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list replica sets: %w", err)
+		}
+		replicaSets = list.Items
+	}
+
+	owned := make([]appsv1.ReplicaSet, 0, len(replicaSets))
+	for _, rs := range replicaSets {
+		if metav1.IsControlledBy(&rs, deployment) {
+			owned = append(owned, rs)
+		}
+	}
+
+	sort.Slice(owned, func(i, j int) bool {
+		return Revision(&owned[i]) > Revision(&owned[j])
+	})
+	return owned, nil
+}
+
+// ListDeploymentPods returns the pods selected by a deployment's label selector
+func (c *Client) ListDeploymentPods(ctx context.Context, deployment *appsv1.Deployment) ([]corev1.Pod, error) {
+	selector, err := metav1.LabelSelectorAsSelector(deployment.Spec.Selector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid deployment selector: %w", err)
+	}
+
+	if cache := c.syncedCache(); cache != nil {
+		pods, err := cache.pods.Pods(deployment.Namespace).List(selector)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list pods: %w", err)
+		}
+		return cachedItems(pods), nil
+	}
+
+	list, err := c.clientset.CoreV1().Pods(deployment.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: selector.String(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+	return list.Items, nil
+}
+
+// Revision returns the deployment revision recorded on a ReplicaSet, or 0 if it has none
+func Revision(rs *appsv1.ReplicaSet) int64 {
+	revision, err := strconv.ParseInt(rs.Annotations[RevisionAnnotation], 10, 64)
+	if err != nil {
+		return 0
+	}
+	return revision
+}
+
+// findRevision picks the ReplicaSet for a revision from a newest-first list
+func findRevision(replicaSets []appsv1.ReplicaSet, revision int64) (*appsv1.ReplicaSet, error) {
+	if revision == 0 {
+		if len(replicaSets) < 2 {
+			return nil, apierrors.NewBadRequest("no previous revision to roll back to")
+		}
+		return &replicaSets[1], nil
+	}
+
+	for i := range replicaSets {
+		if Revision(&replicaSets[i]) == revision {
+			return &replicaSets[i], nil
+		}
+	}
+	return nil, apierrors.NewBadRequest(fmt.Sprintf("revision %d not found", revision))
+}
+
+// updateDeployment applies a mutation to the latest version of a deployment, retrying on conflicts
+func (c *Client) updateDeployment(ctx context.Context, namespace, name string, mutate func(*appsv1.Deployment) error) (*appsv1.Deployment, error) {
+	var updated *appsv1.Deployment
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		deployment, err := c.consistent().GetDeployment(ctx, namespace, name)
+		if err != nil {
+			return err
+		}
+
+		if err := mutate(deployment); err != nil {
+			return err
+		}
+
+		updated, err = c.clientset.AppsV1().Deployments(namespace).Update(ctx, deployment, metav1.UpdateOptions{
+			FieldManager: FieldManager,
+		})
+		return err
+	})
+	return updated, err
+}
+
+// CreateService creates a new service, or applies it with server-side apply when opts.Apply is set
+func (c *Client) CreateService(ctx context.Context, namespace string, service *corev1apply.ServiceApplyConfiguration, opts WriteOptions) (*corev1.Service, error) {
+	if opts.Apply {
+		return c.clientset.CoreV1().Services(namespace).Apply(ctx, service, opts.applyOptions())
+	}
+	created := &corev1.Service{}
+	if err := fromApplyConfiguration(service, created); err != nil {
+		return nil, err
+	}
+	return c.clientset.CoreV1().Services(namespace).Create(ctx, created, opts.createOptions())
 }
 
 // GetService gets a service by name and namespace
 func (c *Client) GetService(ctx context.Context, namespace, name string) (*corev1.Service, error) {
+	if cache := c.syncedCache(); cache != nil {
+		service, err := cache.services.Services(namespace).Get(name)
+		if err != nil {
+			return nil, err
+		}
+		return service.DeepCopy(), nil
+	}
 	return c.clientset.CoreV1().Services(namespace).Get(ctx, name, metav1.GetOptions{})
 }
 
-// ListServices lists all services in a namespace
-func (c *Client) ListServices(ctx context.Context, namespace string) (*corev1.ServiceList, error) {
-	return c.clientset.CoreV1().Services(namespace).List(ctx, metav1.ListOptions{})
+// ListServices lists the services in a namespace that match the selectors of opts, one page at
+// a time when opts has a limit
+func (c *Client) ListServices(ctx context.Context, namespace string, opts metav1.ListOptions) (*corev1.ServiceList, error) {
+	cache, selector, err := c.listCache(opts)
+	if err != nil {
+		return nil, err
+	}
+	if cache != nil {
+		services, err := cache.services.Services(namespace).List(selector)
+		if err != nil {
+			return nil, err
+		}
+		return &corev1.ServiceList{Items: cachedItems(services)}, nil
+	}
+	return c.clientset.CoreV1().Services(namespace).List(ctx, opts)
 }
 
 // DeleteService deletes a service
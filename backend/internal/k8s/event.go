@@ -0,0 +1,52 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+)
+
+// ListEvents lists events in a namespace (or all namespaces if namespace is empty), optionally
+// limited to the object with the given kind and name, that match the selectors of opts
+func (c *Client) ListEvents(ctx context.Context, namespace, kind, name string, opts metav1.ListOptions) (*corev1.EventList, error) {
+	cache, selector, err := c.listCache(opts)
+	if err != nil {
+		return nil, err
+	}
+	if cache != nil {
+		events, err := cache.events.Events(namespace).List(selector)
+		if err != nil {
+			return nil, err
+		}
+
+		matching := events[:0]
+		for _, event := range events {
+			if (kind == "" || event.InvolvedObject.Kind == kind) && (name == "" || event.InvolvedObject.Name == name) {
+				matching = append(matching, event)
+			}
+		}
+		return &corev1.EventList{Items: cachedItems(matching)}, nil
+	}
+
+	selectors := make([]fields.Selector, 0, 3)
+	if kind != "" {
+		selectors = append(selectors, fields.OneTermEqualSelector("involvedObject.kind", kind))
+	}
+	if name != "" {
+		selectors = append(selectors, fields.OneTermEqualSelector("involvedObject.name", name))
+	}
+	if opts.FieldSelector != "" {
+		selector, err := fields.ParseSelector(opts.FieldSelector)
+		if err != nil {
+			return nil, apierrors.NewBadRequest(fmt.Sprintf("invalid field selector: %v", err))
+		}
+		selectors = append(selectors, selector)
+	}
+
+	opts.FieldSelector = fields.AndSelectors(selectors...).String()
+	return c.clientset.CoreV1().Events(namespace).List(ctx, opts)
+}
@@ -0,0 +1,71 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/remotecommand"
+	utilexec "k8s.io/client-go/util/exec"
+)
+
+// ExecOptions describes a command to run inside a pod container
+type ExecOptions struct {
+	Container string
+	Command   []string
+	Stdin     io.Reader
+	Stdout    io.Writer
+	Stderr    io.Writer
+	TTY       bool
+	// Resize delivers terminal size changes when TTY is set
+	Resize remotecommand.TerminalSizeQueue
+}
+
+// ExecPod runs a command in a pod container through the pods/exec subresource and blocks until
+// it exits or ctx is cancelled. It returns the command's exit code when it ran but failed.
+func (c *Client) ExecPod(ctx context.Context, namespace, name string, opts ExecOptions) (int, error) {
+	if c.config == nil {
+		return 0, ErrNotSupported
+	}
+
+	req := c.clientset.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Namespace(namespace).
+		Name(name).
+		SubResource("exec").
+		VersionedParams(&corev1.PodExecOptions{
+			Container: opts.Container,
+			Command:   opts.Command,
+			Stdin:     opts.Stdin != nil,
+			Stdout:    opts.Stdout != nil,
+			// With a TTY the container's stderr is merged into stdout
+			Stderr: opts.Stderr != nil && !opts.TTY,
+			TTY:    opts.TTY,
+		}, scheme.ParameterCodec)
+
+	executor, err := remotecommand.NewSPDYExecutor(c.config, "POST", req.URL())
+	if err != nil {
+		return 0, fmt.Errorf("failed to create executor: %w", err)
+	}
+
+	streamOpts := remotecommand.StreamOptions{
+		Stdin:             opts.Stdin,
+		Stdout:            opts.Stdout,
+		Tty:               opts.TTY,
+		TerminalSizeQueue: opts.Resize,
+	}
+	if !opts.TTY {
+		streamOpts.Stderr = opts.Stderr
+	}
+
+	err = executor.StreamWithContext(ctx, streamOpts)
+
+	var exitErr utilexec.CodeExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.Code, nil
+	}
+	return 0, err
+}
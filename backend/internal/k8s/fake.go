@@ -0,0 +1,497 @@
+package k8s
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+// ErrNotSupported is returned for operations the fake cluster cannot perform, such as exec
+// and proxying, which need a real API server
+var ErrNotSupported = errors.New("not supported by the fake cluster")
+
+// clusterScopedKinds are the built-in kinds that do not live in a namespace
+var clusterScopedKinds = map[string]bool{
+	"Namespace":                      true,
+	"Node":                           true,
+	"PersistentVolume":               true,
+	"ClusterRole":                    true,
+	"ClusterRoleBinding":             true,
+	"StorageClass":                   true,
+	"PriorityClass":                  true,
+	"IngressClass":                   true,
+	"RuntimeClass":                   true,
+	"CSIDriver":                      true,
+	"CSINode":                        true,
+	"VolumeAttachment":               true,
+	"MutatingWebhookConfiguration":   true,
+	"ValidatingWebhookConfiguration": true,
+	"CertificateSigningRequest":      true,
+}
+
+// NewFakeClient creates a client backed by an in-memory cluster seeded with sample namespaces
+// and workloads, so that the API and the frontend can run without a real cluster. There are no
+// controllers: pods and deployments are reported ready as soon as they are written, and
+// scaling a deployment does not create or delete pods.
+func NewFakeClient() *Client {
+	clientset := fake.NewClientset(fakeClusterObjects()...)
+	clientset.PrependReactor("list", "*", selectAndPage(clientset.Tracker()))
+	clientset.PrependReactor("create", "pods", markPodRunning)
+	clientset.PrependReactor("*", "deployments", markDeploymentAvailable)
+
+	// Manifests are applied through the dynamic client. It shares the typed clientset's objects,
+	// converted to and from their unstructured form.
+	dynamicClient := dynamicfake.NewSimpleDynamicClient(scheme.Scheme)
+	dynamicClient.PrependReactor("*", "*", unstructuredReaction(clienttesting.ObjectReaction(clientset.Tracker())))
+
+	return &Client{
+		clientset: clientset,
+		dynamic:   dynamicClient,
+		mapper:    fakeRESTMapper(),
+	}
+}
+
+// fakeRESTMapper maps every built-in kind to its resource, as discovery would for a real cluster
+func fakeRESTMapper() meta.ResettableRESTMapper {
+	mapper := meta.NewDefaultRESTMapper(scheme.Scheme.PrioritizedVersionsAllGroups())
+	for gvk := range scheme.Scheme.AllKnownTypes() {
+		if gvk.Version == runtime.APIVersionInternal || strings.HasSuffix(gvk.Kind, "List") {
+			continue
+		}
+		scope := meta.RESTScopeNamespace
+		if clusterScopedKinds[gvk.Kind] {
+			scope = meta.RESTScopeRoot
+		}
+		mapper.Add(gvk, scope)
+	}
+	return staticRESTMapper{mapper}
+}
+
+// staticRESTMapper is a RESTMapper whose mappings never change, so resetting it does nothing
+type staticRESTMapper struct {
+	meta.RESTMapper
+}
+
+func (staticRESTMapper) Reset() {}
+
+// unstructuredReaction converts the typed objects returned by a reaction into the unstructured
+// objects the dynamic client expects
+func unstructuredReaction(react clienttesting.ReactionFunc) clienttesting.ReactionFunc {
+	return func(action clienttesting.Action) (bool, runtime.Object, error) {
+		handled, obj, err := react(action)
+		if err != nil || obj == nil {
+			return handled, obj, err
+		}
+		if _, ok := obj.(runtime.Unstructured); ok {
+			return handled, obj, nil
+		}
+
+		gvks, _, err := scheme.Scheme.ObjectKinds(obj)
+		if err != nil {
+			return handled, nil, err
+		}
+		content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+		if err != nil {
+			return handled, nil, err
+		}
+
+		converted := &unstructured.Unstructured{Object: content}
+		converted.SetGroupVersionKind(gvks[0])
+		return handled, converted, nil
+	}
+}
+
+// selectAndPage applies the field selector and the limit and continue token of list options,
+// which the fake clientset ignores, as the API server would. Objects are listed by namespace
+// and name, and continue tokens hold the position of the next one.
+func selectAndPage(tracker clienttesting.ObjectTracker) clienttesting.ReactionFunc {
+	return func(action clienttesting.Action) (bool, runtime.Object, error) {
+		list, ok := action.(clienttesting.ListActionImpl)
+		if !ok {
+			return false, nil, nil
+		}
+		opts := list.ListOptions
+		if opts.Limit <= 0 && opts.Continue == "" && opts.FieldSelector == "" {
+			return false, nil, nil
+		}
+
+		listed, err := tracker.List(list.GetResource(), list.GetKind(), list.GetNamespace(), opts)
+		if err != nil {
+			return true, nil, err
+		}
+		items, err := meta.ExtractList(listed)
+		if err != nil {
+			return true, nil, err
+		}
+
+		restrictions := list.GetListRestrictions()
+		matching := make([]runtime.Object, 0, len(items))
+		for _, item := range items {
+			accessor, err := meta.Accessor(item)
+			if err != nil {
+				continue
+			}
+			fieldSet := fakeFieldSet(item, accessor)
+			for _, requirement := range restrictions.Fields.Requirements() {
+				if _, ok := fieldSet[requirement.Field]; !ok {
+					return true, nil, apierrors.NewBadRequest(fmt.Sprintf("field label not supported: %s", requirement.Field))
+				}
+			}
+			if restrictions.Labels.Matches(labels.Set(accessor.GetLabels())) && restrictions.Fields.Matches(fieldSet) {
+				matching = append(matching, item)
+			}
+		}
+		slices.SortFunc(matching, func(a, b runtime.Object) int {
+			first, _ := meta.Accessor(a)
+			second, _ := meta.Accessor(b)
+			return cmp.Or(strings.Compare(first.GetNamespace(), second.GetNamespace()), strings.Compare(first.GetName(), second.GetName()))
+		})
+
+		start := 0
+		if opts.Continue != "" {
+			start, err = strconv.Atoi(opts.Continue)
+			if err != nil || start < 0 || start > len(matching) {
+				return true, nil, apierrors.NewBadRequest("continue key is not valid")
+			}
+		}
+		end := len(matching)
+		if opts.Limit > 0 && int64(end-start) > opts.Limit {
+			end = start + int(opts.Limit)
+		}
+		if err := meta.SetList(listed, matching[start:end]); err != nil {
+			return true, nil, err
+		}
+
+		listMeta, err := meta.ListAccessor(listed)
+		if err != nil {
+			return true, nil, err
+		}
+		listMeta.SetContinue("")
+		listMeta.SetRemainingItemCount(nil)
+		if end < len(matching) {
+			remaining := int64(len(matching) - end)
+			listMeta.SetContinue(strconv.Itoa(end))
+			listMeta.SetRemainingItemCount(&remaining)
+		}
+		return true, listed, nil
+	}
+}
+
+// fakeFieldSet returns the fields of an object that field selectors can match, a subset of
+// those the API server supports
+func fakeFieldSet(obj runtime.Object, accessor metav1.Object) fields.Set {
+	set := fields.Set{
+		"metadata.name":      accessor.GetName(),
+		"metadata.namespace": accessor.GetNamespace(),
+	}
+	switch object := obj.(type) {
+	case *corev1.Pod:
+		set["spec.nodeName"] = object.Spec.NodeName
+		set["spec.restartPolicy"] = string(object.Spec.RestartPolicy)
+		set["spec.serviceAccountName"] = object.Spec.ServiceAccountName
+		set["status.phase"] = string(object.Status.Phase)
+		set["status.podIP"] = object.Status.PodIP
+	case *corev1.Service:
+		set["spec.clusterIP"] = object.Spec.ClusterIP
+		set["spec.type"] = string(object.Spec.Type)
+	case *corev1.Event:
+		set["involvedObject.kind"] = object.InvolvedObject.Kind
+		set["involvedObject.namespace"] = object.InvolvedObject.Namespace
+		set["involvedObject.name"] = object.InvolvedObject.Name
+		set["involvedObject.uid"] = string(object.InvolvedObject.UID)
+		set["reason"] = object.Reason
+		set["source"] = object.Source.Component
+		set["type"] = object.Type
+	case *corev1.Namespace:
+		set["status.phase"] = string(object.Status.Phase)
+	case *appsv1.ReplicaSet:
+		set["status.replicas"] = strconv.Itoa(int(object.Status.Replicas))
+	}
+	return set
+}
+
+// markPodRunning reports new pods as running, since there is no kubelet to start them
+func markPodRunning(action clienttesting.Action) (bool, runtime.Object, error) {
+	if pod, ok := action.(clienttesting.CreateAction).GetObject().(*corev1.Pod); ok {
+		pod.Status = runningPodStatus(pod.Spec, len(pod.Name))
+	}
+	return false, nil, nil
+}
+
+// markDeploymentAvailable reports created and updated deployments as fully rolled out, since
+// there is no deployment controller
+func markDeploymentAvailable(action clienttesting.Action) (bool, runtime.Object, error) {
+	var object runtime.Object
+	switch action := action.(type) {
+	case clienttesting.CreateAction:
+		object = action.GetObject()
+	case clienttesting.UpdateAction:
+		object = action.GetObject()
+	}
+
+	if deployment, ok := object.(*appsv1.Deployment); ok && action.GetSubresource() == "" {
+		replicas := int32(1)
+		if deployment.Spec.Replicas != nil {
+			replicas = *deployment.Spec.Replicas
+		}
+		deployment.Generation++
+		deployment.Status = appsv1.DeploymentStatus{
+			ObservedGeneration: deployment.Generation,
+			Replicas:           replicas,
+			UpdatedReplicas:    replicas,
+			ReadyReplicas:      replicas,
+			AvailableReplicas:  replicas,
+		}
+	}
+	return false, nil, nil
+}
+
+// fakeClusterObjects returns the sample namespaces and workloads of the fake cluster
+func fakeClusterObjects() []runtime.Object {
+	objects := []runtime.Object{
+		fakeNamespace("default"),
+		fakeNamespace("kube-system"),
+		fakeNamespace("demo"),
+		fakeNamespace("staging"),
+	}
+
+	objects = append(objects, fakeDeployment("default", "web", "nginx:1.27", 3, 80, "nginx:1.25", "nginx:1.27")...)
+	objects = append(objects, fakeService("default", "web", corev1.ServiceTypeClusterIP, 80))
+	objects = append(objects, fakeDeployment("default", "redis", "redis:7.4", 1, 6379, "redis:7.4")...)
+	objects = append(objects, fakeService("default", "redis", corev1.ServiceTypeClusterIP, 6379))
+
+	objects = append(objects, fakeDeployment("demo", "api", "hashicorp/http-echo:1.0", 2, 5678, "hashicorp/http-echo:0.2.3", "hashicorp/http-echo:1.0")...)
+	objects = append(objects, fakeService("demo", "api", corev1.ServiceTypeNodePort, 5678))
+
+	objects = append(objects, fakeDeployment("kube-system", "coredns", "registry.k8s.io/coredns/coredns:v1.11.3", 2, 53, "registry.k8s.io/coredns/coredns:v1.11.3")...)
+
+	debug := fakePod("staging", "debug", "busybox:1.36", nil, nil)
+	crashing := fakePod("staging", "broken", "busybox:1.36", nil, nil)
+	crashing.Status.Phase = corev1.PodRunning
+	crashing.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionFalse, Reason: "ContainersNotReady"}}
+	crashing.Status.ContainerStatuses[0].Ready = false
+	crashing.Status.ContainerStatuses[0].RestartCount = 7
+	crashing.Status.ContainerStatuses[0].State = corev1.ContainerState{
+		Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff", Message: "back-off 5m0s restarting failed container"},
+	}
+	objects = append(objects, debug, crashing)
+
+	objects = append(objects,
+		fakeEvent("default", "Deployment", "web", corev1.EventTypeNormal, "ScalingReplicaSet", "Scaled up replica set web-2 to 3"),
+		fakeEvent("staging", "Pod", "debug", corev1.EventTypeNormal, "Started", "Started container debug"),
+		fakeEvent("staging", "Pod", "broken", corev1.EventTypeWarning, "BackOff", "Back-off restarting failed container broken in pod broken"),
+	)
+
+	return objects
+}
+
+func fakeNamespace(name string) *corev1.Namespace {
+	return &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: name, CreationTimestamp: fakeCreationTime()},
+		Status:     corev1.NamespaceStatus{Phase: corev1.NamespaceActive},
+	}
+}
+
+// fakeDeployment returns a deployment with one ReplicaSet per image of its revision history,
+// oldest first, and running pods for the latest one
+func fakeDeployment(namespace, name, image string, replicas, port int32, history ...string) []runtime.Object {
+	labels := map[string]string{"app": name}
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         namespace,
+			UID:               fakeUID(namespace, "deployment", name),
+			Labels:            labels,
+			Generation:        int64(len(history)),
+			CreationTimestamp: fakeCreationTime(),
+			Annotations:       map[string]string{RevisionAnnotation: strconv.Itoa(len(history))},
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: fakePodTemplate(labels, name, image, port),
+		},
+		Status: appsv1.DeploymentStatus{
+			ObservedGeneration: int64(len(history)),
+			Replicas:           replicas,
+			UpdatedReplicas:    replicas,
+			ReadyReplicas:      replicas,
+			AvailableReplicas:  replicas,
+		},
+	}
+	objects := []runtime.Object{deployment}
+
+	for i, revisionImage := range history {
+		revision := i + 1
+		hash := strconv.Itoa(revision)
+		rsLabels := map[string]string{"app": name, appsv1.DefaultDeploymentUniqueLabelKey: hash}
+		rsReplicas := int32(0)
+		if revision == len(history) {
+			rsReplicas = replicas
+		}
+
+		rs := &appsv1.ReplicaSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              name + "-" + hash,
+				Namespace:         namespace,
+				UID:               fakeUID(namespace, "replicaset", name+"-"+hash),
+				Labels:            rsLabels,
+				CreationTimestamp: fakeCreationTime(),
+				Annotations:       map[string]string{RevisionAnnotation: strconv.Itoa(revision)},
+				OwnerReferences:   []metav1.OwnerReference{*metav1.NewControllerRef(deployment, appsv1.SchemeGroupVersion.WithKind("Deployment"))},
+			},
+			Spec: appsv1.ReplicaSetSpec{
+				Replicas: &rsReplicas,
+				Selector: &metav1.LabelSelector{MatchLabels: rsLabels},
+				Template: fakePodTemplate(rsLabels, name, revisionImage, port),
+			},
+			Status: appsv1.ReplicaSetStatus{Replicas: rsReplicas, ReadyReplicas: rsReplicas, AvailableReplicas: rsReplicas},
+		}
+		objects = append(objects, rs)
+
+		for p := int32(0); p < rsReplicas; p++ {
+			podName := fmt.Sprintf("%s-%s-%d", name, hash, p)
+			objects = append(objects, fakePod(namespace, podName, revisionImage, rsLabels, rs))
+		}
+	}
+
+	return objects
+}
+
+func fakePodTemplate(labels map[string]string, name, image string, port int32) corev1.PodTemplateSpec {
+	return corev1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{Labels: labels},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{
+				Name:  name,
+				Image: image,
+				Ports: []corev1.ContainerPort{{ContainerPort: port, Protocol: corev1.ProtocolTCP}},
+			}},
+		},
+	}
+}
+
+// fakePod returns a running pod, owned by owner when it is set
+func fakePod(namespace, name, image string, labels map[string]string, owner *appsv1.ReplicaSet) *corev1.Pod {
+	if labels == nil {
+		labels = map[string]string{"run": name}
+	}
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         namespace,
+			UID:               fakeUID(namespace, "pod", name),
+			Labels:            labels,
+			CreationTimestamp: fakeCreationTime(),
+		},
+		Spec: corev1.PodSpec{
+			NodeName:   "fake-node",
+			Containers: []corev1.Container{{Name: name, Image: image}},
+		},
+	}
+	if owner != nil {
+		pod.OwnerReferences = []metav1.OwnerReference{*metav1.NewControllerRef(owner, appsv1.SchemeGroupVersion.WithKind("ReplicaSet"))}
+		pod.Spec = *owner.Spec.Template.Spec.DeepCopy()
+		pod.Spec.NodeName = "fake-node"
+	}
+	pod.Status = runningPodStatus(pod.Spec, len(namespace)+len(name))
+	return pod
+}
+
+// runningPodStatus returns the status of a pod whose containers are all running and ready.
+// seed varies the pod IP.
+func runningPodStatus(spec corev1.PodSpec, seed int) corev1.PodStatus {
+	started := metav1.NewTime(time.Now().Add(-time.Hour))
+	status := corev1.PodStatus{
+		Phase:     corev1.PodRunning,
+		HostIP:    "10.0.0.10",
+		PodIP:     fmt.Sprintf("10.244.0.%d", 10+seed%240),
+		StartTime: &started,
+		Conditions: []corev1.PodCondition{
+			{Type: corev1.PodReady, Status: corev1.ConditionTrue},
+			{Type: corev1.ContainersReady, Status: corev1.ConditionTrue},
+		},
+	}
+	for _, container := range spec.Containers {
+		status.ContainerStatuses = append(status.ContainerStatuses, corev1.ContainerStatus{
+			Name:  container.Name,
+			Image: container.Image,
+			Ready: true,
+			State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{StartedAt: started}},
+		})
+	}
+	return status
+}
+
+func fakeService(namespace, name string, serviceType corev1.ServiceType, port int32) *corev1.Service {
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         namespace,
+			UID:               fakeUID(namespace, "service", name),
+			CreationTimestamp: fakeCreationTime(),
+		},
+		Spec: corev1.ServiceSpec{
+			Type:      serviceType,
+			Selector:  map[string]string{"app": name},
+			ClusterIP: fmt.Sprintf("10.96.0.%d", 10+len(namespace)+len(name)),
+			Ports: []corev1.ServicePort{{
+				Port:       port,
+				TargetPort: intstr.FromInt32(port),
+				Protocol:   corev1.ProtocolTCP,
+			}},
+		},
+	}
+	if serviceType == corev1.ServiceTypeNodePort {
+		service.Spec.Ports[0].NodePort = 30000 + port%2768
+	}
+	return service
+}
+
+func fakeEvent(namespace, kind, name, eventType, reason, message string) *corev1.Event {
+	now := metav1.NewTime(time.Now().Add(-10 * time.Minute))
+	return &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s.%s", name, reason),
+			Namespace: namespace,
+		},
+		InvolvedObject: corev1.ObjectReference{Kind: kind, Name: name, Namespace: namespace},
+		Type:           eventType,
+		Reason:         reason,
+		Message:        message,
+		Count:          1,
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Source:         corev1.EventSource{Component: "fake-cluster"},
+	}
+}
+
+// fakeUID returns a stable UID, so that owner references resolve
+func fakeUID(namespace, kind, name string) types.UID {
+	return types.UID(fmt.Sprintf("fake-%s-%s-%s", kind, namespace, name))
+}
+
+func fakeCreationTime() metav1.Time {
+	return metav1.NewTime(time.Now().Add(-24 * time.Hour))
+}
@@ -0,0 +1,48 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestFakeClientPagesAndSelectsFields(t *testing.T) {
+	pods := NewFakeClient().clientset.CoreV1().Pods("")
+	ctx := context.Background()
+
+	var names []string
+	opts := metav1.ListOptions{Limit: 4}
+	for {
+		page, err := pods.List(ctx, opts)
+		if err != nil {
+			t.Fatalf("failed to list pods: %v", err)
+		}
+		if len(page.Items) > 4 {
+			t.Fatalf("expected at most 4 pods per page, got %d", len(page.Items))
+		}
+		for _, pod := range page.Items {
+			names = append(names, pod.Namespace+"/"+pod.Name)
+		}
+		if page.Continue == "" {
+			break
+		}
+		opts.Continue = page.Continue
+	}
+	if len(names) != 10 || names[0] != "default/redis-1-0" || names[9] != "staging/debug" {
+		t.Errorf("unexpected pods %v", names)
+	}
+
+	selected, err := pods.List(ctx, metav1.ListOptions{FieldSelector: "metadata.name=debug"})
+	if err != nil {
+		t.Fatalf("failed to list pods: %v", err)
+	}
+	if len(selected.Items) != 1 || selected.Items[0].Name != "debug" {
+		t.Errorf("expected only the debug pod, got %d pods", len(selected.Items))
+	}
+
+	if _, err := pods.List(ctx, metav1.ListOptions{FieldSelector: "spec.unknown=x"}); !apierrors.IsBadRequest(err) {
+		t.Errorf("expected a bad request for an unsupported field, got %v", err)
+	}
+}
@@ -0,0 +1,206 @@
+package k8s
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/dynamic"
+)
+
+// Manifest apply actions
+const (
+	ApplyActionCreated   = "created"
+	ApplyActionUpdated   = "updated"
+	ApplyActionUnchanged = "unchanged"
+)
+
+// applyOrder ranks kinds so that dependencies are applied before the objects that use them
+var applyOrder = map[string]int{
+	"Namespace":                0,
+	"CustomResourceDefinition": 0,
+	"ServiceAccount":           1,
+	"ConfigMap":                1,
+	"Secret":                   1,
+	"PersistentVolume":         1,
+	"PersistentVolumeClaim":    1,
+	"Role":                     1,
+	"ClusterRole":              1,
+	"RoleBinding":              1,
+	"ClusterRoleBinding":       1,
+	"Deployment":               2,
+	"StatefulSet":              2,
+	"DaemonSet":                2,
+	"ReplicaSet":               2,
+	"Pod":                      2,
+	"Job":                      2,
+	"CronJob":                  2,
+	"Service":                  3,
+}
+
+// manifestRank returns the apply order of a kind, unknown kinds go last
+func manifestRank(kind string) int {
+	if rank, ok := applyOrder[kind]; ok {
+		return rank
+	}
+	return 4
+}
+
+// DecodeManifests parses multi-document YAML or JSON into unstructured objects.
+// Empty documents are skipped and List kinds are expanded into their items.
+func DecodeManifests(data []byte) ([]*unstructured.Unstructured, error) {
+	decoder := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096)
+
+	objects := make([]*unstructured.Unstructured, 0)
+	for index := 0; ; index++ {
+		var raw map[string]interface{}
+		if err := decoder.Decode(&raw); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("failed to decode document %d: %w", index, err)
+		}
+		if len(raw) == 0 {
+			continue
+		}
+
+		obj := &unstructured.Unstructured{Object: raw}
+		if obj.IsList() {
+			list, err := obj.ToList()
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode list in document %d: %w", index, err)
+			}
+			for i := range list.Items {
+				objects = append(objects, &list.Items[i])
+			}
+			continue
+		}
+
+		if obj.GetAPIVersion() == "" || obj.GetKind() == "" {
+			return nil, fmt.Errorf("document %d is missing apiVersion or kind", index)
+		}
+		objects = append(objects, obj)
+	}
+
+	return objects, nil
+}
+
+// SortManifests orders objects so that namespaces and configuration are applied
+// before workloads, and workloads before services. The original order is kept
+// within each group.
+func SortManifests(objects []*unstructured.Unstructured) {
+	sort.SliceStable(objects, func(i, j int) bool {
+		return manifestRank(objects[i].GetKind()) < manifestRank(objects[j].GetKind())
+	})
+}
+
+// ApplyObject applies a single object with server-side apply and reports whether
+// it was created, updated or left unchanged. Namespaced objects without a
+// namespace are placed into defaultNamespace. Only opts.DryRun is honoured,
+// manifests are always applied.
+func (c *Client) ApplyObject(ctx context.Context, defaultNamespace string, obj *unstructured.Unstructured, opts WriteOptions) (string, *unstructured.Unstructured, error) {
+	if obj.GetName() == "" {
+		return "", nil, fmt.Errorf("metadata.name is required")
+	}
+
+	resource, err := c.resourceFor(obj, defaultNamespace)
+	if err != nil {
+		return "", nil, err
+	}
+
+	existing, err := resource.Get(ctx, obj.GetName(), metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return "", nil, err
+	}
+
+	applied, err := resource.Apply(ctx, obj.GetName(), obj, opts.applyOptions())
+	if err != nil {
+		return "", nil, err
+	}
+
+	switch {
+	case existing == nil:
+		return ApplyActionCreated, applied, nil
+	case !objectChanged(existing, applied):
+		return ApplyActionUnchanged, applied, nil
+	default:
+		return ApplyActionUpdated, applied, nil
+	}
+}
+
+// objectChanged compares two revisions of an object while ignoring server-maintained
+// fields. The resourceVersion cannot be used for this because dry-run requests never bump it.
+func objectChanged(before, after *unstructured.Unstructured) bool {
+	strip := func(obj *unstructured.Unstructured) map[string]interface{} {
+		content := obj.DeepCopy().Object
+		unstructured.RemoveNestedField(content, "metadata", "resourceVersion")
+		unstructured.RemoveNestedField(content, "metadata", "managedFields")
+		unstructured.RemoveNestedField(content, "metadata", "generation")
+		unstructured.RemoveNestedField(content, "status")
+		return content
+	}
+	return !equality.Semantic.DeepEqual(strip(before), strip(after))
+}
+
+// ResolveNamespace sets the namespace an object will be applied to the same way ApplyObject
+// does and reports whether the object is namespaced. Cluster-scoped objects end up without one.
+func (c *Client) ResolveNamespace(obj *unstructured.Unstructured, defaultNamespace string) (bool, error) {
+	mapping, err := c.restMapping(obj.GroupVersionKind())
+	if err != nil {
+		return false, err
+	}
+
+	if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+		obj.SetNamespace("")
+		return false, nil
+	}
+
+	if obj.GetNamespace() == "" {
+		obj.SetNamespace(defaultNamespace)
+	}
+	return true, nil
+}
+
+// resourceFor resolves the dynamic resource interface for an object through discovery
+func (c *Client) resourceFor(obj *unstructured.Unstructured, defaultNamespace string) (dynamic.ResourceInterface, error) {
+	gvk := obj.GroupVersionKind()
+
+	mapping, err := c.restMapping(gvk)
+	if err != nil {
+		return nil, err
+	}
+
+	if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+		obj.SetNamespace("")
+		return c.dynamic.Resource(mapping.Resource), nil
+	}
+
+	if obj.GetNamespace() == "" {
+		obj.SetNamespace(defaultNamespace)
+	}
+	return c.dynamic.Resource(mapping.Resource).Namespace(obj.GetNamespace()), nil
+}
+
+// restMapping maps a GroupVersionKind to a resource, refreshing discovery once on a miss
+// so that recently installed CRDs are picked up
+func (c *Client) restMapping(gvk schema.GroupVersionKind) (*meta.RESTMapping, error) {
+	mapping, err := c.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if meta.IsNoMatchError(err) {
+		c.mapper.Reset()
+		mapping, err = c.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve resource for %s: %w", gvk.String(), err)
+	}
+	return mapping, nil
+}
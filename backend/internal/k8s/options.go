@@ -0,0 +1,55 @@
+package k8s
+
+import (
+	"encoding/json"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// FieldManager is the field manager name recorded for every write made by kube-deploy
+const FieldManager = "kube-deploy"
+
+// WriteOptions controls how objects are written to the cluster
+type WriteOptions struct {
+	// Apply uses server-side apply instead of create, so existing objects are updated
+	Apply bool
+	// DryRun validates the request on the API server without persisting anything
+	DryRun bool
+}
+
+func (o WriteOptions) dryRun() []string {
+	if o.DryRun {
+		return []string{metav1.DryRunAll}
+	}
+	return nil
+}
+
+func (o WriteOptions) createOptions() metav1.CreateOptions {
+	return metav1.CreateOptions{
+		FieldManager: FieldManager,
+		DryRun:       o.dryRun(),
+	}
+}
+
+func (o WriteOptions) applyOptions() metav1.ApplyOptions {
+	return metav1.ApplyOptions{
+		FieldManager: FieldManager,
+		Force:        true,
+		DryRun:       o.dryRun(),
+	}
+}
+
+// fromApplyConfiguration converts an apply configuration into the typed object it describes, for
+// writes that create the object instead of applying it
+func fromApplyConfiguration(cfg any, obj runtime.Object) error {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to encode %T: %w", cfg, err)
+	}
+	if err := json.Unmarshal(data, obj); err != nil {
+		return fmt.Errorf("failed to decode %T: %w", cfg, err)
+	}
+	return nil
+}
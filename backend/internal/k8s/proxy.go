@@ -0,0 +1,40 @@
+package k8s
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"k8s.io/client-go/rest"
+)
+
+// ProxyURL returns the API server proxy subresource URL for a pod or service port.
+// resource is "pods" or "services" and port is a port number or name, optionally prefixed
+// with the scheme to use, e.g. "https:8443".
+func (c *Client) ProxyURL(resource, namespace, name, port string) *url.URL {
+	target := name + ":" + port
+	if scheme, portName, found := strings.Cut(port, ":"); found {
+		target = scheme + ":" + name + ":" + portName
+	}
+
+	if c.config == nil {
+		return &url.URL{Path: "/api/v1/namespaces/" + namespace + "/" + resource + "/" + target + "/proxy"}
+	}
+
+	return c.clientset.CoreV1().RESTClient().Get().
+		Resource(resource).
+		Namespace(namespace).
+		Name(target).
+		SubResource("proxy").
+		URL()
+}
+
+// ProxyTransport returns a transport that authenticates to the API server with the client's
+// credentials. Any Authorization header already on a request is sent as is, so callers must
+// remove it first.
+func (c *Client) ProxyTransport() (http.RoundTripper, error) {
+	if c.config == nil {
+		return nil, ErrNotSupported
+	}
+	return rest.TransportFor(c.config)
+}
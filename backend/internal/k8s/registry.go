@@ -0,0 +1,252 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"log"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Sources a cluster in the registry was loaded from
+const (
+	ClusterSourceInCluster  = "in-cluster"
+	ClusterSourceKubeconfig = "kubeconfig"
+	ClusterSourceRegistered = "registered"
+	ClusterSourceFake       = "fake"
+)
+
+// InClusterName is the name of the cluster the backend runs in
+const InClusterName = "in-cluster"
+
+// healthCheckTimeout bounds a single cluster health check
+const healthCheckTimeout = 10 * time.Second
+
+// ErrClusterExists is returned when adding a cluster under a name that is already taken
+var ErrClusterExists = errors.New("cluster already exists")
+
+// invalidClusterNameChars are the characters that cannot appear in a cluster name, which is
+// used as a URL path segment
+var invalidClusterNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// ClusterName turns a kubeconfig context name into a cluster name, e.g. the EKS context
+// arn:aws:eks:eu-west-1:123456789012:cluster/prod becomes
+// arn-aws-eks-eu-west-1-123456789012-cluster-prod
+func ClusterName(contextName string) string {
+	return strings.Trim(invalidClusterNameChars.ReplaceAllString(contextName, "-"), "-")
+}
+
+// ValidClusterName reports whether name can be used as a cluster name
+func ValidClusterName(name string) bool {
+	return name != "" && len(name) <= 63 && ClusterName(name) == name
+}
+
+// ClusterHealth is the result of the latest health check of a cluster
+type ClusterHealth struct {
+	Healthy bool
+	// CheckedAt is zero until the first check has finished
+	CheckedAt time.Time
+	Error     string
+}
+
+// Cluster is a Kubernetes cluster the API can act on
+type Cluster struct {
+	Name   string
+	Source string
+	// Client is nil when the cluster could not be loaded, e.g. because its stored kubeconfig
+	// no longer decrypts. LoadError explains why.
+	Client    ClusterAPI
+	LoadError string
+
+	mu     sync.RWMutex
+	health ClusterHealth
+}
+
+// Health returns the result of the latest health check
+func (c *Cluster) Health() ClusterHealth {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.health
+}
+
+// CheckHealth tests the connection to the cluster and records the result
+func (c *Cluster) CheckHealth(ctx context.Context) ClusterHealth {
+	health := ClusterHealth{CheckedAt: time.Now()}
+	if c.Client == nil {
+		health.Error = c.LoadError
+	} else {
+		ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+		defer cancel()
+		if err := c.Client.TestConnection(ctx); err != nil {
+			health.Error = err.Error()
+		} else {
+			health.Healthy = true
+		}
+	}
+
+	c.mu.Lock()
+	previous := c.health
+	c.health = health
+	c.mu.Unlock()
+
+	switch {
+	case !health.Healthy && (previous.Healthy || previous.CheckedAt.IsZero()):
+		log.Printf("Cluster %s is unhealthy: %s", c.Name, health.Error)
+	case health.Healthy && !previous.Healthy && !previous.CheckedAt.IsZero():
+		log.Printf("Cluster %s is healthy again", c.Name)
+	}
+	return health
+}
+
+// Registry holds the clusters the API can act on. Requests name a cluster, or use the
+// default one.
+type Registry struct {
+	mu          sync.RWMutex
+	clusters    map[string]*Cluster
+	defaultName string
+	// cache serves reads of every cluster from an informer cache
+	cache bool
+}
+
+// NewRegistry creates an empty cluster registry. With cache set, reads of every cluster added
+// are served from an informer cache.
+func NewRegistry(cache bool) *Registry {
+	return &Registry{
+		clusters: make(map[string]*Cluster),
+		cache:    cache,
+	}
+}
+
+// Add adds a cluster to the registry and starts its cache. The first cluster added becomes
+// the default.
+func (r *Registry) Add(cluster *Cluster) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.clusters[cluster.Name]; ok {
+		return ErrClusterExists
+	}
+	if r.cache && cluster.Client != nil {
+		cluster.Client.StartCache(cluster.Name)
+	}
+	r.clusters[cluster.Name] = cluster
+	if r.defaultName == "" {
+		r.defaultName = cluster.Name
+	}
+	return nil
+}
+
+// Remove removes a cluster from the registry and stops its cache. When it was the default,
+// the first remaining cluster by name becomes the default.
+func (r *Registry) Remove(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if cluster, ok := r.clusters[name]; ok && cluster.Client != nil {
+		cluster.Client.StopCache()
+	}
+	delete(r.clusters, name)
+	if r.defaultName == name {
+		r.defaultName = ""
+		if names := r.names(); len(names) > 0 {
+			r.defaultName = names[0]
+		}
+	}
+}
+
+// SetDefault makes a cluster the default one. It reports false when there is no such cluster.
+func (r *Registry) SetDefault(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.clusters[name]; !ok {
+		return false
+	}
+	r.defaultName = name
+	return true
+}
+
+// DefaultName returns the name of the default cluster, or an empty string when the registry
+// is empty
+func (r *Registry) DefaultName() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.defaultName
+}
+
+// Get returns the cluster with the given name, or the default cluster when name is empty
+func (r *Registry) Get(name string) (*Cluster, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if name == "" {
+		name = r.defaultName
+	}
+	cluster, ok := r.clusters[name]
+	return cluster, ok
+}
+
+// List returns every cluster, sorted by name
+func (r *Registry) List() []*Cluster {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	clusters := make([]*Cluster, 0, len(r.clusters))
+	for _, name := range r.names() {
+		clusters = append(clusters, r.clusters[name])
+	}
+	return clusters
+}
+
+// names returns the sorted cluster names. The caller must hold the lock.
+func (r *Registry) names() []string {
+	names := make([]string, 0, len(r.clusters))
+	for name := range r.clusters {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// CacheSyncing reports whether the cache of any cluster is still syncing
+func (r *Registry) CacheSyncing() bool {
+	for _, cluster := range r.List() {
+		if cluster.Client != nil && cluster.Client.CacheState() == CacheSyncing {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckHealth checks every cluster concurrently and waits for the checks to finish
+func (r *Registry) CheckHealth(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, cluster := range r.List() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cluster.CheckHealth(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+// StartHealthChecks checks every cluster right away and then every interval until ctx is done
+func (r *Registry) StartHealthChecks(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			r.CheckHealth(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
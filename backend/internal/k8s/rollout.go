@@ -0,0 +1,159 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// Rollout states reported by GetRolloutStatus
+const (
+	RolloutProgressing = "progressing"
+	RolloutComplete    = "complete"
+	RolloutFailed      = "failed"
+)
+
+// rolloutResync is how often the deployment is re-read even when no watch event arrives
+const rolloutResync = 10 * time.Second
+
+// RolloutUpdate carries the latest state of a deployment while it rolls out
+type RolloutUpdate struct {
+	Deployment *appsv1.Deployment
+	Err        error
+}
+
+// GetRolloutStatus reports the rollout state of a deployment along with a human readable
+// message. It follows the same rules as kubectl rollout status.
+func GetRolloutStatus(deployment *appsv1.Deployment) (string, string) {
+	if deployment.Generation > deployment.Status.ObservedGeneration {
+		return RolloutProgressing, "Waiting for deployment spec update to be observed"
+	}
+
+	for _, condition := range deployment.Status.Conditions {
+		if condition.Type == appsv1.DeploymentProgressing && condition.Reason == "ProgressDeadlineExceeded" {
+			return RolloutFailed, fmt.Sprintf("Deployment %q exceeded its progress deadline", deployment.Name)
+		}
+	}
+
+	if deployment.Spec.Paused {
+		return RolloutProgressing, "Deployment is paused"
+	}
+
+	desired := int32(1)
+	if deployment.Spec.Replicas != nil {
+		desired = *deployment.Spec.Replicas
+	}
+	status := deployment.Status
+
+	switch {
+	case status.UpdatedReplicas < desired:
+		return RolloutProgressing, fmt.Sprintf("Waiting for rollout to finish: %d out of %d new replicas have been updated", status.UpdatedReplicas, desired)
+	case status.Replicas > status.UpdatedReplicas:
+		return RolloutProgressing, fmt.Sprintf("Waiting for rollout to finish: %d old replicas are pending termination", status.Replicas-status.UpdatedReplicas)
+	case status.AvailableReplicas < status.UpdatedReplicas:
+		return RolloutProgressing, fmt.Sprintf("Waiting for rollout to finish: %d of %d updated replicas are available", status.AvailableReplicas, status.UpdatedReplicas)
+	}
+
+	return RolloutComplete, fmt.Sprintf("Deployment %q successfully rolled out", deployment.Name)
+}
+
+// WatchRollout watches a deployment together with its ReplicaSets and pods, and sends the
+// latest deployment whenever any of them change. The channel is closed when the context is
+// cancelled or the deployment is deleted.
+func (c *Client) WatchRollout(ctx context.Context, namespace, name string) (<-chan RolloutUpdate, error) {
+	// The deployment is re-read on watch events, which the cache may not have seen yet
+	c = c.consistent()
+
+	deployment, err := c.GetDeployment(ctx, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(deployment.Spec.Selector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid deployment selector: %w", err)
+	}
+
+	triggers := make(chan struct{}, 1)
+	notify := func() {
+		select {
+		case triggers <- struct{}{}:
+		default:
+		}
+	}
+
+	go watchLoop(ctx, notify, func() (watch.Interface, error) {
+		return c.clientset.AppsV1().Deployments(namespace).Watch(ctx, metav1.ListOptions{
+			FieldSelector: fields.OneTermEqualSelector("metadata.name", name).String(),
+		})
+	})
+	go watchLoop(ctx, notify, func() (watch.Interface, error) {
+		return c.clientset.AppsV1().ReplicaSets(namespace).Watch(ctx, metav1.ListOptions{
+			LabelSelector: selector.String(),
+		})
+	})
+	go watchLoop(ctx, notify, func() (watch.Interface, error) {
+		return c.clientset.CoreV1().Pods(namespace).Watch(ctx, metav1.ListOptions{
+			LabelSelector: selector.String(),
+		})
+	})
+
+	updates := make(chan RolloutUpdate)
+	go func() {
+		defer close(updates)
+
+		ticker := time.NewTicker(rolloutResync)
+		defer ticker.Stop()
+
+		current, err := deployment, error(nil)
+		for {
+			select {
+			case updates <- RolloutUpdate{Deployment: current, Err: err}:
+			case <-ctx.Done():
+				return
+			}
+			if apierrors.IsNotFound(err) {
+				return
+			}
+
+			select {
+			case <-triggers:
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+
+			current, err = c.GetDeployment(ctx, namespace, name)
+			if ctx.Err() != nil {
+				return
+			}
+		}
+	}()
+
+	return updates, nil
+}
+
+// watchLoop keeps a watch open until the context is cancelled and calls notify on every event.
+// Watches are closed by the API server periodically, so they are simply re-established.
+func watchLoop(ctx context.Context, notify func(), start func() (watch.Interface, error)) {
+	for {
+		if w, err := start(); err == nil {
+			for range w.ResultChan() {
+				notify()
+			}
+			w.Stop()
+		}
+
+		select {
+		case <-time.After(time.Second):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	toolscache "k8s.io/client-go/tools/cache"
+)
+
+// Resources that can be watched with WatchResource
+const (
+	ResourcePods        = "pods"
+	ResourceDeployments = "deployments"
+	ResourceServices    = "services"
+)
+
+// WatchResource watches pods, deployments or services in a namespace, or in every namespace
+// when namespace is empty. Without a resource version the current objects are sent as ADDED
+// events first, followed by a bookmark carrying the resource version to resume from. They come
+// from the informer cache once it has synced, so that callers do not each list and watch the
+// kind on the API server. With a resource version the watch resumes after it, and fails with a
+// 410 Gone error when the API server no longer has that version. The API server sends
+// bookmarks carrying the latest resource version while no objects change.
+func (c *Client) WatchResource(ctx context.Context, resource, namespace, resourceVersion string) (watch.Interface, error) {
+	if cache := c.syncedCache(); cache != nil && resourceVersion == "" {
+		return cache.watch(resource, namespace)
+	}
+
+	var list func(metav1.ListOptions) (runtime.Object, error)
+	var start func(metav1.ListOptions) (watch.Interface, error)
+	switch resource {
+	case ResourcePods:
+		pods := c.clientset.CoreV1().Pods(namespace)
+		list = func(opts metav1.ListOptions) (runtime.Object, error) { return pods.List(ctx, opts) }
+		start = func(opts metav1.ListOptions) (watch.Interface, error) { return pods.Watch(ctx, opts) }
+	case ResourceDeployments:
+		deployments := c.clientset.AppsV1().Deployments(namespace)
+		list = func(opts metav1.ListOptions) (runtime.Object, error) { return deployments.List(ctx, opts) }
+		start = func(opts metav1.ListOptions) (watch.Interface, error) { return deployments.Watch(ctx, opts) }
+	case ResourceServices:
+		services := c.clientset.CoreV1().Services(namespace)
+		list = func(opts metav1.ListOptions) (runtime.Object, error) { return services.List(ctx, opts) }
+		start = func(opts metav1.ListOptions) (watch.Interface, error) { return services.Watch(ctx, opts) }
+	default:
+		return nil, fmt.Errorf("resource %q cannot be watched", resource)
+	}
+
+	if resourceVersion != "" {
+		return start(metav1.ListOptions{ResourceVersion: resourceVersion, AllowWatchBookmarks: true})
+	}
+
+	// Listing first rather than relying on the API server's initial events also works for the
+	// fake cluster, whose watches only report later changes
+	listed, err := list(metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	items, err := meta.ExtractList(listed)
+	if err != nil {
+		return nil, err
+	}
+	listMeta, err := meta.ListAccessor(listed)
+	if err != nil {
+		return nil, err
+	}
+
+	w, err := start(metav1.ListOptions{ResourceVersion: listMeta.GetResourceVersion(), AllowWatchBookmarks: true})
+	if err != nil {
+		return nil, err
+	}
+	return withInitialEvents(items, listMeta.GetResourceVersion(), w), nil
+}
+
+// watch watches a kind through the cache's informer. The cached objects are sent as ADDED
+// events first, followed by a bookmark.
+func (cc *clusterCache) watch(resource, namespace string) (watch.Interface, error) {
+	var informer toolscache.SharedIndexInformer
+	switch resource {
+	case ResourcePods:
+		informer = cc.factory.Core().V1().Pods().Informer()
+	case ResourceDeployments:
+		informer = cc.factory.Apps().V1().Deployments().Informer()
+	case ResourceServices:
+		informer = cc.factory.Core().V1().Services().Informer()
+	default:
+		return nil, fmt.Errorf("resource %q cannot be watched", resource)
+	}
+
+	// The cache holds at least this version when the handler is added, so resuming from it may
+	// repeat changes the ADDED events already show but never misses one
+	resourceVersion := informer.LastSyncResourceVersion()
+
+	events := make(chan watch.Event)
+	proxy := watch.NewProxyWatcher(events)
+	send := func(eventType watch.EventType, obj interface{}) {
+		object, ok := obj.(runtime.Object)
+		if !ok {
+			return
+		}
+		if accessor, err := meta.Accessor(object); err != nil || (namespace != "" && accessor.GetNamespace() != namespace) {
+			return
+		}
+		select {
+		case events <- watch.Event{Type: eventType, Object: object}:
+		case <-proxy.StopChan():
+		}
+	}
+
+	registration, err := informer.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			send(watch.Added, obj)
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			// Relisting after the informer's watch expired reports every object as updated
+			oldAccessor, oldErr := meta.Accessor(oldObj)
+			newAccessor, newErr := meta.Accessor(newObj)
+			if oldErr == nil && newErr == nil && oldAccessor.GetResourceVersion() == newAccessor.GetResourceVersion() {
+				return
+			}
+			send(watch.Modified, newObj)
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(toolscache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			send(watch.Deleted, obj)
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		defer informer.RemoveEventHandler(registration)
+
+		// The handler has been called for every cached object once the registration has synced
+		if toolscache.WaitForCacheSync(proxy.StopChan(), registration.HasSynced) && resourceVersion != "" {
+			select {
+			case events <- bookmarkEvent(resourceVersion):
+			case <-proxy.StopChan():
+			}
+		}
+		<-proxy.StopChan()
+	}()
+
+	return proxy, nil
+}
+
+// bookmarkEvent returns a bookmark carrying resourceVersion
+func bookmarkEvent(resourceVersion string) watch.Event {
+	return watch.Event{
+		Type:   watch.Bookmark,
+		Object: &metav1.PartialObjectMetadata{ObjectMeta: metav1.ObjectMeta{ResourceVersion: resourceVersion}},
+	}
+}
+
+// withInitialEvents returns a watch that sends every item as an ADDED event, followed by a
+// bookmark carrying the resource version of their list, before the events of w
+func withInitialEvents(items []runtime.Object, resourceVersion string, w watch.Interface) watch.Interface {
+	events := make(chan watch.Event)
+	proxy := watch.NewProxyWatcher(events)
+
+	go func() {
+		defer close(events)
+		defer w.Stop()
+
+		send := func(event watch.Event) bool {
+			select {
+			case events <- event:
+				return true
+			case <-proxy.StopChan():
+				return false
+			}
+		}
+
+		for _, item := range items {
+			if !send(watch.Event{Type: watch.Added, Object: item}) {
+				return
+			}
+		}
+		if resourceVersion != "" && !send(bookmarkEvent(resourceVersion)) {
+			return
+		}
+		for {
+			select {
+			case event, ok := <-w.ResultChan():
+				if !ok || !send(event) {
+					return
+				}
+			case <-proxy.StopChan():
+				return
+			}
+		}
+	}()
+
+	return proxy
+}
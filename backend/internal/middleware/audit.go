@@ -0,0 +1,201 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kube-deploy/backend/internal/database"
+	"github.com/kube-deploy/backend/internal/k8s"
+	"github.com/kube-deploy/backend/internal/models"
+)
+
+// maxAuditBodySize is the largest request body recorded in the audit log. Larger bodies are
+// forwarded untouched but not recorded, because a truncated body cannot be redacted reliably.
+const maxAuditBodySize = 1 << 20
+
+// redacted replaces secret values in recorded request bodies
+const redacted = "[REDACTED]"
+
+// sensitiveKey matches field names, and environment variable names, that hold secrets
+var sensitiveKey = regexp.MustCompile(`(?i)(password|passwd|secret|token|api[_-]?key|credential|private[_-]?key|kubeconfig)`)
+
+// auditedMethods are the HTTP methods that change state
+var auditedMethods = map[string]bool{
+	http.MethodPost:   true,
+	http.MethodPut:    true,
+	http.MethodPatch:  true,
+	http.MethodDelete: true,
+}
+
+// Audit records every mutating request in the audit log, together with the caller, the
+// redacted request body and the response status. It does nothing while the database is
+// unavailable.
+func Audit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !auditedMethods[c.Request.Method] || database.GetDB() == nil {
+			c.Next()
+			return
+		}
+
+		start := time.Now()
+		body := captureAuditBody(c)
+
+		c.Next()
+
+		entry := models.AuditEntry{
+			UserID:      c.GetUint("userID"),
+			Username:    c.GetString("username"),
+			Method:      c.Request.Method,
+			Route:       c.FullPath(),
+			Path:        c.Request.URL.Path,
+			Cluster:     GetCluster(c),
+			Namespace:   c.Param("namespace"),
+			Resource:    auditResource(c.FullPath()),
+			Name:        c.Param("name"),
+			RequestBody: body.text,
+			Status:      c.Writer.Status(),
+			DurationMs:  time.Since(start).Milliseconds(),
+			ClientIP:    c.ClientIP(),
+		}
+		if entry.Namespace == "" {
+			entry.Namespace = body.namespace
+		}
+		if entry.Namespace == "" {
+			entry.Namespace = c.Query("namespace")
+		}
+		if entry.Name == "" {
+			entry.Name = body.name
+		}
+		// Cluster routes name the cluster they manage in the path
+		if entry.Name == "" && entry.Resource == "clusters" {
+			entry.Name = c.Param("cluster")
+		}
+
+		if err := database.GetDB().Create(&entry).Error; err != nil {
+			log.Printf("Failed to record audit entry for %s %s: %v", entry.Method, entry.Path, err)
+		}
+	}
+}
+
+// auditBody is the recorded form of a request body
+type auditBody struct {
+	text      string
+	namespace string
+	name      string
+}
+
+// captureAuditBody reads the start of the request body for the audit log and puts it back
+// so that the handler still sees the complete body
+func captureAuditBody(c *gin.Context) auditBody {
+	if c.Request.Body == nil || c.Request.Body == http.NoBody {
+		return auditBody{}
+	}
+
+	head, err := io.ReadAll(io.LimitReader(c.Request.Body, maxAuditBodySize+1))
+	c.Request.Body = readCloser{
+		Reader: io.MultiReader(bytes.NewReader(head), c.Request.Body),
+		Closer: c.Request.Body,
+	}
+	switch {
+	case err != nil:
+		return auditBody{text: "[body could not be read]"}
+	case len(head) == 0:
+		return auditBody{}
+	case len(head) > maxAuditBodySize:
+		return auditBody{text: "[body too large to record]"}
+	case strings.HasPrefix(c.ContentType(), "multipart/"):
+		return auditBody{text: "[multipart body not recorded]"}
+	}
+
+	return redactBody(head)
+}
+
+// readCloser combines the replayed body with the original body's Close
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// redactBody redacts secrets in a JSON body or YAML manifests and returns them as JSON.
+// Bodies in any other format are not recorded since their secrets cannot be found.
+func redactBody(data []byte) auditBody {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		objects, err := k8s.DecodeManifests(data)
+		if err != nil {
+			return auditBody{text: "[unrecognised body not recorded]"}
+		}
+
+		documents := make([]interface{}, 0, len(objects))
+		for _, obj := range objects {
+			documents = append(documents, obj.Object)
+		}
+		value = documents
+	}
+
+	value = redactValue(value)
+
+	body := auditBody{}
+	if object, ok := value.(map[string]interface{}); ok {
+		body.namespace, _ = object["namespace"].(string)
+		body.name, _ = object["name"].(string)
+	}
+
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		body.text = "[body could not be encoded]"
+		return body
+	}
+	body.text = string(encoded)
+	return body
+}
+
+// redactValue replaces secrets anywhere in a decoded JSON value: fields with sensitive names,
+// the value of name/value pairs such as environment variables with sensitive names, and the
+// data of Kubernetes Secrets
+func redactValue(value interface{}) interface{} {
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		isSecret := typed["kind"] == "Secret"
+		name, _ := typed["name"].(string)
+
+		for key, field := range typed {
+			switch {
+			case sensitiveKey.MatchString(key):
+				typed[key] = redacted
+			case isSecret && (key == "data" || key == "stringData"):
+				typed[key] = redacted
+			case key == "value" && sensitiveKey.MatchString(name):
+				typed[key] = redacted
+			default:
+				typed[key] = redactValue(field)
+			}
+		}
+		return typed
+	case []interface{}:
+		for i, item := range typed {
+			typed[i] = redactValue(item)
+		}
+		return typed
+	default:
+		return value
+	}
+}
+
+// auditResource returns the resource a route acts on, e.g. deployments for
+// /api/deployments/:namespace/:name/scale and /api/clusters/:cluster/deployments/:namespace/:name
+func auditResource(route string) string {
+	route = strings.TrimPrefix(route, "/api/")
+	if rest, found := strings.CutPrefix(route, "clusters/:cluster/"); found {
+		route = rest
+	}
+	resource, _, _ := strings.Cut(route, "/")
+	return resource
+}
@@ -1,18 +1,22 @@
 package middleware
 
 import (
+	"errors"
+	"log"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/kube-deploy/backend/internal/database"
 	"github.com/kube-deploy/backend/internal/models"
 	"github.com/kube-deploy/backend/internal/utils"
 )
 
-// AuthMiddleware validates JWT token from Authorization header
+// AuthMiddleware validates the JWT or personal access token from the Authorization header
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
+		authHeader := authorizationHeader(c)
 		if authHeader == "" {
 			c.JSON(http.StatusUnauthorized, models.APIResponse{
 				Success: false,
@@ -35,9 +39,8 @@ func AuthMiddleware() gin.HandlerFunc {
 
 		token := parts[1]
 
-		// Validate token
-		claims, err := utils.ValidateToken(token)
-		if err != nil {
+		// Validate token and set user information in context
+		if err := authenticate(c, token); err != nil {
 			c.JSON(http.StatusUnauthorized, models.APIResponse{
 				Success: false,
 				Error:   "Invalid or expired token",
@@ -46,20 +49,14 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		// Set user information in context
-		c.Set("userID", claims.UserID)
-		c.Set("email", claims.Email)
-		c.Set("username", claims.Username)
-		c.Set("role", claims.Role)
-
 		c.Next()
 	}
 }
 
-// OptionalAuthMiddleware validates JWT token if present but doesn't require it
+// OptionalAuthMiddleware validates the JWT or personal access token if present but doesn't require it
 func OptionalAuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
+		authHeader := authorizationHeader(c)
 		if authHeader == "" {
 			c.Next()
 			return
@@ -72,17 +69,99 @@ func OptionalAuthMiddleware() gin.HandlerFunc {
 		}
 
 		token := parts[1]
-		claims, err := utils.ValidateToken(token)
-		if err != nil {
+		if err := authenticate(c, token); err != nil {
 			c.Next()
 			return
 		}
 
-		c.Set("userID", claims.UserID)
-		c.Set("email", claims.Email)
-		c.Set("username", claims.Username)
-		c.Set("role", claims.Role)
-
 		c.Next()
 	}
 }
+
+// authorizationHeader returns the Authorization header. Browsers cannot set headers on
+// WebSocket connections, so for upgrade requests the token query parameter is accepted too.
+func authorizationHeader(c *gin.Context) string {
+	if authHeader := c.GetHeader("Authorization"); authHeader != "" {
+		return authHeader
+	}
+
+	if strings.EqualFold(c.GetHeader("Upgrade"), "websocket") {
+		if token := c.Query("token"); token != "" {
+			return "Bearer " + token
+		}
+	}
+
+	return ""
+}
+
+// authenticate validates a JWT or personal access token and stores the caller in the context
+func authenticate(c *gin.Context, token string) error {
+	if utils.IsAPIToken(token) {
+		return authenticateAPIToken(c, token)
+	}
+
+	claims, err := utils.ValidateToken(token)
+	if err != nil {
+		return err
+	}
+
+	// Access tokens stay valid only as long as their session, which ends on logout, on a
+	// password change and when the user is deactivated
+	if database.GetDB() == nil || claims.SessionID == "" {
+		return errors.New("token has no active session")
+	}
+	active, err := database.SessionActive(claims.SessionID)
+	if err != nil {
+		return err
+	}
+	if !active {
+		return errors.New("session revoked")
+	}
+
+	c.Set("userID", claims.UserID)
+	c.Set("email", claims.Email)
+	c.Set("username", claims.Username)
+	c.Set("role", claims.Role)
+	c.Set("teams", claims.Teams)
+	c.Set("sessionID", claims.SessionID)
+	return nil
+}
+
+// authenticateAPIToken looks up a personal access token, records that it was used and stores
+// its user together with the token's scopes and namespace allowlist in the context
+func authenticateAPIToken(c *gin.Context, token string) error {
+	db := database.GetDB()
+	if db == nil {
+		return errors.New("personal access tokens require the database")
+	}
+
+	var apiToken models.APIToken
+	if err := db.Preload("User").Where("token_hash = ?", utils.HashToken(token)).First(&apiToken).Error; err != nil {
+		return errors.New("unknown token")
+	}
+	if time.Now().After(apiToken.ExpiresAt) {
+		return errors.New("token expired")
+	}
+	if !apiToken.User.Active {
+		return errors.New("account is disabled")
+	}
+
+	teams, err := database.UserTeamNames(apiToken.UserID)
+	if err != nil {
+		return err
+	}
+
+	if err := db.Model(&apiToken).UpdateColumn("last_used_at", time.Now()).Error; err != nil {
+		log.Printf("Failed to record use of API token %d: %v", apiToken.ID, err)
+	}
+
+	c.Set("userID", apiToken.User.ID)
+	c.Set("email", apiToken.User.Email)
+	c.Set("username", apiToken.User.Username)
+	c.Set("role", apiToken.User.Role)
+	c.Set("teams", teams)
+	c.Set("apiTokenID", apiToken.ID)
+	c.Set("scopes", apiToken.Scopes)
+	c.Set("tokenNamespaces", apiToken.Namespaces)
+	return nil
+}
@@ -0,0 +1,125 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kube-deploy/backend/internal/k8s"
+	"github.com/kube-deploy/backend/internal/models"
+)
+
+// Prefixes of the Kubernetes identities portal users are impersonated as, e.g. the user
+// kube-deploy:user:42 in the groups kube-deploy:role:deployer and kube-deploy:team:payments.
+// Users are named by their ID, as usernames can be changed and reused.
+const (
+	ImpersonationUserPrefix = "kube-deploy:user:"
+	ImpersonationRolePrefix = "kube-deploy:role:"
+	ImpersonationTeamPrefix = "kube-deploy:team:"
+)
+
+// Kubernetes identity of callers that did not authenticate
+const (
+	anonymousUser  = "system:anonymous"
+	anonymousGroup = "system:unauthenticated"
+)
+
+// Context keys holding the request's cluster name and Kubernetes client
+const (
+	clusterKey    = "cluster"
+	kubeClientKey = "k8sClient"
+)
+
+// KubernetesClient stores the cluster and Kubernetes client used for the request. The cluster
+// is named by the cluster path or query parameter, and is the registry's default otherwise.
+// With impersonate set the client impersonates the authenticated portal user, so that cluster
+// RBAC applies to them and the cluster audit log records who made the change. Anonymous
+// callers are impersonated as system:anonymous. With the consistent query parameter set to
+// true, reads go to the API server instead of the cluster's cache.
+func KubernetesClient(clusters *k8s.Registry, impersonate bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.Param("cluster")
+		if name == "" {
+			name = c.Query("cluster")
+		}
+
+		cluster, ok := clusters.Get(name)
+		if !ok {
+			message := fmt.Sprintf("Cluster %q not found", name)
+			if name == "" {
+				message = "No Kubernetes cluster is configured"
+			}
+			c.JSON(http.StatusNotFound, models.APIResponse{
+				Success: false,
+				Error:   message,
+			})
+			c.Abort()
+			return
+		}
+		if cluster.Client == nil {
+			c.JSON(http.StatusServiceUnavailable, models.APIResponse{
+				Success: false,
+				Error:   fmt.Sprintf("Cluster %s is unavailable: %s", cluster.Name, cluster.LoadError),
+			})
+			c.Abort()
+			return
+		}
+		c.Set(clusterKey, cluster.Name)
+
+		client := cluster.Client
+		if impersonate {
+			username, groups := impersonationIdentity(c)
+			impersonated, err := client.Impersonate(username, groups)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, models.APIResponse{
+					Success: false,
+					Error:   "Failed to create Kubernetes client",
+				})
+				c.Abort()
+				return
+			}
+			client = impersonated
+		}
+		if consistent, _ := strconv.ParseBool(c.Query("consistent")); consistent {
+			client = client.Consistent()
+		}
+
+		c.Set(kubeClientKey, client)
+		c.Next()
+	}
+}
+
+// GetKubernetesClient returns the client stored by KubernetesClient
+func GetKubernetesClient(c *gin.Context) k8s.ClusterAPI {
+	client, _ := c.Get(kubeClientKey)
+	return client.(k8s.ClusterAPI)
+}
+
+// GetCluster returns the name of the cluster stored by KubernetesClient, or an empty string
+// when the request does not act on a cluster
+func GetCluster(c *gin.Context) string {
+	return c.GetString(clusterKey)
+}
+
+// impersonationIdentity derives the Kubernetes user and groups from the caller's token claims
+func impersonationIdentity(c *gin.Context) (string, []string) {
+	userID, ok := c.Get("userID")
+	if !ok {
+		return anonymousUser, []string{anonymousGroup}
+	}
+
+	role := c.GetString("role")
+	if alias, ok := roleAliases[role]; ok {
+		role = alias
+	}
+
+	teams := c.GetStringSlice("teams")
+	groups := make([]string, 0, len(teams)+1)
+	groups = append(groups, ImpersonationRolePrefix+role)
+	for _, team := range teams {
+		groups = append(groups, ImpersonationTeamPrefix+team)
+	}
+
+	return fmt.Sprintf("%s%d", ImpersonationUserPrefix, userID), groups
+}
@@ -0,0 +1,113 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kube-deploy/backend/internal/database"
+	"github.com/kube-deploy/backend/internal/models"
+)
+
+// namespaceAccessKey is the context key holding the caller's NamespaceAccess
+const namespaceAccessKey = "namespaceAccess"
+
+// NamespaceAccess is the set of namespaces a caller may use. A nil NamespaceAccess allows
+// every namespace.
+type NamespaceAccess map[string]bool
+
+// Allows reports whether the caller may use namespace
+func (a NamespaceAccess) Allows(namespace string) bool {
+	return a == nil || a[namespace]
+}
+
+// Unrestricted reports whether the caller may use every namespace
+func (a NamespaceAccess) Unrestricted() bool {
+	return a == nil
+}
+
+// restrict limits the access to the given namespaces
+func (a NamespaceAccess) restrict(namespaces []string) NamespaceAccess {
+	restricted := make(NamespaceAccess, len(namespaces))
+	for _, namespace := range namespaces {
+		if a.Allows(namespace) {
+			restricted[namespace] = true
+		}
+	}
+	return restricted
+}
+
+// GetNamespaceAccess returns the namespaces resolved by NamespaceScope for the request.
+// Requests that did not pass through NamespaceScope may use no namespace at all.
+func GetNamespaceAccess(c *gin.Context) NamespaceAccess {
+	value, ok := c.Get(namespaceAccessKey)
+	if !ok {
+		return NamespaceAccess{}
+	}
+	access, _ := value.(NamespaceAccess)
+	return access
+}
+
+// NamespaceScope resolves the namespaces of the request's cluster bound to the caller's teams
+// and rejects requests whose :namespace path parameter is not one of them. It must run after
+// KubernetesClient, which resolves the cluster. Admins, and every caller while the database is
+// unavailable, may use all namespaces. Anonymous callers belong to no team. API tokens with a
+// namespace allowlist are further limited to those namespaces.
+func NamespaceScope() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		access, err := loadNamespaceAccess(c)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.APIResponse{
+				Success: false,
+				Error:   "Failed to load namespace permissions",
+			})
+			c.Abort()
+			return
+		}
+		if allowlist := c.GetStringSlice("tokenNamespaces"); len(allowlist) > 0 {
+			access = access.restrict(allowlist)
+		}
+		c.Set(namespaceAccessKey, access)
+
+		if namespace := c.Param("namespace"); namespace != "" && !access.Allows(namespace) {
+			c.JSON(http.StatusForbidden, models.APIResponse{
+				Success: false,
+				Error:   "You do not have access to namespace " + namespace,
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// loadNamespaceAccess looks up the namespaces of the request's cluster bound to the teams of
+// the authenticated user
+func loadNamespaceAccess(c *gin.Context) (NamespaceAccess, error) {
+	db := database.GetDB()
+	if db == nil || c.GetString("role") == models.RoleAdmin {
+		return nil, nil
+	}
+
+	userID, ok := c.Get("userID")
+	if !ok {
+		return NamespaceAccess{}, nil
+	}
+
+	var namespaces []string
+	err := db.Model(&models.TeamNamespace{}).
+		Joins("JOIN teams ON teams.id = team_namespaces.team_id AND teams.deleted_at IS NULL").
+		Joins("JOIN team_memberships ON team_memberships.team_id = team_namespaces.team_id").
+		Where("team_memberships.user_id = ? AND team_namespaces.cluster = ?", userID, GetCluster(c)).
+		Distinct().
+		Pluck("team_namespaces.namespace", &namespaces).Error
+	if err != nil {
+		return nil, err
+	}
+
+	access := make(NamespaceAccess, len(namespaces))
+	for _, namespace := range namespaces {
+		access[namespace] = true
+	}
+	return access, nil
+}
@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/kube-deploy/backend/internal/database"
+	"github.com/kube-deploy/backend/internal/k8s"
+	"github.com/kube-deploy/backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestNamespaceScopeIsPerCluster(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.Team{}, &models.TeamMembership{}, &models.TeamNamespace{}); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+	previous := database.DB
+	database.DB = db
+	t.Cleanup(func() { database.DB = previous })
+
+	user := models.User{Email: "alice@example.com", Username: "alice", Role: models.RoleDeployer, Active: true}
+	team := models.Team{
+		Name:    "payments",
+		Members: []models.TeamMembership{{User: user}},
+		Namespaces: []models.TeamNamespace{
+			{Namespace: "legacy"},
+			{Namespace: "payments"},
+			{Cluster: "production", Namespace: "payments"},
+			{Cluster: "staging", Namespace: "payments-staging"},
+		},
+	}
+	if err := db.Create(&team).Error; err != nil {
+		t.Fatalf("failed to create team: %v", err)
+	}
+
+	clusters := k8s.NewRegistry(false)
+	for _, name := range []string{"production", "staging"} {
+		if err := clusters.Add(&k8s.Cluster{Name: name, Client: k8s.NewFakeClient()}); err != nil {
+			t.Fatalf("failed to add cluster: %v", err)
+		}
+	}
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("userID", team.Members[0].UserID)
+		c.Set("role", models.RoleDeployer)
+	})
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	router.GET("/pods/:namespace", KubernetesClient(clusters, false), NamespaceScope(), ok)
+	router.GET("/clusters/:cluster/pods/:namespace", KubernetesClient(clusters, false), NamespaceScope(), ok)
+
+	check := func(path string, want int) {
+		t.Helper()
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		if recorder.Code != want {
+			t.Errorf("%s: expected status %d, got %d", path, want, recorder.Code)
+		}
+	}
+
+	check("/pods/payments", http.StatusOK)
+	check("/pods/payments-staging", http.StatusForbidden)
+	check("/clusters/production/pods/payments", http.StatusOK)
+	check("/clusters/staging/pods/payments-staging", http.StatusOK)
+	check("/clusters/staging/pods/payments", http.StatusForbidden)
+	check("/pods/payments?cluster=staging", http.StatusForbidden)
+
+	// Bindings without a cluster grant nothing until they are bound to one
+	check("/pods/legacy", http.StatusForbidden)
+	assigned, err := database.AssignTeamNamespaceClusters("production")
+	if err != nil {
+		t.Fatalf("failed to assign clusters: %v", err)
+	}
+	if assigned != 1 {
+		t.Errorf("expected 1 binding to be assigned besides the duplicate, got %d", assigned)
+	}
+	check("/pods/legacy", http.StatusOK)
+	check("/clusters/staging/pods/legacy", http.StatusForbidden)
+
+	// Moving the default cluster does not move the bindings
+	clusters.SetDefault("staging")
+	check("/pods/legacy", http.StatusForbidden)
+	check("/clusters/production/pods/legacy", http.StatusOK)
+}
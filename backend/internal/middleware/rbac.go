@@ -0,0 +1,210 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kube-deploy/backend/internal/models"
+)
+
+// Verbs used in the permission matrix
+const (
+	VerbRead   = "read"
+	VerbWrite  = "write"
+	VerbDelete = "delete"
+	VerbExec   = "exec"
+	VerbProxy  = "proxy"
+)
+
+// wildcard matches any verb or resource in the permission matrix
+const wildcard = "*"
+
+// permissions maps each role to the verbs it may use on each resource
+var permissions = map[string]map[string][]string{
+	models.RoleViewer: {
+		wildcard: {VerbRead},
+	},
+	models.RoleDeployer: {
+		wildcard:      {VerbRead},
+		"pods":        {VerbWrite, VerbDelete, VerbProxy},
+		"deployments": {VerbWrite, VerbDelete},
+		"services":    {VerbWrite, VerbDelete, VerbProxy},
+		"manifests":   {VerbWrite},
+	},
+	models.RoleAdmin: {
+		wildcard: {wildcard},
+	},
+}
+
+// GrantExec lets roles open a terminal in pods on top of the permission matrix, which only
+// allows admins. It must be called before the server starts.
+func GrantExec(roles []string) {
+	for _, role := range roles {
+		if rules, ok := permissions[role]; ok && !HasPermission(role, VerbExec, "pods") {
+			rules["pods"] = append(slices.Clone(rules["pods"]), VerbExec)
+		}
+	}
+}
+
+// verbs lists every verb in the permission matrix
+var verbs = []string{VerbRead, VerbWrite, VerbDelete, VerbExec, VerbProxy}
+
+// roleAliases maps roles assigned before the permission matrix existed onto current roles
+var roleAliases = map[string]string{
+	"user": models.RoleDeployer,
+}
+
+// HasPermission reports whether a role may use a verb on a resource
+func HasPermission(role, verb, resource string) bool {
+	if alias, ok := roleAliases[role]; ok {
+		role = alias
+	}
+
+	rules, ok := permissions[role]
+	if !ok {
+		return false
+	}
+
+	for _, key := range []string{resource, wildcard} {
+		for _, allowed := range rules[key] {
+			if allowed == verb || allowed == wildcard {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Allowed reports whether the caller's role, and API token if any, may use verb on resource.
+// It is for handlers that act on several resources and cannot use RequirePermission.
+func Allowed(c *gin.Context, verb, resource string) bool {
+	if !HasPermission(c.GetString("role"), verb, resource) {
+		return false
+	}
+	if scopes, ok := tokenScopes(c); ok && !scopeAllows(scopes, verb, resource) {
+		return false
+	}
+	return true
+}
+
+// RequirePermission rejects requests whose role may not use verb on resource
+func RequirePermission(verb, resource string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := c.GetString("role")
+		if role == "" {
+			c.JSON(http.StatusUnauthorized, models.APIResponse{
+				Success: false,
+				Error:   "Authentication required",
+			})
+			c.Abort()
+			return
+		}
+
+		if !HasPermission(role, verb, resource) {
+			c.JSON(http.StatusForbidden, models.APIResponse{
+				Success: false,
+				Error:   "Your role is not allowed to " + verb + " " + resource,
+			})
+			c.Abort()
+			return
+		}
+
+		if scopes, ok := tokenScopes(c); ok && !scopeAllows(scopes, verb, resource) {
+			c.JSON(http.StatusForbidden, models.APIResponse{
+				Success: false,
+				Error:   "This API token is not allowed to " + verb + " " + resource,
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireRole rejects requests whose role is not one of roles
+func RequireRole(roles ...string) gin.HandlerFunc {
+	allowed := make(map[string]bool, len(roles))
+	for _, role := range roles {
+		allowed[role] = true
+	}
+
+	return func(c *gin.Context) {
+		role := c.GetString("role")
+		if role == "" {
+			c.JSON(http.StatusUnauthorized, models.APIResponse{
+				Success: false,
+				Error:   "Authentication required",
+			})
+			c.Abort()
+			return
+		}
+
+		if alias, ok := roleAliases[role]; ok {
+			role = alias
+		}
+		if !allowed[role] {
+			c.JSON(http.StatusForbidden, models.APIResponse{
+				Success: false,
+				Error:   "Your role is not allowed to access this resource",
+			})
+			c.Abort()
+			return
+		}
+
+		// Routes limited to a role are not covered by any narrower scope
+		if scopes, ok := tokenScopes(c); ok && !scopeAllows(scopes, wildcard, wildcard) {
+			c.JSON(http.StatusForbidden, models.APIResponse{
+				Success: false,
+				Error:   "This API token is not allowed to access this resource",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// ValidScope reports whether scope is a resource:verb pair for an API token, e.g.
+// deployments:write. Either part may be the * wildcard.
+func ValidScope(scope string) bool {
+	resource, verb, found := strings.Cut(scope, ":")
+	if !found || resource == "" {
+		return false
+	}
+	return verb == wildcard || slices.Contains(verbs, verb)
+}
+
+// tokenScopes returns the scopes of the API token a request authenticated with. It reports
+// false for requests that did not use an API token.
+func tokenScopes(c *gin.Context) ([]string, bool) {
+	if _, ok := c.Get("apiTokenID"); !ok {
+		return nil, false
+	}
+	return c.GetStringSlice("scopes"), true
+}
+
+// scopeAllows reports whether one of an API token's scopes grants verb on resource
+func scopeAllows(scopes []string, verb, resource string) bool {
+	for _, scope := range scopes {
+		scopeResource, scopeVerb, _ := strings.Cut(scope, ":")
+		if (scopeResource == resource || scopeResource == wildcard) && (scopeVerb == verb || scopeVerb == wildcard) {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultRole assigns role to requests that were not authenticated, such as anonymous callers
+// when authentication is optional or every caller when authentication is disabled
+func DefaultRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("role") == "" {
+			c.Set("role", role)
+		}
+		c.Next()
+	}
+}
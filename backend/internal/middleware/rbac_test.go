@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/kube-deploy/backend/internal/models"
+)
+
+func TestGrantExec(t *testing.T) {
+	previous := slices.Clone(permissions[models.RoleDeployer]["pods"])
+	t.Cleanup(func() { permissions[models.RoleDeployer]["pods"] = previous })
+
+	if HasPermission(models.RoleDeployer, VerbExec, "pods") {
+		t.Fatal("deployers must not exec into pods by default")
+	}
+	GrantExec([]string{models.RoleDeployer})
+	if !HasPermission(models.RoleDeployer, VerbExec, "pods") {
+		t.Error("expected deployers to exec into pods after GrantExec")
+	}
+	if HasPermission(models.RoleViewer, VerbExec, "pods") {
+		t.Error("viewers must not exec into pods")
+	}
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// AuditEntry records a mutating API call
+type AuditEntry struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	CreatedAt   time.Time `gorm:"index" json:"created_at"`
+	UserID      uint      `gorm:"index" json:"user_id,omitempty"` // 0 for anonymous callers
+	Username    string    `gorm:"index" json:"username,omitempty"`
+	Method      string    `json:"method"`
+	Route       string    `json:"route"` // Route pattern, e.g. /api/deployments/:namespace/:name
+	Path        string    `json:"path"`
+	Cluster     string    `gorm:"index" json:"cluster,omitempty"`
+	Namespace   string    `gorm:"index" json:"namespace,omitempty"`
+	Resource    string    `gorm:"index" json:"resource"`
+	Name        string    `json:"name,omitempty"`
+	RequestBody string    `gorm:"type:text" json:"request_body,omitempty"` // Secrets are redacted
+	Status      int       `json:"status"`
+	DurationMs  int64     `json:"duration_ms"`
+	ClientIP    string    `json:"client_ip"`
+}
@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// Cluster is a cluster registered through the API. Clusters from the kubeconfig file are not
+// stored.
+type Cluster struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Name      string    `gorm:"uniqueIndex;not null" json:"name"`
+	Context   string    `json:"context"` // Context of the submitted kubeconfig the cluster uses
+	// Kubeconfig is reduced to the cluster's context and encrypted with CLUSTER_ENCRYPTION_KEY
+	Kubeconfig  string `gorm:"type:text;not null" json:"-"`
+	CreatedByID uint   `json:"created_by_id,omitempty"`
+}
+
+// ClusterCreateRequest represents a request to register a cluster
+type ClusterCreateRequest struct {
+	Name       string `json:"name" binding:"required,max=63"` // Letters, digits, '.', '_' and '-'
+	Kubeconfig string `json:"kubeconfig" binding:"required"`
+	Context    string `json:"context"` // Defaults to the kubeconfig's current context
+}
+
+// ClusterResponse represents a cluster and its health
+type ClusterResponse struct {
+	Name      string     `json:"name"`
+	Source    string     `json:"source"` // in-cluster, kubeconfig, registered or fake
+	Default   bool       `json:"default"`
+	Healthy   bool       `json:"healthy"`
+	CheckedAt *time.Time `json:"checked_at,omitempty"` // Unset until the first health check
+	Error     string     `json:"error,omitempty"`
+	Cache     string     `json:"cache"` // disabled, syncing, synced or failed
+}
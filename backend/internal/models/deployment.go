@@ -23,6 +23,12 @@ type ServiceCreateRequest struct {
 	Ports      []ServicePort     `json:"ports" binding:"required"`
 }
 
+// DeploymentImageUpdateRequest represents a request to change a deployment's image
+type DeploymentImageUpdateRequest struct {
+	Image     string `json:"image" binding:"required"`
+	Container string `json:"container"` // Required when the deployment has more than one container
+}
+
 // ContainerPort represents a container port
 type ContainerPort struct {
 	Name          string `json:"name"`
@@ -54,11 +60,55 @@ type DeploymentResponse struct {
 	Replicas          int32             `json:"replicas"`
 	AvailableReplicas int32             `json:"availableReplicas"`
 	ReadyReplicas     int32             `json:"readyReplicas"`
+	Status            string            `json:"status"` // Rollout status: progressing, complete or failed
 	CreatedAt         string            `json:"created_at"`
 	Image             string            `json:"image"`
 	Labels            map[string]string `json:"labels,omitempty"`
 }
 
+// DeploymentRevision represents an entry in a deployment's rollout history
+type DeploymentRevision struct {
+	Revision    int64    `json:"revision"`
+	ReplicaSet  string   `json:"replicaSet"`
+	Images      []string `json:"images"`
+	Replicas    int32    `json:"replicas"`
+	ChangeCause string   `json:"changeCause,omitempty"`
+	Current     bool     `json:"current"`
+	CreatedAt   string   `json:"created_at"`
+}
+
+// DeploymentCondition represents a condition reported in a deployment's status
+type DeploymentCondition struct {
+	Type           string `json:"type"`
+	Status         string `json:"status"`
+	Reason         string `json:"reason,omitempty"`
+	Message        string `json:"message,omitempty"`
+	LastUpdateTime string `json:"lastUpdateTime,omitempty"`
+}
+
+// RolloutStatusEvent represents a progress update streamed while a deployment rolls out
+type RolloutStatusEvent struct {
+	Status              string                `json:"status"` // progressing, complete, failed
+	Message             string                `json:"message"`
+	Generation          int64                 `json:"generation"`
+	ObservedGeneration  int64                 `json:"observedGeneration"`
+	Replicas            int32                 `json:"replicas"`
+	UpdatedReplicas     int32                 `json:"updatedReplicas"`
+	ReadyReplicas       int32                 `json:"readyReplicas"`
+	AvailableReplicas   int32                 `json:"availableReplicas"`
+	UnavailableReplicas int32                 `json:"unavailableReplicas"`
+	Paused              bool                  `json:"paused"`
+	Conditions          []DeploymentCondition `json:"conditions"`
+	Timestamp           string                `json:"timestamp"`
+}
+
+// DeploymentLogsResponse represents the combined logs of all pods in a deployment
+type DeploymentLogsResponse struct {
+	Logs   string   `json:"logs"`
+	Pods   []string `json:"pods"`
+	Errors []string `json:"errors,omitempty"`
+}
+
 // ServiceResponse represents a service in the response
 type ServiceResponse struct {
 	Name         string            `json:"name"`
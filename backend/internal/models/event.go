@@ -0,0 +1,16 @@
+package models
+
+// EventResponse represents a Kubernetes event in the response. Repeated events for the same
+// object, reason and message are merged into one entry.
+type EventResponse struct {
+	Type      string `json:"type"` // Normal, Warning
+	Reason    string `json:"reason"`
+	Message   string `json:"message"`
+	Count     int32  `json:"count"`
+	FirstSeen string `json:"firstSeen"`
+	LastSeen  string `json:"lastSeen"`
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Source    string `json:"source,omitempty"`
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// LoginThrottle tracks failed password logins for an account or a client IP. Keys are
+// user:<id> for accounts and ip:<address> for clients.
+type LoginThrottle struct {
+	Key           string    `gorm:"primarykey" json:"key"`
+	Failures      int       `json:"failures"`
+	LastFailureAt time.Time `json:"last_failure_at"`
+	// BlockedUntil is the earliest time of the next login attempt, after a backoff delay or a
+	// lockout
+	BlockedUntil time.Time `json:"blocked_until"`
+}
@@ -0,0 +1,20 @@
+package models
+
+// ManifestObjectResult represents the outcome of applying a single manifest object
+type ManifestObjectResult struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
+	Action     string `json:"action"` // created, updated, unchanged, failed
+	Error      string `json:"error,omitempty"`
+}
+
+// ManifestApplyResponse represents the result of applying a set of manifests
+type ManifestApplyResponse struct {
+	Results   []ManifestObjectResult `json:"results"`
+	Created   int                    `json:"created"`
+	Updated   int                    `json:"updated"`
+	Unchanged int                    `json:"unchanged"`
+	Failed    int                    `json:"failed"`
+}
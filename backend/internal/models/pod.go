@@ -35,10 +35,71 @@ type PodResponse struct {
 	Labels    map[string]string `json:"labels,omitempty"`
 }
 
+// PodDetailResponse represents a single pod with per-container status in the response
+type PodDetailResponse struct {
+	PodResponse
+	NodeName        string            `json:"nodeName,omitempty"`
+	PodIP           string            `json:"podIP,omitempty"`
+	HostIP          string            `json:"hostIP,omitempty"`
+	QOSClass        string            `json:"qosClass,omitempty"`
+	StartTime       string            `json:"startTime,omitempty"`
+	Reason          string            `json:"reason,omitempty"`
+	Message         string            `json:"message,omitempty"`
+	InitContainers  []ContainerStatus `json:"initContainers"`
+	Containers      []ContainerStatus `json:"containers"`
+	Conditions      []PodCondition    `json:"conditions"`
+	OwnerReferences []OwnerReference  `json:"ownerReferences"`
+}
+
+// ContainerStatus represents the state of a single container in a pod
+type ContainerStatus struct {
+	Name         string `json:"name"`
+	Image        string `json:"image"`
+	ImageID      string `json:"imageID,omitempty"`
+	State        string `json:"state"` // waiting, running, terminated
+	Reason       string `json:"reason,omitempty"`
+	Message      string `json:"message,omitempty"`
+	ExitCode     *int32 `json:"exitCode,omitempty"`
+	RestartCount int32  `json:"restartCount"`
+	Ready        bool   `json:"ready"`
+	StartedAt    string `json:"startedAt,omitempty"`
+	FinishedAt   string `json:"finishedAt,omitempty"`
+}
+
+// PodCondition represents a condition reported in a pod's status
+type PodCondition struct {
+	Type               string `json:"type"`
+	Status             string `json:"status"`
+	Reason             string `json:"reason,omitempty"`
+	Message            string `json:"message,omitempty"`
+	LastTransitionTime string `json:"lastTransitionTime,omitempty"`
+}
+
+// OwnerReference represents an object that owns a pod, such as a ReplicaSet
+type OwnerReference struct {
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	Controller bool   `json:"controller"`
+}
+
 // APIResponse represents a generic API response
 type APIResponse struct {
 	Success bool        `json:"success"`
 	Message string      `json:"message,omitempty"`
 	Data    interface{} `json:"data,omitempty"`
 	Error   string      `json:"error,omitempty"`
+	// Pagination describes the page of objects in Data for list endpoints
+	Pagination *Pagination `json:"pagination,omitempty"`
+}
+
+// Pagination describes a page of a list response
+type Pagination struct {
+	Limit int64 `json:"limit,omitempty"` // Unset when every object was returned
+	// Continue is passed as the continue query parameter to get the next page. It is empty on
+	// the last page.
+	Continue string `json:"continue,omitempty"`
+	// RemainingItemCount estimates how many objects follow this page before filtering by
+	// status, search term and namespace access. The API server omits it for some queries.
+	RemainingItemCount *int64 `json:"remainingItemCount,omitempty"`
+	Count              int    `json:"count"` // Objects in this page
 }
@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// Session is a login session. Access tokens carry the session ID, so revoking the session
+// revokes every access and refresh token issued for it.
+type Session struct {
+	ID        string     `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	UserID    uint       `gorm:"index;not null" json:"user_id"`
+	User      User       `json:"-"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `gorm:"index" json:"revoked_at,omitempty"`
+	ClientIP  string     `json:"client_ip"`
+	UserAgent string     `json:"user_agent"`
+}
+
+// RefreshToken is a single-use token that renews a session's access token. Every refresh
+// replaces it with a new one, and presenting a used token again revokes the whole session.
+type RefreshToken struct {
+	ID        uint       `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	SessionID string     `gorm:"index;not null" json:"session_id"`
+	Session   Session    `json:"-"`
+	TokenHash string     `gorm:"uniqueIndex;not null" json:"-"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+}
+
+// RefreshRequest represents the refresh request payload
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// ChangePasswordRequest represents the change password request payload
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required,min=6"`
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Team groups users that share access to a set of namespaces
+type Team struct {
+	ID          uint             `gorm:"primarykey" json:"id"`
+	CreatedAt   time.Time        `json:"created_at"`
+	UpdatedAt   time.Time        `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt   `gorm:"index" json:"-"`
+	Name        string           `gorm:"uniqueIndex;not null" json:"name"`
+	Description string           `json:"description"`
+	Members     []TeamMembership `json:"members,omitempty"`
+	Namespaces  []TeamNamespace  `json:"namespaces,omitempty"`
+}
+
+// TeamMembership makes a user a member of a team
+type TeamMembership struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	TeamID    uint      `gorm:"uniqueIndex:idx_team_memberships_team_user;not null" json:"team_id"`
+	UserID    uint      `gorm:"uniqueIndex:idx_team_memberships_team_user;index;not null" json:"user_id"`
+	User      User      `json:"-"`
+}
+
+// TeamNamespace binds a Kubernetes namespace of one cluster to a team. Members of the team may
+// use the namespace within the limits of their role. Bindings without a cluster, which predate
+// the cluster column, are bound to the default cluster once at startup and grant nothing
+// until then.
+type TeamNamespace struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	TeamID    uint      `gorm:"uniqueIndex:idx_team_namespaces_team_cluster_namespace;not null" json:"team_id"`
+	Cluster   string    `gorm:"uniqueIndex:idx_team_namespaces_team_cluster_namespace;not null;default:''" json:"cluster"`
+	Namespace string    `gorm:"uniqueIndex:idx_team_namespaces_team_cluster_namespace;index;not null" json:"namespace"`
+}
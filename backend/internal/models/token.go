@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// APIToken is a long-lived personal access token, e.g. for CI pipelines. It acts as its user,
+// limited to its scopes and, when set, its namespaces.
+type APIToken struct {
+	ID         uint       `gorm:"primarykey" json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	UserID     uint       `gorm:"index;not null" json:"user_id"`
+	User       User       `json:"-"`
+	Name       string     `gorm:"not null" json:"name"`
+	TokenHash  string     `gorm:"uniqueIndex;not null" json:"-"`
+	Prefix     string     `json:"prefix"` // Start of the token, to recognise it in listings
+	Scopes     []string   `gorm:"serializer:json" json:"scopes"`
+	Namespaces []string   `gorm:"serializer:json" json:"namespaces,omitempty"` // Empty allows every namespace of the user
+	ExpiresAt  time.Time  `gorm:"index" json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// APITokenCreateRequest represents a request to create a personal access token
+type APITokenCreateRequest struct {
+	Name          string   `json:"name" binding:"required"`
+	Scopes        []string `json:"scopes" binding:"required,min=1"` // resource:verb, e.g. deployments:write
+	Namespaces    []string `json:"namespaces"`
+	ExpiresInDays int      `json:"expires_in_days" binding:"omitempty,min=1,max=365"` // Defaults to 90
+}
+
+// APITokenCreateResponse represents a newly created personal access token
+type APITokenCreateResponse struct {
+	Token    string   `json:"token"` // Only returned once
+	APIToken APIToken `json:"api_token"`
+}
@@ -9,18 +9,31 @@ import (
 
 // User represents a user in the system
 type User struct {
-	ID        uint           `gorm:"primarykey" json:"id"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
-	Email     string         `gorm:"uniqueIndex;not null" json:"email"`
-	Username  string         `gorm:"uniqueIndex;not null" json:"username"`
-	Password  string         `gorm:"not null" json:"-"`
-	FullName  string         `json:"full_name"`
-	Role      string         `gorm:"default:user" json:"role"` // admin, user
-	Active    bool           `gorm:"default:true" json:"active"`
+	ID          uint           `gorm:"primarykey" json:"id"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+	Email       string         `gorm:"uniqueIndex;not null" json:"email"`
+	Username    string         `gorm:"uniqueIndex;not null" json:"username"`
+	Password    string         `gorm:"not null" json:"-"` // Empty for users that only sign in through OIDC
+	FullName    string         `json:"full_name"`
+	Role        string         `gorm:"default:viewer" json:"role"` // viewer, deployer, admin
+	Active      bool           `gorm:"default:true" json:"active"`
+	OIDCSubject *string        `gorm:"column:oidc_subject;uniqueIndex" json:"-"` // Set for users that signed in through OIDC
+	LastLoginAt *time.Time     `json:"last_login_at,omitempty"`
+	LastLoginIP string         `json:"last_login_ip,omitempty"`
 }
 
+// User roles, from least to most privileged
+const (
+	RoleViewer   = "viewer"
+	RoleDeployer = "deployer"
+	RoleAdmin    = "admin"
+)
+
+// Roles lists the user roles from least to most privileged
+var Roles = []string{RoleViewer, RoleDeployer, RoleAdmin}
+
 // SignupRequest represents the signup request payload
 type SignupRequest struct {
 	Email    string `json:"email" binding:"required,email"`
@@ -37,8 +50,41 @@ type LoginRequest struct {
 
 // LoginResponse represents the login response
 type LoginResponse struct {
-	Token string `json:"token"`
-	User  User   `json:"user"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"` // Access token lifetime in seconds
+	User         User   `json:"user"`
+}
+
+// ProfileUpdateRequest represents a user's changes to their own account. Omitted fields are
+// left unchanged.
+type ProfileUpdateRequest struct {
+	// Email is rejected unless it matches the current address, which only admins can change
+	Email *string `json:"email" binding:"omitempty,email"`
+	// Username is rejected unless it matches the current one. Usernames cannot be changed.
+	Username *string `json:"username" binding:"omitempty,min=3"`
+	FullName *string `json:"full_name"`
+}
+
+// UserUpdateRequest represents an admin's changes to a user. Omitted fields are left unchanged.
+type UserUpdateRequest struct {
+	Email    *string `json:"email" binding:"omitempty,email"`
+	FullName *string `json:"full_name"`
+	Role     *string `json:"role" binding:"omitempty,oneof=viewer deployer admin"`
+	Active   *bool   `json:"active"`
+}
+
+// PasswordResetRequest represents an admin setting a new password for a user
+type PasswordResetRequest struct {
+	NewPassword string `json:"new_password" binding:"required,min=6"`
+}
+
+// UserListResponse is a page of users
+type UserListResponse struct {
+	Users    []User `json:"users"`
+	Total    int64  `json:"total"`
+	Page     int    `json:"page"`
+	PageSize int    `json:"page_size"`
 }
 
 // HashPassword hashes the user's password
@@ -0,0 +1,29 @@
+package models
+
+// Types of WatchEvent messages
+const (
+	WatchAdded    = "ADDED"
+	WatchModified = "MODIFIED"
+	WatchDeleted  = "DELETED"
+	// WatchBookmark carries the resource version to resume from after the initial ADDED
+	// events, and the latest one while no objects change
+	WatchBookmark = "BOOKMARK"
+	// WatchReset means the resource version to resume from has expired. The client must drop
+	// the objects of the kind, the ADDED events that follow carry their current state.
+	WatchReset = "RESET"
+	// WatchError reports a failed watch, which the server retries with backoff. Consecutive
+	// failures are reported once.
+	WatchError = "ERROR"
+)
+
+// WatchEvent is a message of the resource change feed
+type WatchEvent struct {
+	Type string `json:"type"` // ADDED, MODIFIED, DELETED, BOOKMARK, RESET or ERROR
+	Kind string `json:"kind"` // pods, deployments or services
+	// ResourceVersion to resume the kind's watch after this event
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+	// Object is a PodResponse, DeploymentResponse or ServiceResponse. Deleted objects carry
+	// their last state.
+	Object interface{} `json:"object,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
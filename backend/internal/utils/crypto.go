@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// encryptedPrefix marks values encrypted by EncryptSecret, and the format version
+const encryptedPrefix = "v1:"
+
+// EncryptSecret encrypts a secret for storage with AES-256-GCM under a key derived from
+// passphrase. The result is text, so that it fits any database column.
+func EncryptSecret(passphrase string, plaintext []byte) (string, error) {
+	aead, err := secretCipher(passphrase)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	sealed := aead.Seal(nonce, nonce, plaintext, nil)
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// DecryptSecret decrypts a value produced by EncryptSecret with the same passphrase
+func DecryptSecret(passphrase, ciphertext string) ([]byte, error) {
+	aead, err := secretCipher(passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	encoded, found := strings.CutPrefix(ciphertext, encryptedPrefix)
+	if !found {
+		return nil, errors.New("unknown encryption format")
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encrypted value: %w", err)
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("invalid encrypted value: too short")
+	}
+
+	nonce, sealed := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return nil, errors.New("failed to decrypt, the encryption key may have changed")
+	}
+	return plaintext, nil
+}
+
+// secretCipher returns the AES-GCM cipher for a passphrase
+func secretCipher(passphrase string) (cipher.AEAD, error) {
+	if passphrase == "" {
+		return nil, errors.New("no encryption key configured")
+	}
+
+	key := sha256.Sum256([]byte(passphrase))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
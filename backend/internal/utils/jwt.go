@@ -8,41 +8,63 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
-var jwtSecret []byte
+// AccessTokenTTL is how long a JWT access token is valid. Access tokens are short-lived and
+// renewed with a refresh token.
+var AccessTokenTTL = 15 * time.Minute
+
+// RefreshTokenTTL is how long a refresh token, and so a login session without activity, is valid
+var RefreshTokenTTL = 7 * 24 * time.Hour
 
 func init() {
-	secret := os.Getenv("JWT_SECRET")
-	if secret == "" {
-		secret = "your-super-secret-jwt-key-change-this-in-production" // Default for development
+	loadSecretKeySet()
+
+	if ttl, err := time.ParseDuration(os.Getenv("ACCESS_TOKEN_TTL")); err == nil && ttl > 0 {
+		AccessTokenTTL = ttl
+	}
+	if ttl, err := time.ParseDuration(os.Getenv("REFRESH_TOKEN_TTL")); err == nil && ttl > 0 {
+		RefreshTokenTTL = ttl
 	}
-	jwtSecret = []byte(secret)
 }
 
 // Claims represents the JWT claims
 type Claims struct {
-	UserID   uint   `json:"user_id"`
-	Email    string `json:"email"`
-	Username string `json:"username"`
-	Role     string `json:"role"`
+	UserID   uint     `json:"user_id"`
+	Email    string   `json:"email"`
+	Username string   `json:"username"`
+	Role     string   `json:"role"`
+	Teams    []string `json:"teams,omitempty"`
+	// SessionID is the login session the token belongs to. Revoking the session revokes the token.
+	SessionID string `json:"sid"`
 	jwt.RegisteredClaims
 }
 
-// GenerateToken generates a new JWT token for a user
-func GenerateToken(userID uint, email, username, role string) (string, error) {
+// GenerateToken generates a new JWT access token for a user of a login session, together with
+// the names of the teams they belong to
+func GenerateToken(userID uint, email, username, role string, teams []string, sessionID string) (string, error) {
+	jti, err := RandomID()
+	if err != nil {
+		return "", err
+	}
+
 	claims := Claims{
-		UserID:   userID,
-		Email:    email,
-		Username: username,
-		Role:     role,
+		UserID:    userID,
+		Email:     email,
+		Username:  username,
+		Role:      role,
+		Teams:     teams,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)), // Token expires in 24 hours
+			ID:        jti,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(AccessTokenTTL)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(jwtSecret)
+	key := jwtKeys.signing
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.id
+	tokenString, err := token.SignedString(key.private)
 	if err != nil {
 		return "", fmt.Errorf("failed to sign token: %w", err)
 	}
@@ -53,11 +75,17 @@ func GenerateToken(userID uint, email, username, role string) (string, error) {
 // ValidateToken validates a JWT token and returns the claims
 func ValidateToken(tokenString string) (*Claims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		// Verify the signing method
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		kid, _ := token.Header["kid"].(string)
+		key, err := jwtKeys.verificationKey(kid)
+		if err != nil {
+			return nil, err
+		}
+
+		// Verify the signing method, so that a public key is never used as an HMAC secret
+		if token.Method.Alg() != key.method.Alg() {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
-		return jwtSecret, nil
+		return key.public, nil
 	})
 
 	if err != nil {
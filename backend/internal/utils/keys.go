@@ -0,0 +1,307 @@
+package utils
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// defaultJWTSecret signs tokens when neither JWT_SECRET nor a signing key file is configured.
+// It is public, so the server refuses to use it in release mode.
+const defaultJWTSecret = "your-super-secret-jwt-key-change-this-in-production"
+
+// jwtKey is a key that access tokens are signed or verified with
+type jwtKey struct {
+	id     string
+	method jwt.SigningMethod
+	// private signs tokens. It is nil for keys that are only accepted for verification.
+	private interface{}
+	public  interface{}
+	// published reports whether the key belongs in the JWKS. Shared HMAC secrets never do.
+	published bool
+}
+
+// jwtKeySet holds the key new tokens are signed with and every key tokens are accepted from
+type jwtKeySet struct {
+	signing *jwtKey
+	keys    map[string]*jwtKey
+	// legacy verifies tokens without a kid header, issued before keys had IDs
+	legacy *jwtKey
+	// defaultSecret reports whether the public development secret is in use
+	defaultSecret bool
+}
+
+var jwtKeys jwtKeySet
+
+// JSONWebKey is a public key in JWK format (RFC 7517)
+type JSONWebKey struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	Y         string `json:"y,omitempty"`
+}
+
+// JSONWebKeySet is the document served at /.well-known/jwks.json
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// loadSecretKeySet sets up HS256 signing with JWT_SECRET, or the development default
+func loadSecretKeySet() {
+	secret := os.Getenv("JWT_SECRET")
+	if secret == "" {
+		secret = defaultJWTSecret
+	}
+	key := secretKey(secret)
+
+	jwtKeys = jwtKeySet{
+		signing:       key,
+		keys:          map[string]*jwtKey{key.id: key},
+		legacy:        key,
+		defaultSecret: secret == defaultJWTSecret,
+	}
+}
+
+// secretKey returns the HS256 key for a shared secret. Its ID is derived from the secret so
+// that every replica sharing the secret agrees on it.
+func secretKey(secret string) *jwtKey {
+	sum := sha256.Sum256([]byte("kid:" + secret))
+	return &jwtKey{
+		id:      "hs-" + hex.EncodeToString(sum[:8]),
+		method:  jwt.SigningMethodHS256,
+		private: []byte(secret),
+		public:  []byte(secret),
+	}
+}
+
+// LoadSigningKeys switches token signing to the RSA or ECDSA private key in signingKeyFile
+// and additionally accepts tokens signed with the keys in verificationKeyFiles, which may hold
+// public or private keys. Listing the next key as a verification key on every replica before
+// signing with it, and keeping the previous key listed until its tokens have expired, rotates
+// keys without logging anyone out. JWT_SECRET, when set, stays valid for verification so that
+// existing HS256 tokens are accepted while switching to asymmetric keys.
+func LoadSigningKeys(signingKeyFile string, verificationKeyFiles []string) error {
+	if signingKeyFile == "" && len(verificationKeyFiles) == 0 {
+		return nil
+	}
+
+	keys := jwtKeySet{
+		signing:       jwtKeys.signing,
+		keys:          make(map[string]*jwtKey),
+		legacy:        jwtKeys.legacy,
+		defaultSecret: jwtKeys.defaultSecret,
+	}
+	if signingKeyFile != "" {
+		key, err := readKeyFile(signingKeyFile)
+		if err != nil {
+			return err
+		}
+		if key.private == nil {
+			return fmt.Errorf("signing key %s holds no private key", signingKeyFile)
+		}
+		keys.signing = key
+
+		// The development secret is only kept when it is still needed for signing
+		if keys.defaultSecret {
+			keys.legacy = nil
+			keys.defaultSecret = false
+		}
+	}
+
+	if keys.legacy != nil {
+		keys.keys[keys.legacy.id] = keys.legacy
+	}
+	keys.keys[keys.signing.id] = keys.signing
+	for _, file := range verificationKeyFiles {
+		key, err := readKeyFile(file)
+		if err != nil {
+			return err
+		}
+		if _, ok := keys.keys[key.id]; !ok {
+			key.private = nil
+			keys.keys[key.id] = key
+		}
+	}
+
+	jwtKeys = keys
+	return nil
+}
+
+// UsingDefaultSecret reports whether tokens are signed or accepted with the public development
+// secret, because JWT_SECRET is unset or still has the example value and no signing key is
+// configured
+func UsingDefaultSecret() bool {
+	return jwtKeys.defaultSecret
+}
+
+// PublicKeys returns the public keys that access tokens are accepted from
+func PublicKeys() JSONWebKeySet {
+	set := JSONWebKeySet{Keys: make([]JSONWebKey, 0, len(jwtKeys.keys))}
+	for _, key := range jwtKeys.keys {
+		if key.published {
+			set.Keys = append(set.Keys, key.jwk())
+		}
+	}
+	slices.SortFunc(set.Keys, func(a, b JSONWebKey) int { return strings.Compare(a.KeyID, b.KeyID) })
+	return set
+}
+
+// verificationKey returns the key a token with the given kid header must be verified with
+func (s jwtKeySet) verificationKey(kid string) (*jwtKey, error) {
+	if kid == "" {
+		if s.legacy == nil {
+			return nil, errors.New("token has no key ID")
+		}
+		return s.legacy, nil
+	}
+
+	key, ok := s.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return key, nil
+}
+
+// readKeyFile reads a PEM encoded RSA or ECDSA key, private or public
+func readKeyFile(file string) (*jwtKey, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("key file %s is not PEM encoded", file)
+	}
+
+	key, err := parseKey(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse key file %s: %w", file, err)
+	}
+	return key, nil
+}
+
+// parseKey builds a key from a PEM block in any of the usual private and public key encodings
+func parseKey(block *pem.Block) (*jwtKey, error) {
+	var private, public interface{}
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		private = key
+	case "RSA PRIVATE KEY":
+		key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		private = key
+	case "EC PRIVATE KEY":
+		key, err := x509.ParseECPrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		private = key
+	case "PUBLIC KEY":
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		public = key
+	case "RSA PUBLIC KEY":
+		key, err := x509.ParsePKCS1PublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		public = key
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+
+	switch key := private.(type) {
+	case *rsa.PrivateKey:
+		public = &key.PublicKey
+	case *ecdsa.PrivateKey:
+		public = &key.PublicKey
+	case nil:
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", private)
+	}
+
+	var method jwt.SigningMethod
+	switch key := public.(type) {
+	case *rsa.PublicKey:
+		method = jwt.SigningMethodRS256
+	case *ecdsa.PublicKey:
+		switch key.Curve {
+		case elliptic.P256():
+			method = jwt.SigningMethodES256
+		case elliptic.P384():
+			method = jwt.SigningMethodES384
+		case elliptic.P521():
+			method = jwt.SigningMethodES512
+		default:
+			return nil, fmt.Errorf("unsupported curve %s", key.Curve.Params().Name)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", public)
+	}
+
+	// The ID is derived from the public key so that it is the same on every replica
+	der, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(der)
+
+	return &jwtKey{
+		id:        base64.RawURLEncoding.EncodeToString(sum[:12]),
+		method:    method,
+		private:   private,
+		public:    public,
+		published: true,
+	}, nil
+}
+
+// jwk returns the public key in JWK format
+func (k *jwtKey) jwk() JSONWebKey {
+	jwk := JSONWebKey{
+		KeyID:     k.id,
+		Use:       "sig",
+		Algorithm: k.method.Alg(),
+	}
+
+	switch key := k.public.(type) {
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(key.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes())
+	case *ecdsa.PublicKey:
+		// Coordinates are padded to the size of the curve, as RFC 7518 requires
+		size := (key.Curve.Params().BitSize + 7) / 8
+		jwk.KeyType = "EC"
+		jwk.Curve = key.Curve.Params().Name
+		jwk.X = base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, size)))
+		jwk.Y = base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, size)))
+	}
+	return jwk
+}
@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// APITokenPrefix marks personal access tokens so they can be told apart from JWTs
+const APITokenPrefix = "kd_"
+
+// GenerateAPIToken creates a new personal access token and returns it with its hash.
+// Only the hash is stored, the token itself is shown to the user once.
+func GenerateAPIToken() (string, string, error) {
+	return generateSecretToken(APITokenPrefix)
+}
+
+// GenerateRefreshToken creates a new refresh token and returns it with its hash.
+// Only the hash is stored.
+func GenerateRefreshToken() (string, string, error) {
+	return generateSecretToken("")
+}
+
+// RandomID returns a random identifier, e.g. for sessions and JWT IDs
+func RandomID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("failed to generate id: %w", err)
+	}
+	return hex.EncodeToString(id), nil
+}
+
+// generateSecretToken creates a random token with the given prefix and returns it with its hash
+func generateSecretToken(prefix string) (string, string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", fmt.Errorf("failed to generate token: %w", err)
+	}
+
+	token := prefix + base64.RawURLEncoding.EncodeToString(secret)
+	return token, HashToken(token), nil
+}
+
+// HashToken returns the hash a personal access token or refresh token is stored under.
+// Tokens carry 256 bits of randomness, so a fast hash is enough.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// IsAPIToken reports whether a bearer token is a personal access token rather than a JWT
+func IsAPIToken(token string) bool {
+	return strings.HasPrefix(token, APITokenPrefix)
+}
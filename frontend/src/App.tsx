@@ -7,6 +7,7 @@ import { DeployPod } from './pages/DeployPod';
 import { PodList } from './pages/PodList';
 import { Login } from './pages/Login';
 import { Signup } from './pages/Signup';
+import { AuthCallback } from './pages/AuthCallback';
 import AdvancedDeploy from './pages/AdvancedDeploy';
 import DeploymentList from './pages/DeploymentList';
 import ServiceList from './pages/ServiceList';
@@ -29,6 +30,7 @@ function App() {
           <Route path="/" element={<Home />} />
           <Route path="/login" element={<Login />} />
           <Route path="/signup" element={<Signup />} />
+          <Route path="/auth/callback" element={<AuthCallback />} />
 
           {/* Protected routes (with layout) */}
           <Route path="/dashboard" element={<Layout />}>
@@ -1,6 +1,7 @@
 import { Link, Outlet, useLocation, useNavigate } from 'react-router-dom';
 import { Server, List, Sparkles, Rocket, Layers, Globe, LogOut, User } from 'lucide-react';
 import { Button } from '@/components/ui/button';
+import { authAPI, clearSession } from '@/lib/api';
 
 export function Layout() {
   const location = useLocation();
@@ -16,9 +17,13 @@ export function Layout() {
 
   const isActive = (path: string) => location.pathname === path;
 
-  const handleLogout = () => {
-    localStorage.removeItem('token');
-    localStorage.removeItem('user');
+  const handleLogout = async () => {
+    try {
+      await authAPI.logout();
+    } catch {
+      // The session may already have ended, clear it locally regardless
+    }
+    clearSession();
     navigate('/login');
   };
 
@@ -24,35 +24,100 @@ api.interceptors.request.use(
   }
 );
 
+// Store the tokens returned by login, signup, refresh and password changes
+export const storeSession = (data: { token: string; refresh_token: string }) => {
+  localStorage.setItem('token', data.token);
+  localStorage.setItem('refreshToken', data.refresh_token);
+};
+
+export const clearSession = () => {
+  localStorage.removeItem('token');
+  localStorage.removeItem('refreshToken');
+  localStorage.removeItem('user');
+};
+
+// Concurrent requests that fail with 401 share a single refresh
+let refreshRequest: Promise<string> | null = null;
+
+const refreshAccessToken = (): Promise<string> => {
+  if (!refreshRequest) {
+    const refreshToken = localStorage.getItem('refreshToken');
+    refreshRequest = (refreshToken
+      ? axios.post(`${API_BASE_URL}/auth/refresh`, { refresh_token: refreshToken })
+      : Promise.reject(new Error('No refresh token'))
+    )
+      .then((response) => {
+        storeSession(response.data.data);
+        return response.data.data.token as string;
+      })
+      .finally(() => {
+        refreshRequest = null;
+      });
+  }
+  return refreshRequest;
+};
+
 // Response interceptor
 api.interceptors.response.use(
   (response) => response,
-  (error) => {
-    if (error.response?.status === 401) {
+  async (error) => {
+    const request = error.config;
+    if (error.response?.status === 401 && request && !request._retried && !request.url?.startsWith('/auth/')) {
+      // The access token expired, renew it once and retry the request
+      request._retried = true;
+      try {
+        const token = await refreshAccessToken();
+        request.headers.Authorization = `Bearer ${token}`;
+        return api(request);
+      } catch {
+        // Fall through to the login page
+      }
+    }
+    if (error.response?.status === 401 && !request?.url?.startsWith('/auth/login')) {
       // Handle unauthorized
-      localStorage.removeItem('token');
+      clearSession();
       window.location.href = '/login';
     }
     return Promise.reject(error);
   }
 );
 
+// Query parameters shared by the pod, deployment, service and event lists. status and search
+// filter each page after it has been fetched, so a page can be empty while its continue token
+// is set: keep following continue until it is empty. sort cannot be combined with limit or
+// continue.
+export interface ListParams {
+  limit?: number;
+  continue?: string;
+  labelSelector?: string;
+  fieldSelector?: string;
+  status?: string;
+  search?: string;
+  sort?: string;
+  managedOnly?: boolean;
+}
+
 // API methods
 export const podAPI = {
-  list: (namespace?: string) =>
-    api.get('/pods', { params: { namespace } }),
+  list: (namespace?: string, params?: ListParams) =>
+    api.get('/pods', { params: { namespace, ...params } }),
 
   get: (namespace: string, name: string) =>
     api.get(`/pods/${namespace}/${name}`),
 
-  create: (data: any) =>
-    api.post('/pods', data),
+  create: (data: any, options?: { apply?: boolean; dryRun?: boolean }) =>
+    api.post('/pods', data, { params: options }),
 
   delete: (namespace: string, name: string) =>
     api.delete(`/pods/${namespace}/${name}`),
 
-  getLogs: (namespace: string, name: string, tail?: number) =>
-    api.get(`/pods/${namespace}/${name}/logs`, { params: { tail } }),
+  getLogs: (
+    namespace: string,
+    name: string,
+    tail?: number,
+    options?: { container?: string; previous?: boolean; sinceSeconds?: number; timestamps?: boolean }
+  ) =>
+    api.get(`/pods/${namespace}/${name}/logs`, { params: { tail, ...options } }),
 };
 
 export const namespaceAPI = {
@@ -61,37 +126,85 @@ export const namespaceAPI = {
 
 // Deployment API
 export const deploymentAPI = {
-  list: (namespace?: string) =>
-    api.get("/deployments", { params: { namespace } }),
+  list: (namespace?: string, params?: ListParams) =>
+    api.get("/deployments", { params: { namespace, ...params } }),
 
   get: (namespace: string, name: string) =>
     api.get(`/deployments/${namespace}/${name}`),
 
-  create: (data: any) =>
-    api.post("/deployments", data),
+  create: (data: any, options?: { apply?: boolean; dryRun?: boolean }) =>
+    api.post("/deployments", data, { params: options }),
 
   delete: (namespace: string, name: string) =>
     api.delete(`/deployments/${namespace}/${name}`),
 
   scale: (namespace: string, name: string, replicas: number) =>
     api.put(`/deployments/${namespace}/${name}/scale`, { replicas }),
+
+  updateImage: (namespace: string, name: string, image: string, container?: string) =>
+    api.put(`/deployments/${namespace}/${name}/image`, { image, container }),
+
+  rollback: (namespace: string, name: string, revision?: number) =>
+    api.post(`/deployments/${namespace}/${name}/rollback`, null, { params: { revision } }),
+
+  history: (namespace: string, name: string) =>
+    api.get(`/deployments/${namespace}/${name}/history`),
+
+  getLogs: (namespace: string, name: string, tail?: number, container?: string) =>
+    api.get(`/deployments/${namespace}/${name}/logs`, { params: { tail, container } }),
+
+  pause: (namespace: string, name: string) =>
+    api.post(`/deployments/${namespace}/${name}/pause`),
+
+  resume: (namespace: string, name: string) =>
+    api.post(`/deployments/${namespace}/${name}/resume`),
+
+  restart: (namespace: string, name: string) =>
+    api.post(`/deployments/${namespace}/${name}/restart`),
 };
 
 // Service API
 export const serviceAPI = {
-  list: (namespace?: string) =>
-    api.get("/services", { params: { namespace } }),
+  list: (namespace?: string, params?: ListParams) =>
+    api.get("/services", { params: { namespace, ...params } }),
 
   get: (namespace: string, name: string) =>
     api.get(`/services/${namespace}/${name}`),
 
-  create: (data: any) =>
-    api.post("/services", data),
+  create: (data: any, options?: { apply?: boolean; dryRun?: boolean }) =>
+    api.post("/services", data, { params: options }),
 
   delete: (namespace: string, name: string) =>
     api.delete(`/services/${namespace}/${name}`),
 };
 
+// Event API
+export const eventAPI = {
+  list: (params?: { namespace?: string; kind?: string; name?: string } & ListParams) =>
+    api.get("/events", { params }),
+
+  forPod: (namespace: string, name: string) =>
+    api.get(`/pods/${namespace}/${name}/events`),
+
+  forDeployment: (namespace: string, name: string) =>
+    api.get(`/deployments/${namespace}/${name}/events`),
+
+  forService: (namespace: string, name: string) =>
+    api.get(`/services/${namespace}/${name}/events`),
+};
+
+// Manifest API
+export const manifestAPI = {
+  apply: (file: File, namespace?: string, dryRun?: boolean) => {
+    const formData = new FormData();
+    formData.append("file", file);
+    return api.post("/manifests", formData, {
+      params: { namespace, dryRun },
+      headers: { "Content-Type": "multipart/form-data" },
+    });
+  },
+};
+
 // Auth API
 export const authAPI = {
   signup: (data: { email: string; username: string; password: string; full_name?: string }) =>
@@ -102,4 +215,78 @@ export const authAPI = {
 
   me: () =>
     api.get("/auth/me"),
+
+  logout: () =>
+    api.post("/auth/logout"),
+
+  // Single sign-on is a browser redirect rather than an API call
+  oidcLoginURL: () => `${API_BASE_URL}/auth/oidc/login`,
+
+  updateMe: (data: { full_name?: string }) =>
+    api.put("/auth/me", data),
+
+  changePassword: (data: { current_password: string; new_password: string }) =>
+    api.post("/auth/me/password", data),
+};
+
+// User administration API (admins only)
+export const usersAPI = {
+  list: (params?: { q?: string; role?: string; active?: boolean; page?: number; page_size?: number }) =>
+    api.get("/admin/users", { params }),
+
+  get: (id: number) =>
+    api.get(`/admin/users/${id}`),
+
+  update: (id: number, data: { email?: string; full_name?: string; role?: string; active?: boolean }) =>
+    api.put(`/admin/users/${id}`, data),
+
+  delete: (id: number) =>
+    api.delete(`/admin/users/${id}`),
+
+  resetPassword: (id: number, newPassword: string) =>
+    api.post(`/admin/users/${id}/password`, { new_password: newPassword }),
+};
+
+export const clustersAPI = {
+  list: () =>
+    api.get("/clusters"),
+
+  get: (name: string) =>
+    api.get(`/clusters/${name}`),
+
+  register: (data: { name: string; kubeconfig: string; context?: string }) =>
+    api.post("/clusters", data),
+
+  delete: (name: string) =>
+    api.delete(`/clusters/${name}`),
+};
+
+// Resource change feed. The socket receives a JSON event for every added, modified or deleted
+// pod, deployment or service. The current objects come first, followed by a BOOKMARK event.
+// Pass the last resourceVersion of each kind, including that of bookmarks, to resume after a
+// reconnect.
+export const watchAPI = {
+  connect: (options?: {
+    kinds?: Array<'pods' | 'deployments' | 'services'>;
+    namespace?: string;
+    resourceVersions?: Record<string, string>;
+  }) => {
+    const url = new URL(`${API_BASE_URL}/watch`, window.location.href);
+    url.protocol = url.protocol === 'https:' ? 'wss:' : 'ws:';
+    if (options?.kinds?.length) {
+      url.searchParams.set('kinds', options.kinds.join(','));
+    }
+    if (options?.namespace) {
+      url.searchParams.set('namespace', options.namespace);
+    }
+    for (const [kind, version] of Object.entries(options?.resourceVersions ?? {})) {
+      url.searchParams.append('resourceVersion', `${kind}:${version}`);
+    }
+    // Browsers cannot set headers on WebSocket connections
+    const token = localStorage.getItem('token');
+    if (token) {
+      url.searchParams.set('token', token);
+    }
+    return new WebSocket(url);
+  },
 };
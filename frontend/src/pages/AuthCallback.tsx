@@ -0,0 +1,52 @@
+import { useEffect, useState } from 'react';
+import { useNavigate, Link } from 'react-router-dom';
+import { authAPI, storeSession, clearSession } from '@/lib/api';
+import { Card } from '@/components/ui/card';
+
+// Landing page for single sign-on. The backend passes the tokens in the URL fragment.
+export function AuthCallback() {
+  const navigate = useNavigate();
+  const [error, setError] = useState('');
+
+  useEffect(() => {
+    const params = new URLSearchParams(window.location.hash.slice(1));
+    const token = params.get('token');
+    const refreshToken = params.get('refresh_token');
+    // Keep the tokens out of the browser history
+    window.history.replaceState(null, '', window.location.pathname);
+
+    if (!token || !refreshToken) {
+      setError('Single sign-on failed, no tokens were returned');
+      return;
+    }
+
+    storeSession({ token, refresh_token: refreshToken });
+    authAPI
+      .me()
+      .then((response) => {
+        localStorage.setItem('user', JSON.stringify(response.data.data));
+        navigate('/dashboard', { replace: true });
+      })
+      .catch(() => {
+        clearSession();
+        setError('Single sign-on failed, please try again');
+      });
+  }, [navigate]);
+
+  return (
+    <div className="min-h-screen flex items-center justify-center bg-slate-900">
+      <Card className="w-full max-w-md p-8 glass-effect border-slate-700/50 text-center">
+        {error ? (
+          <>
+            <p className="text-red-400 mb-4">{error}</p>
+            <Link to="/login" className="text-purple-400 hover:text-purple-300 font-medium">
+              Back to login
+            </Link>
+          </>
+        ) : (
+          <p className="text-slate-400">Signing you in...</p>
+        )}
+      </Card>
+    </div>
+  );
+}
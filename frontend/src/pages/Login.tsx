@@ -1,12 +1,12 @@
 import { useState } from 'react';
 import { useMutation } from '@tanstack/react-query';
 import { useNavigate, Link } from 'react-router-dom';
-import { authAPI } from '@/lib/api';
+import { authAPI, storeSession } from '@/lib/api';
 import { Button } from '@/components/ui/button';
 import { Input } from '@/components/ui/input';
 import { Label } from '@/components/ui/label';
 import { Card } from '@/components/ui/card';
-import { LogIn, Server } from 'lucide-react';
+import { KeyRound, LogIn, Server } from 'lucide-react';
 
 export function Login() {
   const navigate = useNavigate();
@@ -17,8 +17,8 @@ export function Login() {
   const loginMutation = useMutation({
     mutationFn: (data: { email: string; password: string }) => authAPI.login(data),
     onSuccess: (response) => {
-      const { token, user } = response.data.data;
-      localStorage.setItem('token', token);
+      const { user } = response.data.data;
+      storeSession(response.data.data);
       localStorage.setItem('user', JSON.stringify(user));
       navigate('/dashboard');
     },
@@ -147,6 +147,16 @@ export function Login() {
           </Button>
         </form>
 
+        <Button
+          type="button"
+          onClick={() => window.location.assign(authAPI.oidcLoginURL())}
+          className="w-full mt-4 bg-slate-800/50 hover:bg-slate-700/50 text-slate-200 border border-slate-600 font-medium py-2"
+          variant="outline"
+        >
+          <KeyRound className="h-4 w-4 mr-2" />
+          Sign in with SSO
+        </Button>
+
         <div className="mt-6 text-center">
           <p className="text-slate-400">
             Don't have an account?{' '}
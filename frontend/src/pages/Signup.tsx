@@ -1,7 +1,7 @@
 import { useState } from 'react';
 import { useMutation } from '@tanstack/react-query';
 import { useNavigate, Link } from 'react-router-dom';
-import { authAPI } from '@/lib/api';
+import { authAPI, storeSession } from '@/lib/api';
 import { Button } from '@/components/ui/button';
 import { Input } from '@/components/ui/input';
 import { Label } from '@/components/ui/label';
@@ -23,8 +23,8 @@ export function Signup() {
     mutationFn: (data: { email: string; username: string; password: string; full_name?: string }) =>
       authAPI.signup(data),
     onSuccess: (response) => {
-      const { token, user } = response.data.data;
-      localStorage.setItem('token', token);
+      const { user } = response.data.data;
+      storeSession(response.data.data);
       localStorage.setItem('user', JSON.stringify(user));
       navigate('/dashboard');
     },
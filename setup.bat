@@ -48,7 +48,7 @@ echo ============================================
 echo.
 echo To start the application:
 echo   1. Open TWO command prompts
-echo   2. In first: cd backend ^&^& go run cmd/server/main.go
+echo   2. In first: cd backend ^&^& set AUTH_DISABLED=true^&^& go run cmd/server/main.go
 echo   3. In second: cd frontend ^&^& npm run dev
 echo   4. Open http://localhost:5173 in your browser
 echo.